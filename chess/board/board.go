@@ -77,9 +77,17 @@ type Board struct {
 
 	RKRmoved [2][3]bool
 
+	// a Chess960 game castles with the rooks on RookFiles, the queen
+	// side's first, by the king moving onto its own rook
+	Chess960 bool
+
+	RookFiles [2][2]int
+
 	EnPassantSquare *Square
 
-	MoveCounter uint8
+	MoveCounter uint16
+
+	HalfMoveClock uint8
 }
 
 func NewBoard() *Board {
@@ -110,63 +118,13 @@ func NewBoard() *Board {
 func (b *Board) IsLegal(start Square, end Square, promotion Piece) (Piece, Piece) {
 	// Checks if moving piece from start to end with promotion
 	// (or not if empty) is legal.
-	color := b.Turn
-	otherColor := color.Other()
-	opponentMoves := b.AllAttacks(otherColor)
-	piece := b.GetPieceAt(start, color)
-	output := Empty
-	outputPromotion := Empty
-	var endBB Bitboard = end.GetFile() & end.GetRank()
-	// checks through the pieceBB for the piece we are moving
-	if promotion == Pawns || promotion == Kings {
-		return Empty, Empty
-	}
-	switch piece {
-	case Empty:
-		fmt.Println("must select piece")
-		return Empty, Empty
-	case Pawns:
-		pawnMoves := GetPawnMoves(start, b.FullBB, color, b.ColorBB[otherColor])
-		if (pawnMoves & endBB & ^b.ColorBB[color]) != 0 {
-			if end.GetRank() == Rank8 || end.GetRank() == Rank1 {
-				if promotion != Empty {
-					outputPromotion = promotion
-				} else {
-					return Empty, Empty
-				}
-			}
-			output = Pawns
-		}
-	case Knights:
-		if (allKnightMoves[start] & endBB & ^b.ColorBB[color]) != 0 {
-			output = Knights
-		}
-	case Bishops:
-		if (GetBishopMoves(start, b.FullBB) & endBB & ^b.ColorBB[color]) != 0 {
-			output = Bishops
-		}
-	case Rooks:
-		if (GetRookMoves(start, b.FullBB) & endBB & ^b.ColorBB[color]) != 0 {
-			output = Rooks
-		}
-	case Queens:
-		if (GetQueenMoves(start, b.FullBB) & endBB & ^b.ColorBB[color]) != 0 {
-			output = Queens
-		}
-	case Kings:
-		if (GetKingMoves(start, b.FullBB, color, b.RKRmoved[color], opponentMoves) & endBB & ^b.ColorBB[color] & ^opponentMoves) != 0 {
-			output = Kings
+	// returns the moving piece and promotion, or Empty if not legal.
+	for _, m := range b.LegalMoves() {
+		if m.From == start && m.To == end && m.Promotion == promotion {
+			return m.Piece, m.Promotion
 		}
 	}
-	// king can't be in check after move
-	if (b.PieceBB[color][Kings] & opponentMoves) != 0 {
-		fmt.Println("You are in check")
-		fmt.Println(PrintBB(opponentMoves))
-		return Empty, Empty
-	} else if promotion != outputPromotion {
-		return Empty, Empty
-	}
-	return output, outputPromotion
+	return Empty, Empty
 }
 
 func (b *Board) MovePiece(piece Piece, start, end Square, promotion Piece) bool {
@@ -175,15 +133,15 @@ func (b *Board) MovePiece(piece Piece, start, end Square, promotion Piece) bool
 	color := b.Turn
 	otherColor := color.Other()
 	startFile := start.GetFile()
-	startRank := start.GetRank()
 	endFile := end.GetFile()
-	endRank := end.GetRank()
+	enPassant := b.EnPassantSquare
 
 	var capture bool = false
 
 	if piece == Empty {
 		return false
 	}
+	b.EnPassantSquare = nil
 	// checks through the other colored bb to remove captured piece if relevent
 	for p := Pawns; p <= Kings; p++ {
 		if b.PieceBB[otherColor][p]&(1<<end) != 0 {
@@ -191,56 +149,93 @@ func (b *Board) MovePiece(piece Piece, start, end Square, promotion Piece) bool
 			capture = true
 		}
 	}
+	// a captured rook on its home square loses its castling right
+	if capture {
+		b.rookGone(otherColor, end)
+	}
+	// a Chess960 castle puts the king and rook on the squares they'd
+	// have in standard chess, wherever they started
+	if piece == Kings && b.Chess960 && b.PieceBB[color][Rooks].GetBit(end) {
+		base := start &^ 7
+		kingTo, rookTo := base+2, base+3
+		if end > start {
+			kingTo, rookTo = base+6, base+5
+		}
+		b.PieceBB[color][Rooks].ZeroBit(end)
+		b.PieceBB[color][Kings].ZeroBit(start)
+		b.PieceBB[color][Rooks].SetBit(rookTo)
+		b.PieceBB[color][Kings].SetBit(kingTo)
+		b.RKRmoved[color] = [3]bool{true, true, true}
+		b.CombineBB()
+		b.MoveCounter++
+		b.HalfMoveClock++
+		return false
+	}
 	// checks if castling
 	if piece == Kings {
-		if startFile == FileE && endFile == FileC {
-			b.MoveCounter--
-			if color == White {
-				b.MovePiece(Rooks, 1, 4, Empty)
-			} else {
-				b.MovePiece(Rooks, 56, 59, Empty)
-			}
-			b.RKRmoved[color][2] = true
-		} else if startFile == FileE && endFile == FileG {
-			b.MoveCounter--
-			if color == White {
-				b.MovePiece(Rooks, 7, 5, Empty)
-			} else {
-				b.MovePiece(Rooks, 63, 61, Empty)
-			}
-			b.RKRmoved[color][0] = true
+		if !b.Chess960 && startFile == FileE && endFile == FileC {
+			b.PieceBB[color][Rooks].ZeroBit(start - 4)
+			b.PieceBB[color][Rooks].SetBit(start - 1)
+		} else if !b.Chess960 && startFile == FileE && endFile == FileG {
+			b.PieceBB[color][Rooks].ZeroBit(start + 3)
+			b.PieceBB[color][Rooks].SetBit(start + 1)
 		}
 		b.RKRmoved[color][1] = true
 	} else if piece == Rooks {
-		if startFile == FileA {
-			b.RKRmoved[color][0] = true
-		} else if startFile == FileH {
-			b.RKRmoved[color][2] = true
-		}
+		b.rookGone(color, start)
 	} else if piece == Pawns {
-		if color == White && startRank == Rank2 && endRank == Rank4 {
+		if color == White && end == start+16 {
 			newsq := end - 8
 			b.EnPassantSquare = &newsq
-		} else if color == Black && startRank == Rank7 && endRank == Rank5 {
+		} else if color == Black && end+16 == start {
 			newsq := end + 8
 			b.EnPassantSquare = &newsq
-		} else {
-			b.EnPassantSquare = nil
+		} else if enPassant != nil && end == *enPassant {
+			// en passant removes the pawn behind the target square
+			if color == White {
+				b.PieceBB[otherColor][Pawns].ZeroBit(end - 8)
+			} else {
+				b.PieceBB[otherColor][Pawns].ZeroBit(end + 8)
+			}
+			capture = true
 		}
 	}
 	// actually moves the selected piece with promotion check
+	b.PieceBB[color][piece].ZeroBit(start)
 	if promotion == Empty {
 		b.PieceBB[color][piece].SetBit(end)
 	} else {
 		b.PieceBB[color][promotion].SetBit(end)
 	}
-	b.PieceBB[color][piece].ZeroBit(start)
 	// pushes through the changed piecebb to affect all other bbs
 	b.CombineBB()
 	b.MoveCounter++
+	if capture || piece == Pawns {
+		b.HalfMoveClock = 0
+	} else {
+		b.HalfMoveClock++
+	}
 	return capture
 }
 
+func (b *Board) rookGone(color Color, sq Square) {
+	// marks the castling right of a rook leaving (or captured on)
+	// its home square as used.
+	home := Square(0)
+	if color == Black {
+		home = 56
+	}
+	queenSide, kingSide := home, home+7
+	if b.Chess960 {
+		queenSide, kingSide = home+Square(b.RookFiles[color][0]), home+Square(b.RookFiles[color][1])
+	}
+	if sq == queenSide {
+		b.RKRmoved[color][0] = true
+	} else if sq == kingSide {
+		b.RKRmoved[color][2] = true
+	}
+}
+
 func (b *Board) CombineBB() {
 	b.ColorBB[White] = 0
 	b.ColorBB[Black] = 0
@@ -452,12 +447,12 @@ func GetPawnMoves(sq Square, fullBB Bitboard, color Color, otherColorBB Bitboard
 		// adds double push
 		moves |= ((moves & Rank3) << 8) & ^fullBB
 		// adds takes
-		moves |= (((1<<sq + 7) & ^FileA) & otherColorBB) | (((1<<sq + 9) & ^FileH) & otherColorBB)
+		moves |= PawnAttacks(sq, color) & otherColorBB
 	} else {
 		// same for black
 		moves = (1 << (sq - 8)) & ^fullBB
 		moves |= ((moves & Rank6) >> 8) & ^fullBB
-		moves |= (((1 << (sq - 7)) & ^FileA) & otherColorBB) | (((1 << (sq - 9)) & ^FileH) & otherColorBB)
+		moves |= PawnAttacks(sq, color) & otherColorBB
 	}
 	return moves
 }
@@ -487,7 +482,7 @@ func (b *Board) GetPawnAttacks(color Color) Bitboard {
 	var moves Bitboard
 	pawns := b.PieceBB[color][Pawns]
 	if color == White {
-		moves = (((pawns << 7) & ^FileA) & b.ColorBB[Black]) | (((pawns << 9) & ^FileH) & b.ColorBB[Black])
+		moves = (((pawns << 7) & ^FileH) & b.ColorBB[Black]) | (((pawns << 9) & ^FileA) & b.ColorBB[Black])
 	} else {
 		moves = (((pawns >> 7) & ^FileA) & b.ColorBB[White]) | (((pawns >> 9) & ^FileH) & b.ColorBB[White])
 	}
@@ -508,68 +503,24 @@ func (b *Board) GetKnightMoves(color Color) Bitboard {
 }
 
 func GetBishopMoves(sq Square, fullBB Bitboard) Bitboard {
-	// Creates a bitboard of every legal move that a rook
-	// of the coresponding color and square could make.
-	rank := sq / 8
-	file := sq % 8
-	var moves Bitboard = 0
+	// Creates a bitboard of every square a bishop on sq can
+	// reach, stopping at (and including) the first blocker.
+	return slide(sq, fullBB, [4][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}})
+}
 
-	// up right
-	f := file + 1
-	for r := rank + 1; r < 8; r++ {
-		target := r*8 + f
-		moves |= 1 << uint(target)
-		if (fullBB & (1 << uint(target))) != 0 {
-			break
-		}
-		if f >= 7 {
-			break
-		}
-		f++
-	}
-	// down right
-	if rank != 0 {
-		f = file + 1
-		for r := rank - 1; r >= 0; r-- {
-			target := r*8 + f
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
-			if f >= 7 {
-				break
-			}
-			f++
-		}
-	}
-	// down left
-	if rank != 0 && file != 0 {
-		f = file - 1
-		for r := rank - 1; r >= 0; r-- {
-			target := r*8 + f
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
-			if f == 0 {
-				break
-			}
-			f--
-		}
-	}
-	// up left
-	if file != 0 {
-		f = file - 1
-		for r := rank + 1; r < 8; r++ {
-			target := r*8 + f
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
-			if f == 0 {
+func slide(sq Square, fullBB Bitboard, dirs [4][2]int) Bitboard {
+	// walks each (rank, file) direction from sq until it leaves
+	// the board or hits an occupied square.
+	rank := int(sq / 8)
+	file := int(sq % 8)
+	var moves Bitboard = 0
+	for _, d := range dirs {
+		for r, f := rank+d[0], file+d[1]; r >= 0 && r < 8 && f >= 0 && f < 8; r, f = r+d[0], f+d[1] {
+			target := Bitboard(1) << uint(r*8+f)
+			moves |= target
+			if (fullBB & target) != 0 {
 				break
 			}
-			f--
 		}
 	}
 	return moves
@@ -589,49 +540,9 @@ func (b *Board) GetBishopMoves(color Color) Bitboard {
 }
 
 func GetRookMoves(sq Square, fullBB Bitboard) Bitboard {
-	// Creates a bitboard of every legal move that a rook
-	// of the coresponding color and square could make.
-	rank := sq / 8
-	file := sq % 8
-	var moves Bitboard = 0
-
-	// up
-	for r := rank + 1; r < 8; r++ {
-		target := r*8 + file
-		moves |= 1 << uint(target)
-		if (fullBB & (1 << uint(target))) != 0 {
-			break
-		}
-	}
-	// right
-	for f := file + 1; f < 8; f++ {
-		target := rank*8 + f
-		moves |= 1 << uint(target)
-		if (fullBB & (1 << uint(target))) != 0 {
-			break
-		}
-	}
-	// down
-	if rank != 0 {
-		for r := rank - 1; r >= 0; r-- {
-			target := r*8 + file
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
-		}
-	}
-	// left
-	if file != 0 {
-		for f := file - 1; f >= 0; f-- {
-			target := rank*8 + f
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
-		}
-	}
-	return moves
+	// Creates a bitboard of every square a rook on sq can
+	// reach, stopping at (and including) the first blocker.
+	return slide(sq, fullBB, [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}})
 }
 
 func (b *Board) GetRookMoves(color Color) Bitboard {
@@ -674,6 +585,10 @@ func GetKingMoves(sq Square, fullBB Bitboard, color Color, RKR [3]bool, opBB Bit
 }
 
 func GetCastles(color Color, fullBB Bitboard, RKR [3]bool, opBB Bitboard) Bitboard {
+	// returns the king destination squares of every castle still
+	// available. the king may not be in check, pass through or land
+	// on an attacked square (opBB) and the squares between king and
+	// rook must be empty.
 	var moves Bitboard
 	var rank Bitboard
 	if color == White {
@@ -681,27 +596,14 @@ func GetCastles(color Color, fullBB Bitboard, RKR [3]bool, opBB Bitboard) Bitboa
 	} else {
 		rank = Rank8
 	}
-	kingside := []Bitboard{FileF, FileG}
-	queenside := []Bitboard{FileD, FileC, FileB}
-	if !RKR[1] {
-		if !RKR[0] {
-			for i := range queenside {
-				if (queenside[i]&rank&fullBB) != 0 || (queenside[i]&rank&opBB != 0) {
-					break
-				} else {
-					moves |= FileC & rank
-				}
-			}
-		}
-		if !RKR[2] {
-			for i := range kingside {
-				if (kingside[i]&rank&fullBB) != 0 || (kingside[i]&rank&opBB != 0) {
-					break
-				} else {
-					moves |= FileG & rank
-				}
-			}
-		}
+	if RKR[1] || (FileE&rank&opBB) != 0 {
+		return 0
+	}
+	if !RKR[0] && ((FileB|FileC|FileD)&rank&fullBB) == 0 && ((FileC|FileD)&rank&opBB) == 0 {
+		moves |= FileC & rank
+	}
+	if !RKR[2] && ((FileF|FileG)&rank&fullBB) == 0 && ((FileF|FileG)&rank&opBB) == 0 {
+		moves |= FileG & rank
 	}
 	return moves
 }
@@ -715,97 +617,6 @@ func (b *Board) GetKingMoves(color Color) Bitboard {
 	return moves
 }
 
-func (board *Board) ToTensor() [8][8][19]float32 {
-  // converts board to tensor to be used as input for CNN.
-  // not yet fully implemented
-
-	var tensor [8][8][19]float32
-
-	for row := 0; row < 8; row++ {
-		for col := 0; col < 8; col++ {
-			square := Square(row*8 + col)
-			color := board.Turn
-			piece := board.GetPieceAt(square, color)
-
-			if color == White {
-				switch piece {
-				case Pawns:
-					tensor[row][col][0] = 1.0
-				case Knights:
-					tensor[row][col][1] = 1.0
-				case Bishops:
-					tensor[row][col][2] = 1.0
-				case Rooks:
-					tensor[row][col][3] = 1.0
-				case Queens:
-					tensor[row][col][4] = 1.0
-				case Kings:
-					tensor[row][col][5] = 1.0
-				}
-			} else if color == Black {
-				switch piece {
-				case Pawns:
-					tensor[row][col][6] = 1.0
-				case Knights:
-					tensor[row][col][7] = 1.0
-				case Bishops:
-					tensor[row][col][8] = 1.0
-				case Rooks:
-					tensor[row][col][9] = 1.0
-				case Queens:
-					tensor[row][col][10] = 1.0
-				case Kings:
-					tensor[row][col][11] = 1.0
-				}
-			}
-
-			// Channel 12: Side to move (1 if white, 0 if black)
-			switch color {
-			case White:
-				tensor[row][col][12] = 1.0
-			case Black:
-				tensor[row][col][12] = 0.0
-			}
-			// Channel 13-16: Castling rights
-			opBB := board.AllAttacks(color.Other())
-			whiteCastles := GetCastles(color, board.FullBB, board.RKRmoved[White], opBB)
-			blackCastles := GetCastles(color, board.FullBB, board.RKRmoved[Black], opBB)
-			if whiteCastles&FileG != 0 {
-				tensor[row][col][13] = 1.0
-			} else {
-				tensor[row][col][13] = 1.0
-			}
-			if whiteCastles&FileC != 0 {
-				tensor[row][col][14] = 1.0
-			} else {
-				tensor[row][col][14] = 1.0
-			}
-			if blackCastles&FileG != 0 {
-				tensor[row][col][15] = 1.0
-			} else {
-				tensor[row][col][15] = 1.0
-			}
-			if blackCastles&FileC != 0 {
-				tensor[row][col][16] = 1.0
-			} else {
-				tensor[row][col][16] = 1.0
-			}
-
-			// Channel 17: En passant square
-			if board.EnPassantSquare != nil &&
-				board.EnPassantSquare.GetRank() == Square(row).GetRank() &&
-				board.EnPassantSquare.GetFile() == Square(col).GetFile() {
-				tensor[row][col][17] = 1.0
-			}
-
-			// Channel 18: Move counter (normalized)
-			tensor[row][col][18] = float32(board.MoveCounter) / 100.0
-		}
-	}
-
-	return tensor
-}
-
 func (board *Board) ExportTensorAsJSON() []byte {
   // exports Tensor for model
 	tensor := board.ToTensor()
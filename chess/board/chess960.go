@@ -0,0 +1,50 @@
+package chess
+
+import "strings"
+
+// the knights' squares among the five left, by Scharnagl's code 0-9
+var knightPlacements = [10][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}
+
+func Chess960FEN(n int) string {
+	// returns the FEN of Chess960 start position n, 0-959, numbered as
+	// Scharnagl does: 518 is the standard start
+	var rank [8]byte
+	rank[2*(n%4)+1] = 'B'
+	n /= 4
+	rank[2*(n%4)] = 'B'
+	n /= 4
+	free := func(i int) int {
+		// the index of the i-th empty square
+		for f := range rank {
+			if rank[f] == 0 {
+				if i == 0 {
+					return f
+				}
+				i--
+			}
+		}
+		return -1
+	}
+	rank[free(n%6)] = 'Q'
+	n /= 6
+	knights := knightPlacements[n]
+	rank[free(knights[1])] = 'N'
+	rank[free(knights[0])] = 'N'
+	for _, p := range []byte("RKR") {
+		rank[free(0)] = p
+	}
+
+	white := string(rank[:])
+	return strings.ToLower(white) + "/pppppppp/8/8/8/8/PPPPPPPP/" + white + " w KQkq - 0 1"
+}
+
+func NewChess960Board(n int) *Board {
+	// initializes a board with Chess960 start position n, which is a
+	// Chess960 game even when n is 518
+	b, err := NewBoardFromFEN(Chess960FEN(n))
+	if err != nil {
+		panic(err)
+	}
+	b.Chess960 = true
+	return b
+}
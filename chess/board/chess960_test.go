@@ -0,0 +1,104 @@
+package chess
+
+import "testing"
+
+func TestChess960FEN(t *testing.T) {
+	// Scharnagl's numbering, with the standard start at 518
+	for _, c := range []struct {
+		n   int
+		fen string
+	}{
+		{0, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1"},
+		{518, StartFEN},
+		{959, "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w KQkq - 0 1"},
+	} {
+		if fen := Chess960FEN(c.n); fen != c.fen {
+			t.Errorf("position %d: %s, expected %s", c.n, fen, c.fen)
+		}
+	}
+
+	// every position has the bishops on opposite colours and the king
+	// between the rooks, and no two are the same
+	seen := map[string]bool{}
+	for n := range 960 {
+		fen := Chess960FEN(n)
+		if seen[fen] {
+			t.Errorf("position %d repeats an earlier one", n)
+		}
+		seen[fen] = true
+		b := NewChess960Board(n)
+		bishops := b.PieceBB[White][Bishops]
+		if bishops&0x55 == 0 || bishops&0xaa == 0 {
+			t.Errorf("position %d: bishops on the same colour", n)
+		}
+		king, rooks := b.KingSquare(White), b.PieceBB[White][Rooks]
+		if rooks&(Bitboard(1)<<king-1) == 0 || rooks>>king == 0 {
+			t.Errorf("position %d: king not between the rooks", n)
+		}
+	}
+}
+
+func TestChess960Perft(t *testing.T) {
+	// counts from the Chess960 perft positions of the chessprogramming
+	// wiki, castling rights in Shredder-FEN
+	for _, c := range []struct {
+		fen   string
+		depth int
+		nodes int
+	}{
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", 4, 326672},
+		{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", 3, 18002},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", 3, 10471},
+		{"1rqbkrbn/1ppppp1p/1n6/p1N3p1/8/2P4P/PP1PPPP1/1RQBKRBN w FBfb - 0 9", 4, 287739},
+		{"rbbqn1kr/pp2p1pp/6n1/2pp1p2/2P4P/P7/BP1PPPP1/R1BQNNKR w HAha - 0 9", 3, 25798},
+	} {
+		b, err := NewBoardFromFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !b.Chess960 {
+			t.Errorf("%s: not read as Chess960", c.fen)
+		}
+		if n := perft(b, c.depth); n != c.nodes {
+			t.Errorf("%s depth %d: %d nodes, expected %d", c.fen, c.depth, n, c.nodes)
+		}
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	// the king takes its own rook, landing on g1 or c1 with the rook
+	// beside it, written O-O and O-O-O and in the FEN as the files
+	b, err := NewBoardFromFEN("1r2k1r1/1p4p1/8/8/8/8/8/1R2K1R1 w GBgb - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.CastlingString(); got != "KQkq" {
+		t.Errorf("rights written %s, expected KQkq", got)
+	}
+	for _, c := range []struct{ san, uci, fen string }{
+		{"O-O", "e1g1", "1r2k1r1/1p4p1/8/8/8/8/8/1R3RK1 b kq - 1 1"},
+		{"O-O-O", "e1b1", "1r2k1r1/1p4p1/8/8/8/8/8/2KR2R1 b kq - 1 1"},
+	} {
+		m, err := b.ParseSAN(c.san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.String() != c.uci || b.SAN(m) != c.san {
+			t.Errorf("%s read as %v, written %s", c.san, m, b.SAN(m))
+		}
+		next := *b
+		next.MakeMove(m)
+		if fen := next.ToFEN(); fen != c.fen {
+			t.Errorf("after %s: %s, expected %s", c.san, fen, c.fen)
+		}
+	}
+
+	// with a second rook further out the castling rook is named by file
+	b, err = NewBoardFromFEN("4k3/8/8/8/8/8/8/R1R1K2R w CK - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.CastlingString(); got != "KC" {
+		t.Errorf("rights written %s, expected KC", got)
+	}
+}
@@ -0,0 +1,223 @@
+package chess
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var symbolToPiece = map[rune]Piece{
+	'p': Pawns, 'n': Knights, 'b': Bishops, 'r': Rooks, 'q': Queens, 'k': Kings,
+}
+
+func NewBoardFromFEN(fen string) (*Board, error) {
+	// builds a board from a FEN string. the halfmove clock and
+	// fullmove number are optional.
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid FEN %q: expected at least 4 fields", fen)
+	}
+	b := &Board{}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("invalid FEN %q: expected 8 ranks", fen)
+	}
+	for i, row := range ranks {
+		rank := 7 - i
+		file := 0
+		for _, r := range row {
+			if r >= '1' && r <= '8' {
+				file += int(r - '0')
+				continue
+			}
+			color := White
+			if r >= 'a' && r <= 'z' {
+				color = Black
+				r -= 32
+			}
+			piece, ok := symbolToPiece[r+32]
+			if !ok || file > 7 {
+				return nil, fmt.Errorf("invalid FEN %q: bad rank %q", fen, row)
+			}
+			b.PieceBB[color][piece].SetBit(Square(rank*8 + file))
+			file++
+		}
+		if file != 8 {
+			return nil, fmt.Errorf("invalid FEN %q: bad rank %q", fen, row)
+		}
+	}
+	b.CombineBB()
+	for c := White; c <= Black; c++ {
+		if b.PieceBB[c][Kings].Count() != 1 {
+			return nil, fmt.Errorf("invalid FEN %q: each side needs one king", fen)
+		}
+	}
+
+	switch fields[1] {
+	case "w":
+		b.Turn = White
+	case "b":
+		b.Turn = Black
+	default:
+		return nil, fmt.Errorf("invalid FEN %q: bad side to move", fen)
+	}
+
+	// every right starts as used and is given back by the castling field:
+	// KQkq for the outermost rook on either side of the king or, as in
+	// Shredder-FEN, the rook's file. a king or rook off its standard
+	// square makes the game Chess960.
+	b.RKRmoved = [2][3]bool{{true, true, true}, {true, true, true}}
+	b.RookFiles = [2][2]int{{0, 7}, {0, 7}}
+	if fields[2] != "-" {
+		for _, r := range fields[2] {
+			color := White
+			if r >= 'a' && r <= 'z' {
+				color = Black
+				r -= 32
+			}
+			king := b.KingSquare(color)
+			home := Square(56 * int(color))
+			file := -1
+			switch {
+			case r == 'K':
+				for f := 7; f > int(king-home) && file < 0; f-- {
+					if b.PieceBB[color][Rooks].GetBit(home + Square(f)) {
+						file = f
+					}
+				}
+			case r == 'Q':
+				for f := 0; f < int(king-home) && file < 0; f++ {
+					if b.PieceBB[color][Rooks].GetBit(home + Square(f)) {
+						file = f
+					}
+				}
+			case r >= 'A' && r <= 'H':
+				file = int(r - 'A')
+			default:
+				return nil, fmt.Errorf("invalid FEN %q: bad castling rights", fen)
+			}
+			// a right without its king or rook at home is ignored
+			if king&^7 != home || file < 0 || !b.PieceBB[color][Rooks].GetBit(home+Square(file)) {
+				continue
+			}
+			side := 0
+			if file > int(king-home) {
+				side = 1
+			}
+			b.RKRmoved[color][1], b.RKRmoved[color][2*side] = false, false
+			b.RookFiles[color][side] = file
+			if king-home != 4 || file != 7*side {
+				b.Chess960 = true
+			}
+		}
+	}
+
+	if fields[3] != "-" {
+		sq, ok := NotationToIndex[fields[3]]
+		if !ok {
+			return nil, fmt.Errorf("invalid FEN %q: bad en passant square", fen)
+		}
+		b.EnPassantSquare = &sq
+	}
+
+	fullMoves := 1
+	if len(fields) >= 6 {
+		halfMoves, err := strconv.Atoi(fields[4])
+		if err != nil || halfMoves < 0 || halfMoves > 255 {
+			return nil, fmt.Errorf("invalid FEN %q: bad halfmove clock", fen)
+		}
+		b.HalfMoveClock = uint8(halfMoves)
+		fullMoves, err = strconv.Atoi(fields[5])
+		if err != nil || fullMoves < 1 {
+			return nil, fmt.Errorf("invalid FEN %q: bad fullmove number", fen)
+		}
+	}
+	b.MoveCounter = uint16((fullMoves-1)*2 + int(b.Turn))
+	return b, nil
+}
+
+func (b *Board) ToFEN() string {
+	// writes the position as a FEN string
+	var sb strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := range 8 {
+			sq := Square(rank*8 + file)
+			color := White
+			piece := b.GetPieceAt(sq, White)
+			if piece == Empty {
+				color = Black
+				piece = b.GetPieceAt(sq, Black)
+			}
+			if piece == Empty {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteRune(getSymbol(color, piece))
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+	}
+
+	if b.Turn == White {
+		sb.WriteString(" w ")
+	} else {
+		sb.WriteString(" b ")
+	}
+	sb.WriteString(b.CastlingString())
+
+	if b.EnPassantSquare != nil {
+		sb.WriteString(" " + b.EnPassantSquare.String())
+	} else {
+		sb.WriteString(" -")
+	}
+	fmt.Fprintf(&sb, " %d %d", b.HalfMoveClock, b.MoveCounter/2+1)
+	return sb.String()
+}
+
+func (b *Board) CastlingString() string {
+	// returns the castling rights in FEN form, eg KQkq or -. a Chess960
+	// rook with another further out on its side is named by its file.
+	s := ""
+	rights := []struct {
+		color Color
+		side  int
+		sym   byte
+	}{{White, 1, 'K'}, {White, 0, 'Q'}, {Black, 1, 'k'}, {Black, 0, 'q'}}
+	for _, r := range rights {
+		if b.RKRmoved[r.color][1] || b.RKRmoved[r.color][2*r.side] {
+			continue
+		}
+		sym := r.sym
+		if b.Chess960 {
+			home := Square(56 * int(r.color))
+			file := b.RookFiles[r.color][r.side]
+			for f := file + 2*r.side - 1; f >= 0 && f < 8; f += 2*r.side - 1 {
+				if b.PieceBB[r.color][Rooks].GetBit(home + Square(f)) {
+					sym = byte('A'+file) | r.sym&32
+				}
+			}
+		}
+		s += string(sym)
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (bb Bitboard) Count() int {
+	return bits.OnesCount64(uint64(bb))
+}
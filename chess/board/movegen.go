@@ -0,0 +1,367 @@
+package chess
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+type Move struct {
+	Piece     Piece
+	From      Square
+	To        Square
+	Promotion Piece
+}
+
+// NullMove is the zero Move, used where no move is available.
+var NullMove = Move{}
+
+var (
+	allKingMoves = genAllKingMoves()
+	promotions   = [4]Piece{Queens, Rooks, Bishops, Knights}
+)
+var AllKingMoves = allKingMoves
+
+func (sq Square) String() string {
+	return string([]byte{'a' + byte(sq%8), '1' + byte(sq/8)})
+}
+
+func (m Move) String() string {
+	// returns the move in UCI long algebraic notation, eg e2e4 or e7e8q
+	if m == NullMove {
+		return "0000"
+	}
+	s := m.From.String() + m.To.String()
+	if m.Promotion != Empty {
+		s += string(getSymbol(Black, m.Promotion))
+	}
+	return s
+}
+
+func genAllKingMoves() [64]Bitboard {
+	// Generates the squares a king on each square could step to
+	// on an empty board (castling excluded).
+	var bbs [64]Bitboard
+	for sq := Square(0); sq < 64; sq++ {
+		king := Bitboard(1) << sq
+		bbs[sq] = (king << 8) |
+			(king >> 8) |
+			((king << 1) & ^FileA) |
+			((king >> 1) & ^FileH) |
+			((king << 9) & ^FileA) |
+			((king << 7) & ^FileH) |
+			((king >> 7) & ^FileA) |
+			((king >> 9) & ^FileH)
+	}
+	return bbs
+}
+
+func PawnAttacks(sq Square, color Color) Bitboard {
+	// returns the squares a pawn of color on sq attacks
+	pawn := Bitboard(1) << sq
+	if color == White {
+		return ((pawn << 7) & ^FileH) | ((pawn << 9) & ^FileA)
+	}
+	return ((pawn >> 7) & ^FileA) | ((pawn >> 9) & ^FileH)
+}
+
+func (b *Board) Attackers(sq Square, color Color, occupied Bitboard) Bitboard {
+	// returns every piece of color attacking sq given the occupancy
+	pieces := b.PieceBB[color]
+	attackers := PawnAttacks(sq, color.Other()) & pieces[Pawns]
+	attackers |= allKnightMoves[sq] & pieces[Knights]
+	attackers |= allKingMoves[sq] & pieces[Kings]
+	attackers |= GetBishopMoves(sq, occupied) & (pieces[Bishops] | pieces[Queens])
+	attackers |= GetRookMoves(sq, occupied) & (pieces[Rooks] | pieces[Queens])
+	return attackers & occupied
+}
+
+func (b *Board) IsAttacked(sq Square, color Color) bool {
+	// checks if any piece of color attacks sq
+	return b.Attackers(sq, color, b.FullBB) != 0
+}
+
+func (b *Board) AttackMap(color Color) Bitboard {
+	// creates a bitboard of every square attacked by color,
+	// whether it is empty or occupied by either side.
+	var attacks Bitboard
+	pawns := b.PieceBB[color][Pawns]
+	if color == White {
+		attacks = ((pawns << 7) & ^FileH) | ((pawns << 9) & ^FileA)
+	} else {
+		attacks = ((pawns >> 7) & ^FileA) | ((pawns >> 9) & ^FileH)
+	}
+	for p := Knights; p <= Kings; p++ {
+		bb := b.PieceBB[color][p]
+		for bb != 0 {
+			sq := Square(bits.TrailingZeros64(uint64(bb)))
+			bb &= bb - 1
+			attacks |= PieceAttacks(p, sq, b.FullBB)
+		}
+	}
+	return attacks
+}
+
+func PieceAttacks(p Piece, sq Square, fullBB Bitboard) Bitboard {
+	// returns the squares a non-pawn piece on sq attacks
+	switch p {
+	case Knights:
+		return allKnightMoves[sq]
+	case Bishops:
+		return GetBishopMoves(sq, fullBB)
+	case Rooks:
+		return GetRookMoves(sq, fullBB)
+	case Queens:
+		return GetQueenMoves(sq, fullBB)
+	case Kings:
+		return allKingMoves[sq]
+	}
+	return 0
+}
+
+func (b *Board) KingSquare(color Color) Square {
+	return Square(bits.TrailingZeros64(uint64(b.PieceBB[color][Kings])))
+}
+
+func (b *Board) InCheck() bool {
+	// checks if the side to move is in check
+	return b.IsAttacked(b.KingSquare(b.Turn), b.Turn.Other())
+}
+
+func (b *Board) IsCapture(m Move) bool {
+	if b.ColorBB[b.Turn.Other()].GetBit(m.To) {
+		return true
+	}
+	return m.Piece == Pawns && b.EnPassantSquare != nil && m.To == *b.EnPassantSquare
+}
+
+func (b *Board) PseudoLegalMoves(capturesOnly bool) []Move {
+	// generates every move for the side to move without checking
+	// whether it leaves the king in check. with capturesOnly only
+	// captures and queen promotions are returned.
+	color := b.Turn
+	own := b.ColorBB[color]
+	enemy := b.ColorBB[color.Other()]
+	moves := make([]Move, 0, 48)
+	targets := ^own
+	if capturesOnly {
+		targets = enemy
+	}
+
+	// pawns
+	pawns := b.PieceBB[color][Pawns]
+	lastRank := Rank8
+	if color == Black {
+		lastRank = Rank1
+	}
+	var epBB Bitboard
+	if b.EnPassantSquare != nil {
+		epBB = Bitboard(1) << *b.EnPassantSquare
+	}
+	for pawns != 0 {
+		from := Square(bits.TrailingZeros64(uint64(pawns)))
+		pawns &= pawns - 1
+		dest := GetPawnMoves(from, b.FullBB, color, enemy|epBB)
+		if capturesOnly {
+			dest &= enemy | epBB | lastRank
+		}
+		for dest != 0 {
+			to := Square(bits.TrailingZeros64(uint64(dest)))
+			dest &= dest - 1
+			if (Bitboard(1)<<to)&lastRank != 0 {
+				for _, promo := range promotions {
+					if capturesOnly && promo != Queens {
+						continue
+					}
+					moves = append(moves, Move{Pawns, from, to, promo})
+				}
+			} else {
+				moves = append(moves, Move{Pawns, from, to, Empty})
+			}
+		}
+	}
+
+	// pieces
+	for p := Knights; p <= Kings; p++ {
+		bb := b.PieceBB[color][p]
+		for bb != 0 {
+			from := Square(bits.TrailingZeros64(uint64(bb)))
+			bb &= bb - 1
+			dest := PieceAttacks(p, from, b.FullBB) & targets
+			for dest != 0 {
+				to := Square(bits.TrailingZeros64(uint64(dest)))
+				dest &= dest - 1
+				moves = append(moves, Move{p, from, to, Empty})
+			}
+		}
+	}
+
+	// castles
+	if !capturesOnly && !b.RKRmoved[color][1] && (!b.RKRmoved[color][0] || !b.RKRmoved[color][2]) {
+		from := b.KingSquare(color)
+		var castles Bitboard
+		if b.Chess960 {
+			castles = b.chess960Castles(color)
+		} else {
+			castles = GetCastles(color, b.FullBB, b.RKRmoved[color], b.AttackMap(color.Other()))
+		}
+		for castles != 0 {
+			to := Square(bits.TrailingZeros64(uint64(castles)))
+			castles &= castles - 1
+			moves = append(moves, Move{Kings, from, to, Empty})
+		}
+	}
+	return moves
+}
+
+func (b *Board) chess960Castles(color Color) Bitboard {
+	// returns the squares of the rooks the king can castle with. every
+	// square the king or rook crosses or lands on must be empty but for
+	// the two of them, and the king's path, both ends included, can't
+	// be attacked.
+	king := b.KingSquare(color)
+	base := king &^ 7
+	var attacked Bitboard
+	var castles Bitboard
+	for side := range 2 {
+		if b.RKRmoved[color][2*side] {
+			continue
+		}
+		rook := base + Square(b.RookFiles[color][side])
+		if !b.PieceBB[color][Rooks].GetBit(rook) {
+			continue
+		}
+		kingTo, rookTo := base+2, base+3
+		if side == 1 {
+			kingTo, rookTo = base+6, base+5
+		}
+		occupied := b.FullBB &^ (Bitboard(1)<<king | Bitboard(1)<<rook)
+		if between(king, kingTo)&occupied != 0 || between(rook, rookTo)&occupied != 0 {
+			continue
+		}
+		if attacked == 0 {
+			attacked = b.AttackMap(color.Other())
+		}
+		if between(king, kingTo)&attacked != 0 {
+			continue
+		}
+		castles.SetBit(rook)
+	}
+	return castles
+}
+
+func between(a, b Square) Bitboard {
+	// the squares from a to b on a rank, both included
+	if a > b {
+		a, b = b, a
+	}
+	return (Bitboard(1)<<(b+1) - 1) &^ (Bitboard(1)<<a - 1)
+}
+
+func (b *Board) IsCastle(m Move) bool {
+	// reports whether m castles: a king moving two squares or, in
+	// Chess960, onto its own rook
+	if m.Piece != Kings {
+		return false
+	}
+	if b.Chess960 {
+		return b.PieceBB[b.Turn][Rooks].GetBit(m.To)
+	}
+	return m.To-m.From == 2 || m.From-m.To == 2
+}
+
+func (b *Board) LegalMoves() []Move {
+	// generates every legal move for the side to move
+	moves := b.PseudoLegalMoves(false)
+	legal := moves[:0]
+	for _, m := range moves {
+		if b.IsLegalMove(m) {
+			legal = append(legal, m)
+		}
+	}
+	return legal
+}
+
+func (b *Board) IsLegalMove(m Move) bool {
+	// checks that a pseudo legal move doesn't leave the own king in check
+	next := *b
+	next.MovePiece(m.Piece, m.From, m.To, m.Promotion)
+	return !next.IsAttacked(next.KingSquare(b.Turn), b.Turn.Other())
+}
+
+func (b *Board) HasLegalMoves() bool {
+	for _, m := range b.PseudoLegalMoves(false) {
+		if b.IsLegalMove(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Board) MakeMove(m Move) bool {
+	// plays the move and passes the turn to the other side.
+	// returns true if a piece was captured
+	capture := b.MovePiece(m.Piece, m.From, m.To, m.Promotion)
+	b.Turn = b.Turn.Other()
+	return capture
+}
+
+func (b *Board) MakeNullMove() {
+	// passes the turn without moving, used by the search
+	b.EnPassantSquare = nil
+	b.Turn = b.Turn.Other()
+}
+
+func (b *Board) ParseMove(s string) (Move, error) {
+	// parses a move in UCI notation (e2e4, e7e8q) and checks it is legal
+	if len(s) != 4 && len(s) != 5 {
+		return NullMove, fmt.Errorf("invalid move %q", s)
+	}
+	from, ok := NotationToIndex[s[0:2]]
+	to, ok2 := NotationToIndex[s[2:4]]
+	if !ok || !ok2 {
+		return NullMove, fmt.Errorf("invalid move %q", s)
+	}
+	promotion := Empty
+	if len(s) == 5 {
+		switch s[4] {
+		case 'q', 'Q':
+			promotion = Queens
+		case 'r', 'R':
+			promotion = Rooks
+		case 'b', 'B':
+			promotion = Bishops
+		case 'n', 'N':
+			promotion = Knights
+		default:
+			return NullMove, fmt.Errorf("invalid promotion in %q", s)
+		}
+	}
+	piece, promotion := b.IsLegal(from, to, promotion)
+	if piece == Empty {
+		return NullMove, fmt.Errorf("illegal move %q", s)
+	}
+	return Move{piece, from, to, promotion}, nil
+}
+
+// the squares of a1's colour
+const darkSquares Bitboard = 0xAA55AA55AA55AA55
+
+func (b *Board) InsufficientMaterial() bool {
+	// checks if neither side can possibly mate: bare kings, a
+	// single minor piece, or bishops that all stand on one colour.
+	for c := White; c <= Black; c++ {
+		if b.PieceBB[c][Pawns]|b.PieceBB[c][Rooks]|b.PieceBB[c][Queens] != 0 {
+			return false
+		}
+	}
+	knights := b.PieceBB[White][Knights] | b.PieceBB[Black][Knights]
+	bishops := b.PieceBB[White][Bishops] | b.PieceBB[Black][Bishops]
+	minors := bits.OnesCount64(uint64(knights | bishops))
+	if minors <= 1 {
+		return true
+	}
+	if knights == 0 && (bishops&darkSquares == 0 || bishops & ^darkSquares == 0) {
+		return true
+	}
+	return false
+}
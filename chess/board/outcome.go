@@ -0,0 +1,75 @@
+package chess
+
+// game results as written in PGN
+const (
+	Unfinished = "*"
+	WhiteWon   = "1-0"
+	BlackWon   = "0-1"
+	Drawn      = "1/2-1/2"
+)
+
+type Outcome struct {
+	Result string // one of the results above
+	Reason string // how the game ended, empty while it goes on
+}
+
+func (o Outcome) Over() bool {
+	return o.Result != Unfinished
+}
+
+func (b *Board) Outcome(history []uint64) Outcome {
+	// checks if the game has ended in b. history holds the hashes of
+	// the earlier positions of the game so repetitions can be seen.
+	if !b.HasLegalMoves() {
+		if !b.InCheck() {
+			return Outcome{Drawn, "stalemate"}
+		}
+		if b.Turn == White {
+			return Outcome{BlackWon, "checkmate"}
+		}
+		return Outcome{WhiteWon, "checkmate"}
+	}
+	if b.InsufficientMaterial() {
+		return Outcome{Drawn, "insufficient material"}
+	}
+	if b.HalfMoveClock >= 100 {
+		return Outcome{Drawn, "fifty move rule"}
+	}
+	hash := b.Hash()
+	seen := 1
+	for i := len(history) - 2; i >= 0 && i >= len(history)-int(b.HalfMoveClock); i -= 2 {
+		if history[i] == hash {
+			seen++
+		}
+	}
+	if seen >= 3 {
+		return Outcome{Drawn, "threefold repetition"}
+	}
+	return Outcome{Result: Unfinished}
+}
+
+func (b *Board) HasMatingMaterial(c Color) bool {
+	// checks if c could mate by any sequence of legal moves, the other
+	// side's pieces helping, as used when the other side runs out of
+	// time. a lone king can't, nor a king and knight against queens that
+	// can always take or block it, nor bishops all on one colour with no
+	// pawns or knights to stand on the squares they miss.
+	own, them := b.PieceBB[c], b.PieceBB[c.Other()]
+	if own[Pawns]|own[Rooks]|own[Queens] != 0 {
+		return true
+	}
+	if own[Knights] != 0 {
+		if (own[Knights] | own[Bishops]).Count() > 1 {
+			return true
+		}
+		return them[Pawns]|them[Knights]|them[Bishops]|them[Rooks] != 0
+	}
+	if own[Bishops] == 0 {
+		return false
+	}
+	bishops := own[Bishops] | them[Bishops]
+	if bishops&darkSquares != 0 && bishops&^darkSquares != 0 {
+		return true
+	}
+	return them[Pawns]|them[Knights] != 0
+}
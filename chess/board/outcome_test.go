@@ -0,0 +1,35 @@
+package chess
+
+import "testing"
+
+func TestHasMatingMaterial(t *testing.T) {
+	// whether white could still mate, black having run out of time
+	for _, c := range []struct {
+		fen  string
+		mate bool
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 b - - 0 1", false},
+		{"4k3/8/8/8/8/8/8/4KN2 b - - 0 1", false},
+		{"4k3/pppp4/8/8/8/8/8/4KN2 b - - 0 1", true}, // the pawns can block their king in
+		{"3qk3/8/8/8/8/8/8/4KN2 b - - 0 1", false},   // the queen always takes or blocks
+		{"3rk3/8/8/8/8/8/8/4KN2 b - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/2B1K3 b - - 0 1", false},
+		{"4kb2/8/8/8/8/8/8/2B1K3 b - - 0 1", false}, // bishops on the same colour
+		{"2b1k3/8/8/8/8/8/8/2B1K3 b - - 0 1", true},
+		{"4kn2/8/8/8/8/8/8/2B1K3 b - - 0 1", true},
+		{"3rk3/8/8/8/8/8/8/2B1K3 b - - 0 1", false},
+		{"4k3/8/8/8/8/B7/8/2B1K3 b - - 0 1", false},
+		{"4k3/8/8/8/8/8/8/2B1KB2 b - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/1NB1K3 b - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/R3K3 b - - 0 1", true},
+		{"4k3/8/8/8/8/8/P7/4K3 b - - 0 1", true},
+	} {
+		b, err := NewBoardFromFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := b.HasMatingMaterial(White); got != c.mate {
+			t.Errorf("%s: %v, expected %v", c.fen, got, c.mate)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package chess
+
+import "testing"
+
+func perft(b *Board, depth int) int {
+	// counts the leaves of the legal move tree depth plies deep
+	if depth == 0 {
+		return 1
+	}
+	moves := b.LegalMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+	n := 0
+	for _, m := range moves {
+		next := *b
+		next.MakeMove(m)
+		n += perft(&next, depth-1)
+	}
+	return n
+}
+
+func TestPerft(t *testing.T) {
+	// the counts of the chessprogramming wiki's perft positions. the
+	// deepest ones are left out with -short.
+	for _, c := range []struct {
+		name  string
+		fen   string
+		depth int
+		nodes int
+		long  bool
+	}{
+		{"startpos", StartFEN, 4, 197281, false},
+		{"startpos", StartFEN, 5, 4865609, true},
+		{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3, 97862, false},
+		{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 4, 4085603, true},
+		{"position 3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 5, 674624, false},
+		{"position 4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 4, 422333, false},
+		{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 3, 62379, false},
+		{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 4, 2103487, true},
+		{"position 6", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", 3, 89890, false},
+	} {
+		if c.long && testing.Short() {
+			continue
+		}
+		b, err := NewBoardFromFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := perft(b, c.depth); n != c.nodes {
+			t.Errorf("%s depth %d: %d nodes, expected %d", c.name, c.depth, n, c.nodes)
+		}
+	}
+}
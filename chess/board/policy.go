@@ -0,0 +1,131 @@
+package chess
+
+import "fmt"
+
+// the policy head of the network has an output for every move a piece
+// could make on an empty board, in UCI notation: for each from square,
+// a file at a time (a1 a2 ... a8 b1 ... h8), the squares reached along
+// its rank, its file, both diagonals and by a knight jump, in that
+// order; then for each file the promotions, to q r b n, of pawns going
+// straight and taking left and right, black's and white's in turn. it
+// is the labelling of chess-alpha-zero's create_uci_labels, 1792 moves
+// and 176 promotions.
+const PolicySize = 1968
+
+type policyMove struct {
+	from, to  Square
+	promotion Piece
+}
+
+var (
+	policyMoves [PolicySize]policyMove
+	// the index of every from, to and promotion, -1 where there is none
+	policyIndex [64][64][7]int16
+)
+
+func init() {
+	for from := range policyIndex {
+		for to := range policyIndex[from] {
+			for p := range policyIndex[from][to] {
+				policyIndex[from][to][p] = -1
+			}
+		}
+	}
+	i := 0
+	add := func(from, to Square, promotion Piece) {
+		policyMoves[i] = policyMove{from, to, promotion}
+		policyIndex[from][to][promotion] = int16(i)
+		i++
+	}
+	square := func(file, rank int) Square { return Square(rank*8 + file) }
+	onBoard := func(file, rank int) bool { return file >= 0 && file < 8 && rank >= 0 && rank < 8 }
+
+	for file := 0; file < 8; file++ {
+		for rank := 0; rank < 8; rank++ {
+			var targets [][2]int
+			for t := 0; t < 8; t++ {
+				targets = append(targets, [2]int{t, rank})
+			}
+			for t := 0; t < 8; t++ {
+				targets = append(targets, [2]int{file, t})
+			}
+			for t := -7; t < 8; t++ {
+				targets = append(targets, [2]int{file + t, rank + t})
+			}
+			for t := -7; t < 8; t++ {
+				targets = append(targets, [2]int{file + t, rank - t})
+			}
+			for _, d := range [][2]int{{-2, -1}, {-1, -2}, {-2, 1}, {1, -2}, {2, -1}, {-1, 2}, {2, 1}, {1, 2}} {
+				targets = append(targets, [2]int{file + d[0], rank + d[1]})
+			}
+			for _, t := range targets {
+				if (t != [2]int{file, rank}) && onBoard(t[0], t[1]) {
+					add(square(file, rank), square(t[0], t[1]), Empty)
+				}
+			}
+		}
+	}
+	for file := 0; file < 8; file++ {
+		for _, p := range []Piece{Queens, Rooks, Bishops, Knights} {
+			add(square(file, 1), square(file, 0), p)
+			add(square(file, 6), square(file, 7), p)
+			if file > 0 {
+				add(square(file, 1), square(file-1, 0), p)
+				add(square(file, 6), square(file-1, 7), p)
+			}
+			if file < 7 {
+				add(square(file, 1), square(file+1, 0), p)
+				add(square(file, 6), square(file+1, 7), p)
+			}
+		}
+	}
+	if i != PolicySize {
+		panic(fmt.Sprintf("policy has %d moves, expected %d", i, PolicySize))
+	}
+}
+
+func flipSquare(sq Square) Square {
+	// the square with the same file on the mirrored rank
+	return sq ^ 56
+}
+
+func PolicyIndex(m Move, flip bool) (int, bool) {
+	// the output of the policy head for m. with flip the board is seen
+	// from black's side, ranks mirrored, as when black's moves are
+	// given to a network trained from the side to move.
+	from, to := m.From, m.To
+	if flip {
+		from, to = flipSquare(from), flipSquare(to)
+	}
+	i := policyIndex[from][to][m.Promotion]
+	return int(i), i >= 0
+}
+
+func PolicyLabel(index int, flip bool) string {
+	// the move of an output in UCI notation
+	pm := policyMoves[index]
+	from, to := pm.from, pm.to
+	if flip {
+		from, to = flipSquare(from), flipSquare(to)
+	}
+	return Move{From: from, To: to, Promotion: pm.promotion}.String()
+}
+
+func (b *Board) PolicyMove(index int, flip bool) (Move, error) {
+	// the legal move of b an output stands for
+	if index < 0 || index >= PolicySize {
+		return NullMove, fmt.Errorf("policy index %d out of range", index)
+	}
+	return b.ParseMove(PolicyLabel(index, flip))
+}
+
+func (b *Board) PolicyMask(flip bool) []bool {
+	// which outputs of the policy head are legal moves of b
+	mask := make([]bool, PolicySize)
+	for _, m := range b.LegalMoves() {
+		if i, ok := PolicyIndex(m, flip); ok {
+			mask[i] = true
+		}
+	}
+	return mask
+}
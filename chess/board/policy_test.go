@@ -0,0 +1,51 @@
+package chess
+
+import "testing"
+
+func TestPolicyRoundTrip(t *testing.T) {
+	// every legal move has an output of its own, seen from either
+	// side, and that output reads back as the move
+	for _, fen := range []string{
+		StartFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 b kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"8/8/8/8/8/8/1k4p1/4K2R b K - 0 1",
+	} {
+		b, err := NewBoardFromFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves := b.LegalMoves()
+		for _, flip := range []bool{false, true} {
+			seen := map[int]Move{}
+			for _, m := range moves {
+				i, ok := PolicyIndex(m, flip)
+				if !ok || i < 0 || i >= PolicySize {
+					t.Fatalf("%s flip %v: %v has no output (%d)", fen, flip, m, i)
+				}
+				if other, dup := seen[i]; dup {
+					t.Fatalf("%s flip %v: %v and %v share output %d", fen, flip, m, other, i)
+				}
+				seen[i] = m
+				back, err := b.PolicyMove(i, flip)
+				if err != nil || back != m {
+					t.Errorf("%s flip %v: output %d of %v reads back as %v (%v)", fen, flip, i, m, back, err)
+				}
+				if label := PolicyLabel(i, flip); label != m.String() {
+					t.Errorf("%s flip %v: output %d is labelled %s, expected %v", fen, flip, i, label, m)
+				}
+			}
+			set := 0
+			for _, legal := range b.PolicyMask(flip) {
+				if legal {
+					set++
+				}
+			}
+			if set != len(moves) {
+				t.Errorf("%s flip %v: %d outputs masked in for %d legal moves", fen, flip, set, len(moves))
+			}
+		}
+	}
+}
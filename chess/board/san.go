@@ -0,0 +1,148 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+var pieceLetters = map[Piece]byte{Knights: 'N', Bishops: 'B', Rooks: 'R', Queens: 'Q', Kings: 'K'}
+
+func (b *Board) SAN(m Move) string {
+	// writes a legal move in standard algebraic notation, eg Nbd2,
+	// exd5, e8=Q+, O-O or Qh7#
+	var sb strings.Builder
+	switch {
+	case b.IsCastle(m) && m.To > m.From:
+		sb.WriteString("O-O")
+	case b.IsCastle(m):
+		sb.WriteString("O-O-O")
+	default:
+		capture := b.IsCapture(m)
+		if m.Piece == Pawns {
+			if capture {
+				sb.WriteByte('a' + byte(m.From%8))
+			}
+		} else {
+			sb.WriteByte(pieceLetters[m.Piece])
+			// name the file, the rank or both when another piece of
+			// the same kind could also go there
+			sameFile, sameRank, ambiguous := false, false, false
+			for _, other := range b.LegalMoves() {
+				if other.Piece != m.Piece || other.To != m.To || other.From == m.From {
+					continue
+				}
+				ambiguous = true
+				sameFile = sameFile || other.From%8 == m.From%8
+				sameRank = sameRank || other.From/8 == m.From/8
+			}
+			if ambiguous {
+				if !sameFile {
+					sb.WriteByte('a' + byte(m.From%8))
+				} else if !sameRank {
+					sb.WriteByte('1' + byte(m.From/8))
+				} else {
+					sb.WriteString(m.From.String())
+				}
+			}
+		}
+		if capture {
+			sb.WriteByte('x')
+		}
+		sb.WriteString(m.To.String())
+		if m.Promotion != Empty {
+			sb.WriteByte('=')
+			sb.WriteByte(pieceLetters[m.Promotion])
+		}
+	}
+
+	next := *b
+	next.MakeMove(m)
+	if next.InCheck() {
+		if next.HasLegalMoves() {
+			sb.WriteByte('+')
+		} else {
+			sb.WriteByte('#')
+		}
+	}
+	return sb.String()
+}
+
+func (b *Board) ParseSAN(s string) (Move, error) {
+	// parses a move in standard algebraic notation. it is lenient
+	// about check marks, annotations, missing or extra capture signs
+	// and over-specified origins.
+	text := strings.TrimRight(s, "+#!?")
+	switch strings.ReplaceAll(text, "0", "O") {
+	case "O-O", "O-O-O":
+		for _, m := range b.LegalMoves() {
+			if !b.IsCastle(m) {
+				continue
+			}
+			if m.To > m.From && len(text) == 3 || m.To < m.From && len(text) == 5 {
+				return m, nil
+			}
+		}
+		return NullMove, fmt.Errorf("illegal move %q", s)
+	}
+
+	piece := Pawns
+	if len(text) > 0 && text[0] >= 'A' && text[0] <= 'Z' {
+		piece = letterPiece(text[0])
+		text = text[1:]
+	}
+	promotion := Empty
+	if before, after, found := strings.Cut(text, "="); found {
+		if len(after) != 1 {
+			return NullMove, fmt.Errorf("invalid move %q", s)
+		}
+		text, promotion = before, letterPiece(after[0])
+	} else if piece == Pawns && len(text) > 2 && letterPiece(text[len(text)-1]) != Empty {
+		// promotions written without the =, eg e8Q
+		promotion = letterPiece(text[len(text)-1])
+		text = text[:len(text)-1]
+	}
+	if len(text) < 2 {
+		return NullMove, fmt.Errorf("invalid move %q", s)
+	}
+	to, ok := NotationToIndex[text[len(text)-2:]]
+	if !ok {
+		return NullMove, fmt.Errorf("invalid move %q", s)
+	}
+	fromFile, fromRank := -1, -1
+	for _, c := range text[:len(text)-2] {
+		switch {
+		case c >= 'a' && c <= 'h':
+			fromFile = int(c - 'a')
+		case c >= '1' && c <= '8':
+			fromRank = int(c - '1')
+		case c == 'x' || c == '-' || c == ':':
+		default:
+			return NullMove, fmt.Errorf("invalid move %q", s)
+		}
+	}
+
+	found := NullMove
+	for _, m := range b.LegalMoves() {
+		if m.Piece != piece || m.To != to || m.Promotion != promotion ||
+			fromFile >= 0 && int(m.From%8) != fromFile || fromRank >= 0 && int(m.From/8) != fromRank {
+			continue
+		}
+		if found != NullMove {
+			return NullMove, fmt.Errorf("ambiguous move %q", s)
+		}
+		found = m
+	}
+	if found == NullMove {
+		return NullMove, fmt.Errorf("illegal move %q", s)
+	}
+	return found, nil
+}
+
+func letterPiece(c byte) Piece {
+	for p, letter := range pieceLetters {
+		if c == letter || c == letter+'a'-'A' {
+			return p
+		}
+	}
+	return Empty
+}
@@ -0,0 +1,137 @@
+package chess
+
+import "math/bits"
+
+// the planes of a position given to the network, each an 8x8 grid
+// indexed [row][col] with row 0 the first rank and col 0 the a-file:
+//
+//	0-5    white pawns, knights, bishops, rooks, queens, king
+//	6-11   black pawns, knights, bishops, rooks, queens, king
+//	12     side to move, 1 everywhere when white is to move
+//	13-16  castling rights: white kingside, white queenside, black
+//	       kingside, black queenside, 1 everywhere while the right holds
+//	17     the en passant square
+//	18     plies played divided by 100, everywhere
+//
+// with TensorOptions.Relative the side to move takes white's place:
+// its pieces and castling rights come first and for black the board is
+// mirrored so its pieces start on the low rows. plane 12 still tells
+// who is to move. history planes follow, 12 for each earlier position,
+// encoded like planes 0-11 from the same point of view.
+const (
+	PiecePlanes    = 12
+	PositionPlanes = 19
+)
+
+// the plane numbers of the layout above
+const (
+	SideToMovePlane = 12
+	CastlingPlane   = 13
+	EnPassantPlane  = 17
+	MoveCountPlane  = 18
+)
+
+type TensorOptions struct {
+	Relative bool // seen from the side to move
+	History  int  // earlier positions added, empty planes when unknown
+}
+
+type Plane [8][8]float32
+
+func (o TensorOptions) Planes() int {
+	return PositionPlanes + PiecePlanes*o.History
+}
+
+func (b *Board) Encode(opts TensorOptions, history []*Board) []Plane {
+	// the planes of b as laid out above. history holds the positions
+	// before b, the latest first; only the first opts.History are used.
+	planes := make([]Plane, opts.Planes())
+	us := White
+	if opts.Relative {
+		us = b.Turn
+	}
+	mirror := us == Black
+	b.encodePieces(planes[:PiecePlanes], us, mirror)
+	for i := 0; i < opts.History && i < len(history); i++ {
+		start := PositionPlanes + i*PiecePlanes
+		history[i].encodePieces(planes[start:start+PiecePlanes], us, mirror)
+	}
+
+	if b.Turn == White {
+		planes[SideToMovePlane].fill(1)
+	}
+	for i, c := range []Color{us, us.Other()} {
+		// the king with either rook unmoved keeps that side's right
+		if !b.RKRmoved[c][1] && !b.RKRmoved[c][2] {
+			planes[CastlingPlane+2*i].fill(1)
+		}
+		if !b.RKRmoved[c][1] && !b.RKRmoved[c][0] {
+			planes[CastlingPlane+2*i+1].fill(1)
+		}
+	}
+	if b.EnPassantSquare != nil {
+		row, col := squareCell(*b.EnPassantSquare, mirror)
+		planes[EnPassantPlane][row][col] = 1
+	}
+	planes[MoveCountPlane].fill(float32(b.MoveCounter) / 100)
+	return planes
+}
+
+func (b *Board) encodePieces(planes []Plane, us Color, mirror bool) {
+	// marks the pieces of us in planes 0-5 and the others' in 6-11
+	for i, c := range []Color{us, us.Other()} {
+		for p := Pawns; p <= Kings; p++ {
+			bb := b.PieceBB[c][p]
+			for bb != 0 {
+				sq := Square(bits.TrailingZeros64(uint64(bb)))
+				row, col := squareCell(sq, mirror)
+				planes[6*i+int(p)-1][row][col] = 1
+				bb &= bb - 1
+			}
+		}
+	}
+}
+
+func squareCell(sq Square, mirror bool) (int, int) {
+	row, col := int(sq/8), int(sq%8)
+	if mirror {
+		row = 7 - row
+	}
+	return row, col
+}
+
+func (p *Plane) fill(v float32) {
+	for row := range p {
+		for col := range p[row] {
+			p[row][col] = v
+		}
+	}
+}
+
+func ChannelsLast(planes []Plane) []float32 {
+	// the planes interleaved square by square, [row][col][plane] as
+	// ToTensor and the network lay them out
+	out := make([]float32, 0, 64*len(planes))
+	for row := range 8 {
+		for col := range 8 {
+			for _, p := range planes {
+				out = append(out, p[row][col])
+			}
+		}
+	}
+	return out
+}
+
+func (b *Board) ToTensor() [8][8][19]float32 {
+	// converts board to tensor to be used as input for the CNN, with
+	// the planes last as the network takes them
+	var tensor [8][8][19]float32
+	for i, plane := range b.Encode(TensorOptions{}, nil) {
+		for row := range plane {
+			for col := range plane[row] {
+				tensor[row][col][i] = plane[row][col]
+			}
+		}
+	}
+	return tensor
+}
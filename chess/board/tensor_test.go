@@ -0,0 +1,192 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func cells(p Plane) string {
+	// the cells set in p, named like the squares they'd be without
+	// mirroring, a1 first
+	var names []string
+	for row := range p {
+		for col := range p[row] {
+			if p[row][col] != 0 {
+				names = append(names, fmt.Sprintf("%c%d", 'a'+col, row+1))
+			}
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func filled(p Plane, v float32) bool {
+	for row := range p {
+		for col := range p[row] {
+			if p[row][col] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func mustBoard(t *testing.T, fen string, moves ...string) *Board {
+	t.Helper()
+	b, err := NewBoardFromFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range moves {
+		m, err := b.ParseMove(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.MakeMove(m)
+	}
+	return b
+}
+
+func checkPlanes(t *testing.T, name string, planes []Plane, want map[int]string) {
+	// compares the piece and en passant planes to the cells expected,
+	// planes not listed being empty
+	t.Helper()
+	for i, p := range planes {
+		if i >= PiecePlanes && i < PositionPlanes && i != EnPassantPlane {
+			continue
+		}
+		if got := cells(p); got != want[i] {
+			t.Errorf("%s: plane %d is %q, expected %q", name, i, got, want[i])
+		}
+	}
+}
+
+// the piece planes of the starting position, white first
+var startPieces = map[int]string{
+	0: "a2 b2 c2 d2 e2 f2 g2 h2", 1: "b1 g1", 2: "c1 f1", 3: "a1 h1", 4: "d1", 5: "e1",
+	6: "a7 b7 c7 d7 e7 f7 g7 h7", 7: "b8 g8", 8: "c8 f8", 9: "a8 h8", 10: "d8", 11: "e8",
+}
+
+func TestEncodeStart(t *testing.T) {
+	planes := NewBoard().Encode(TensorOptions{}, nil)
+	if len(planes) != PositionPlanes {
+		t.Fatalf("%d planes, expected %d", len(planes), PositionPlanes)
+	}
+	checkPlanes(t, "start", planes, startPieces)
+	for i := SideToMovePlane; i < EnPassantPlane; i++ {
+		if !filled(planes[i], 1) {
+			t.Errorf("plane %d isn't all ones", i)
+		}
+	}
+	if !filled(planes[MoveCountPlane], 0) {
+		t.Errorf("move count plane %q, expected empty", cells(planes[MoveCountPlane]))
+	}
+
+	b := mustBoard(t, StartFEN, "e2e4", "e7e5", "g1f3")
+	if planes := b.Encode(TensorOptions{}, nil); !filled(planes[MoveCountPlane], 0.03) || !filled(planes[SideToMovePlane], 0) {
+		t.Errorf("after 3 plies the move count plane isn't 0.03 or white is to move")
+	}
+}
+
+func TestEncodeEnPassant(t *testing.T) {
+	b := mustBoard(t, "4k3/8/8/8/3p4/8/4P3/4K3 w - - 0 1", "e2e4")
+	want := map[int]string{0: "e4", 5: "e1", 6: "d4", 11: "e8", EnPassantPlane: "e3"}
+	checkPlanes(t, "absolute", b.Encode(TensorOptions{}, nil), want)
+
+	// black to move: black's pieces come first, ranks mirrored
+	want = map[int]string{0: "d5", 5: "e1", 6: "e5", 11: "e8", EnPassantPlane: "e6"}
+	checkPlanes(t, "relative", b.Encode(TensorOptions{Relative: true}, nil), want)
+}
+
+func TestEncodeCastling(t *testing.T) {
+	for _, c := range []struct {
+		fen    string
+		opts   TensorOptions
+		rights [4]float32 // planes 13-16
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", TensorOptions{}, [4]float32{1, 1, 1, 1}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w Kq - 0 1", TensorOptions{}, [4]float32{1, 0, 0, 1}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b Qk - 0 1", TensorOptions{}, [4]float32{0, 1, 1, 0}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b Kq - 0 1", TensorOptions{Relative: true}, [4]float32{0, 1, 1, 0}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w Kq - 0 1", TensorOptions{Relative: true}, [4]float32{1, 0, 0, 1}},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b - - 0 1", TensorOptions{Relative: true}, [4]float32{0, 0, 0, 0}},
+	} {
+		planes := mustBoard(t, c.fen).Encode(c.opts, nil)
+		for i, v := range c.rights {
+			if !filled(planes[CastlingPlane+i], v) {
+				t.Errorf("%s relative %v: plane %d isn't all %v", c.fen, c.opts.Relative, CastlingPlane+i, v)
+			}
+		}
+	}
+}
+
+func TestEncodeRelative(t *testing.T) {
+	b := mustBoard(t, StartFEN, "e2e4")
+	planes := b.Encode(TensorOptions{Relative: true}, nil)
+	want := map[int]string{
+		0: "a2 b2 c2 d2 e2 f2 g2 h2", 1: "b1 g1", 2: "c1 f1", 3: "a1 h1", 4: "d1", 5: "e1",
+		6: "e5 a7 b7 c7 d7 f7 g7 h7", 7: "b8 g8", 8: "c8 f8", 9: "a8 h8", 10: "d8", 11: "e8",
+		EnPassantPlane: "e6",
+	}
+	checkPlanes(t, "relative", planes, want)
+	if !filled(planes[SideToMovePlane], 0) {
+		t.Error("side to move plane set with black to move")
+	}
+}
+
+func TestEncodeHistory(t *testing.T) {
+	start := NewBoard()
+	e4 := mustBoard(t, StartFEN, "e2e4")
+	e5 := mustBoard(t, StartFEN, "e2e4", "e7e5")
+
+	opts := TensorOptions{History: 3}
+	planes := e5.Encode(opts, []*Board{e4, start})
+	if len(planes) != opts.Planes() || opts.Planes() != PositionPlanes+3*PiecePlanes {
+		t.Fatalf("%d planes, expected %d", len(planes), PositionPlanes+3*PiecePlanes)
+	}
+	want := map[int]string{EnPassantPlane: "e6"}
+	for i, s := range startPieces {
+		want[i] = s
+		want[PositionPlanes+i] = s
+		want[PositionPlanes+PiecePlanes+i] = s
+	}
+	want[0] = "a2 b2 c2 d2 f2 g2 h2 e4"
+	want[6] = "e5 a7 b7 c7 d7 f7 g7 h7"
+	want[PositionPlanes] = want[0]
+	// the third position back isn't known, so its planes stay empty
+	checkPlanes(t, "history", planes, want)
+
+	// the history is seen from the side to move too
+	planes = e4.Encode(TensorOptions{Relative: true, History: 1}, []*Board{start})
+	want = map[int]string{
+		0: "a2 b2 c2 d2 e2 f2 g2 h2", 5: "e1", 6: "e5 a7 b7 c7 d7 f7 g7 h7", 11: "e8",
+		PositionPlanes: "a2 b2 c2 d2 e2 f2 g2 h2", PositionPlanes + 5: "e1",
+		PositionPlanes + 6: "a7 b7 c7 d7 e7 f7 g7 h7", PositionPlanes + 11: "e8",
+		EnPassantPlane: "e6",
+	}
+	// the other pieces haven't moved, and the start looks the same from
+	// either side
+	for i, s := range startPieces {
+		if _, ok := want[i]; !ok {
+			want[i] = s
+			want[PositionPlanes+i] = s
+		}
+	}
+	checkPlanes(t, "relative history", planes, want)
+}
+
+func TestChannelsLast(t *testing.T) {
+	// ToTensor and ChannelsLast agree on the layout
+	b := mustBoard(t, StartFEN, "e2e4")
+	tensor := b.ToTensor()
+	flat := ChannelsLast(b.Encode(TensorOptions{}, nil))
+	for row := range 8 {
+		for col := range 8 {
+			for i := range PositionPlanes {
+				if got := flat[(row*8+col)*PositionPlanes+i]; got != tensor[row][col][i] {
+					t.Fatalf("row %d col %d plane %d: %v, expected %v", row, col, i, got, tensor[row][col][i])
+				}
+			}
+		}
+	}
+}
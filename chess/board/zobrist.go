@@ -0,0 +1,69 @@
+package chess
+
+import "math/bits"
+
+var (
+	zobristPieces    [2][7][64]uint64
+	zobristCastling  [2][2]uint64
+	zobristEnPassant [8]uint64
+	zobristBlack     uint64
+)
+
+func init() {
+	// fills the zobrist tables from a fixed seed so hashes are
+	// stable between runs and can be stored on disk.
+	seed := uint64(0x9E3779B97F4A7C15)
+	next := func() uint64 {
+		// xorshift64*
+		seed ^= seed >> 12
+		seed ^= seed << 25
+		seed ^= seed >> 27
+		return seed * 0x2545F4914F6CDD1D
+	}
+	for c := range zobristPieces {
+		for p := range zobristPieces[c] {
+			for sq := range zobristPieces[c][p] {
+				zobristPieces[c][p][sq] = next()
+			}
+		}
+	}
+	for c := range zobristCastling {
+		for i := range zobristCastling[c] {
+			zobristCastling[c][i] = next()
+		}
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = next()
+	}
+	zobristBlack = next()
+}
+
+func (b *Board) Hash() uint64 {
+	// computes the zobrist hash of the position. the en passant
+	// file only counts when a pawn can actually capture there, so
+	// transpositions are treated as repetitions.
+	var h uint64
+	for c := White; c <= Black; c++ {
+		for p := Pawns; p <= Kings; p++ {
+			bb := b.PieceBB[c][p]
+			for bb != 0 {
+				sq := Square(bits.TrailingZeros64(uint64(bb)))
+				bb &= bb - 1
+				h ^= zobristPieces[c][p][sq]
+			}
+		}
+		if !b.RKRmoved[c][1] && !b.RKRmoved[c][0] {
+			h ^= zobristCastling[c][0]
+		}
+		if !b.RKRmoved[c][1] && !b.RKRmoved[c][2] {
+			h ^= zobristCastling[c][1]
+		}
+	}
+	if b.EnPassantSquare != nil && PawnAttacks(*b.EnPassantSquare, b.Turn.Other())&b.PieceBB[b.Turn][Pawns] != 0 {
+		h ^= zobristEnPassant[*b.EnPassantSquare%8]
+	}
+	if b.Turn == Black {
+		h ^= zobristBlack
+	}
+	return h
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"chess/engine"
+	"chess/uci"
+	"os"
+)
+
+func main() {
+	uci.Run(os.Stdin, os.Stdout, engine.New())
+}
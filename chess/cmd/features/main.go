@@ -0,0 +1,63 @@
+package main
+
+import (
+	"chess/features"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	// writes the features of every position of PGN databases, one row
+	// or JSON object per position, for analysis outside the engine:
+	//
+	//	features -format csv -out features.csv lichess.pgn
+	format := flag.String("format", "csv", "csv or json, a JSON object on each line")
+	outPath := flag.String("out", "-", "file the features are written to, - for stdout")
+	flag.Parse()
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "features:", err)
+		os.Exit(1)
+	}
+	if flag.NArg() == 0 {
+		fail(fmt.Errorf("give the PGN files to read"))
+	}
+	out := os.Stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	var w features.Writer
+	switch *format {
+	case "csv":
+		w = features.NewCSVWriter(out)
+	case "json":
+		w = features.NewJSONWriter(out)
+	default:
+		fail(fmt.Errorf("unknown format %q", *format))
+	}
+
+	games, bad := 0, 0
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fail(err)
+		}
+		var skipped int
+		games, skipped, err = features.WritePGN(f, w, games)
+		bad += skipped
+		f.Close()
+		if err != nil {
+			fail(fmt.Errorf("%s: %v", path, err))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fail(err)
+	}
+	fmt.Fprintf(os.Stderr, "%d games, %d unreadable ones left out\n", games, bad)
+}
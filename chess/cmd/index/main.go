@@ -0,0 +1,58 @@
+package main
+
+import (
+	"chess/explorer"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	// imports PGN files into the opening explorer's store, adding to
+	// the games already there:
+	//
+	//	index -plies 30 games.pgn more.pgn
+	defaultPath, _ := explorer.DefaultPath()
+	dbPath := flag.String("db", defaultPath, "the store to import into")
+	plies := flag.Int("plies", 40, "moves of each game indexed, 0 for all")
+	fresh := flag.Bool("fresh", false, "start a new store instead of adding to the existing one")
+	flag.Parse()
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "index:", err)
+		os.Exit(1)
+	}
+	if flag.NArg() == 0 {
+		fail(fmt.Errorf("give the PGN files to import"))
+	}
+	if *dbPath == "" {
+		fail(fmt.Errorf("no store, give one with -db"))
+	}
+	start := time.Now()
+	b := explorer.NewBuilder(*plies)
+	if !*fresh {
+		if err := b.Load(*dbPath); err != nil {
+			fail(err)
+		}
+	}
+	total := 0
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fail(err)
+		}
+		games, err := b.AddPGN(f)
+		f.Close()
+		if err != nil {
+			fail(fmt.Errorf("%s: %v", path, err))
+		}
+		fmt.Printf("%s: %d games\n", path, games)
+		total += games
+	}
+	if err := b.WriteFile(*dbPath); err != nil {
+		fail(err)
+	}
+	fmt.Printf("imported %d games into %s, %d positions and moves, in %s; %d unreadable games left out\n",
+		total, *dbPath, b.Positions(), time.Since(start).Round(time.Millisecond), b.Bad)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/pgn"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type engineFlags []string
+
+func (e *engineFlags) String() string     { return strings.Join(*e, " ") }
+func (e *engineFlags) Set(s string) error { *e = append(*e, s); return nil }
+
+type timeControl struct {
+	base, inc time.Duration
+	moveTime  time.Duration
+	nodes     int
+	depth     int
+}
+
+func parseTC(s string) (time.Duration, time.Duration, error) {
+	// seconds[+increment], eg 10+0.1
+	base, inc, _ := strings.Cut(s, "+")
+	b, err := strconv.ParseFloat(base, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad time control %q", s)
+	}
+	i := 0.0
+	if inc != "" {
+		if i, err = strconv.ParseFloat(inc, 64); err != nil {
+			return 0, 0, fmt.Errorf("bad time control %q", s)
+		}
+	}
+	return time.Duration(b * float64(time.Second)), time.Duration(i * float64(time.Second)), nil
+}
+
+type gameResult struct {
+	round int
+	game  *pgn.Game
+	score score // for the first engine
+	err   error
+}
+
+func main() {
+	// plays games between two UCI engines and reports the elo difference:
+	//
+	//	match -engine cmd=self,name=new -engine cmd=self,name=old,NullMove=false -tc 5+0.05 -games 1000 -sprt 0,5
+	var engines engineFlags
+	flag.Var(&engines, "engine", "engine to play, given twice: name=X,cmd=path|self,arg=...,<UCI option>=value")
+	games := flag.Int("games", 100, "number of games, played in pairs with colours reversed")
+	concurrency := flag.Int("concurrency", 1, "games played at the same time")
+	tc := flag.String("tc", "", "time control in seconds plus increment, eg 10+0.1")
+	moveTime := flag.Duration("movetime", 0, "fixed time per move")
+	nodes := flag.Int("nodes", 0, "fixed nodes per move")
+	depth := flag.Int("depth", 0, "fixed depth per move")
+	margin := flag.Duration("margin", 100*time.Millisecond, "time an engine may overstep its clock")
+	openingsPath := flag.String("openings", "", "opening suite, an .epd or .pgn file")
+	plies := flag.Int("plies", 16, "moves played from each pgn opening, 0 for all")
+	pgnPath := flag.String("pgn", "", "file the games are appended to")
+	sprtFlag := flag.String("sprt", "", "stop early with a sequential probability ratio test of elo0,elo1")
+	alpha := flag.Float64("alpha", 0.05, "sprt false positive rate")
+	beta := flag.Float64("beta", 0.05, "sprt false negative rate")
+	flag.Parse()
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "match:", err)
+		os.Exit(1)
+	}
+	if len(engines) != 2 {
+		fail(fmt.Errorf("give exactly two -engine flags"))
+	}
+	var specs [2]engineSpec
+	for i, e := range engines {
+		spec, err := parseSpec(e)
+		if err != nil {
+			fail(err)
+		}
+		specs[i] = spec
+	}
+	limits := timeControl{moveTime: *moveTime, nodes: *nodes, depth: *depth}
+	if *tc != "" {
+		var err error
+		if limits.base, limits.inc, err = parseTC(*tc); err != nil {
+			fail(err)
+		}
+	}
+	if *tc == "" && limits.moveTime == 0 && limits.nodes == 0 && limits.depth == 0 {
+		limits.base, limits.inc = 10*time.Second, 100*time.Millisecond
+	}
+	var test *sprt
+	if *sprtFlag != "" {
+		elo0, elo1, _ := strings.Cut(*sprtFlag, ",")
+		t := sprt{alpha: *alpha, beta: *beta}
+		var err0, err1 error
+		t.elo0, err0 = strconv.ParseFloat(elo0, 64)
+		t.elo1, err1 = strconv.ParseFloat(elo1, 64)
+		if err0 != nil || err1 != nil || t.elo0 >= t.elo1 {
+			fail(fmt.Errorf("bad sprt bounds %q", *sprtFlag))
+		}
+		test = &t
+	}
+	openings, err := loadOpenings(*openingsPath, *plies)
+	if err != nil {
+		fail(err)
+	}
+	var out io.Writer = io.Discard
+	if *pgnPath != "" {
+		f, err := os.OpenFile(*pgnPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	// every worker runs its own pair of engines
+	rounds := make(chan int)
+	results := make(chan gameResult)
+	stop := make(chan struct{})
+	var workers sync.WaitGroup
+	for range max(*concurrency, 1) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			var players [2]*player
+			for i, spec := range specs {
+				p, err := startPlayer(spec)
+				if err != nil {
+					results <- gameResult{err: err}
+					return
+				}
+				if p.name == "" {
+					p.name = fmt.Sprintf("engine%d", i+1)
+				}
+				players[i] = p
+				defer p.close()
+			}
+			for round := range rounds {
+				// each opening is played twice, the engines swapping colours
+				o := openings[(round/2)%len(openings)]
+				white, black := players[0], players[1]
+				if round%2 == 1 {
+					white, black = black, white
+				}
+				g, err := playGame(white, black, o, limits, *margin)
+				r := gameResult{round: round, game: g, err: err}
+				if err == nil {
+					g.SetTag("Round", strconv.Itoa(round+1))
+					r.score = resultFor(g.Result, round%2 == 0)
+				}
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		defer close(rounds)
+		for i := 0; i < *games; i++ {
+			select {
+			case rounds <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	var total score
+	stopped := false
+	for r := range results {
+		if r.err != nil {
+			fmt.Fprintln(os.Stderr, "match:", r.err)
+			continue
+		}
+		fmt.Fprint(out, r.game.String())
+		total.wins += r.score.wins
+		total.draws += r.score.draws
+		total.losses += r.score.losses
+		diff, errorBar := total.elo()
+		fmt.Printf("game %d: %s - %s %s (%s)  score %d-%d-%d  elo %+.1f +/- %.1f  los %.1f%%\n",
+			r.round+1, r.game.Tag("White"), r.game.Tag("Black"), r.game.Result, r.game.Tag("Termination"),
+			total.wins, total.losses, total.draws, diff, errorBar, 100*total.los())
+		if test != nil && !stopped {
+			llr := test.llr(total)
+			lower, upper := test.bounds()
+			fmt.Printf("sprt [%g, %g]: llr %.2f (%.2f, %.2f)\n", test.elo0, test.elo1, llr, lower, upper)
+			if llr <= lower || llr >= upper {
+				if llr >= upper {
+					fmt.Println("sprt: H1 accepted")
+				} else {
+					fmt.Println("sprt: H0 accepted")
+				}
+				stopped = true
+				close(stop)
+			}
+		}
+	}
+}
+
+func resultFor(result string, firstIsWhite bool) score {
+	switch {
+	case result == chess.Drawn:
+		return score{draws: 1}
+	case (result == chess.WhiteWon) == firstIsWhite:
+		return score{wins: 1}
+	}
+	return score{losses: 1}
+}
+
+func playGame(white, black *player, o opening, tc timeControl, margin time.Duration) (*pgn.Game, error) {
+	// plays one game from the opening, adjudicating with the board's
+	// outcome detection, and returns it ready to be written out
+	for _, p := range []*player{white, black} {
+		if err := p.newGame(); err != nil {
+			return nil, fmt.Errorf("%s: %v", p.name, err)
+		}
+	}
+	b, err := chess.NewBoardFromFEN(o.fen)
+	if err != nil {
+		return nil, err
+	}
+	g := &pgn.Game{}
+	g.SetTag("Event", "match")
+	g.SetTag("Site", "local")
+	g.SetTag("Date", time.Now().Format("2006.01.02"))
+	g.SetTag("White", white.name)
+	g.SetTag("Black", black.name)
+	if o.fen != chess.StartFEN {
+		g.SetTag("SetUp", "1")
+		g.SetTag("FEN", o.fen)
+	}
+	var history []uint64
+	var uciMoves []string
+	for _, m := range o.moves {
+		g.Moves = append(g.Moves, pgn.Move{Move: m, SAN: b.SAN(m), Comment: "book"})
+		history = append(history, b.Hash())
+		uciMoves = append(uciMoves, m.String())
+		b.MakeMove(m)
+	}
+
+	clocks := [2]time.Duration{tc.base, tc.base}
+	end := func(result, reason string) (*pgn.Game, error) {
+		g.Result = result
+		g.SetTag("Result", result)
+		g.SetTag("Termination", reason)
+		return g, nil
+	}
+	for {
+		if outcome := b.Outcome(history); outcome.Over() {
+			return end(outcome.Result, outcome.Reason)
+		}
+		p, loses := white, chess.BlackWon
+		if b.Turn == chess.Black {
+			p, loses = black, chess.WhiteWon
+		}
+		position := "position fen " + o.fen
+		if len(uciMoves) > 0 {
+			position += " moves " + strings.Join(uciMoves, " ")
+		}
+		goCommand, timeout := "go", time.Duration(0)
+		switch {
+		case tc.base > 0:
+			goCommand += fmt.Sprintf(" wtime %d btime %d winc %d binc %d",
+				clocks[chess.White].Milliseconds(), clocks[chess.Black].Milliseconds(), tc.inc.Milliseconds(), tc.inc.Milliseconds())
+			timeout = clocks[b.Turn] + margin
+		case tc.moveTime > 0:
+			goCommand += fmt.Sprintf(" movetime %d", tc.moveTime.Milliseconds())
+			timeout = tc.moveTime + margin
+		}
+		if tc.nodes > 0 {
+			goCommand += fmt.Sprintf(" nodes %d", tc.nodes)
+		}
+		if tc.depth > 0 {
+			goCommand += fmt.Sprintf(" depth %d", tc.depth)
+		}
+
+		start := time.Now()
+		t, err := p.think(position, goCommand, timeout)
+		spent := time.Since(start)
+		if err == errTimeout {
+			return end(loses, "time forfeit")
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", p.name, err)
+		}
+		if tc.base > 0 {
+			clocks[b.Turn] -= spent
+			if clocks[b.Turn] < -margin {
+				return end(loses, "time forfeit")
+			}
+			clocks[b.Turn] = max(clocks[b.Turn], 0) + tc.inc
+		}
+		m, err := b.ParseMove(t.move)
+		if err != nil {
+			return end(loses, "illegal move "+t.move)
+		}
+		comment := fmt.Sprintf("%.2fs", spent.Seconds())
+		if t.score != "" {
+			comment = fmt.Sprintf("%s/%d %s", t.score, t.depth, comment)
+		}
+		g.Moves = append(g.Moves, pgn.Move{Move: m, SAN: b.SAN(m), Comment: comment})
+		history = append(history, b.Hash())
+		uciMoves = append(uciMoves, t.move)
+		b.MakeMove(m)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	chess "chess/board"
+	"chess/pgn"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type opening struct {
+	fen   string
+	moves []chess.Move
+}
+
+func loadOpenings(path string, plies int) ([]opening, error) {
+	// reads an opening suite: an EPD file with one position per line,
+	// or a PGN file of which the first plies moves of every game are
+	// played. without a file every game starts from the initial position.
+	if path == "" {
+		return []opening{{fen: chess.StartFEN}}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var openings []opening
+	if strings.EqualFold(filepath.Ext(path), ".pgn") {
+		r := pgn.NewReader(f)
+		for {
+			g, err := r.Next()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, err
+			}
+			b, err := g.StartBoard()
+			if err != nil {
+				return nil, err
+			}
+			o := opening{fen: b.ToFEN()}
+			for i, m := range g.Moves {
+				if plies > 0 && i >= plies {
+					break
+				}
+				o.moves = append(o.moves, m.Move)
+			}
+			openings = append(openings, o)
+		}
+	} else {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 {
+				continue
+			}
+			// epd keeps the first four fen fields, operations follow
+			fen := strings.Join(fields[:4], " ") + " 0 1"
+			if _, err := chess.NewBoardFromFEN(fen); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			openings = append(openings, opening{fen: fen})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(openings) == 0 {
+		return nil, fmt.Errorf("no openings in %s", path)
+	}
+	return openings, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"chess/engine"
+	"chess/uci"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type engineSpec struct {
+	name    string
+	cmd     string // path to a UCI engine, or "self" to run this project's engine in-process
+	args    []string
+	options [][2]string // UCI options, in the order given
+}
+
+func parseSpec(s string) (engineSpec, error) {
+	// reads name=A,cmd=./engine,arg=-x,Hash=64,... where every key
+	// besides name, cmd and arg is passed on as a UCI option
+	var spec engineSpec
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return spec, fmt.Errorf("engine option %q is not key=value", field)
+		}
+		switch key {
+		case "name":
+			spec.name = value
+		case "cmd":
+			spec.cmd = value
+		case "arg":
+			spec.args = append(spec.args, value)
+		default:
+			spec.options = append(spec.options, [2]string{key, value})
+		}
+	}
+	if spec.cmd == "" {
+		return spec, fmt.Errorf("engine %q has no cmd", s)
+	}
+	return spec, nil
+}
+
+type player struct {
+	name  string
+	in    io.WriteCloser
+	lines chan string
+	stop  func()
+}
+
+// the last info line seen while thinking, kept for the PGN comments
+type thought struct {
+	move  string
+	score string // like +0.35 or -M4, from the mover's side
+	depth int
+}
+
+var errTimeout = errors.New("engine did not answer in time")
+
+func startPlayer(spec engineSpec) (*player, error) {
+	// launches the engine and goes through the UCI handshake
+	p := &player{name: spec.name, lines: make(chan string, 256)}
+	var out io.Reader
+	if spec.cmd == "self" {
+		inR, inW := io.Pipe()
+		outR, outW := io.Pipe()
+		go func() {
+			uci.Run(inR, outW, engine.New())
+			outW.Close()
+		}()
+		p.in, out = inW, outR
+		p.stop = func() { inW.Close() }
+	} else {
+		cmd := exec.Command(spec.cmd, spec.args...)
+		in, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		p.in, out = in, stdout
+		p.stop = func() {
+			in.Close()
+			done := make(chan struct{})
+			go func() {
+				cmd.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				cmd.Process.Kill()
+			}
+		}
+	}
+	go func() {
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			p.lines <- scanner.Text()
+		}
+		close(p.lines)
+	}()
+
+	p.send("uci")
+	for {
+		line, err := p.next(10 * time.Second)
+		if err != nil {
+			p.close()
+			return nil, fmt.Errorf("%s: %v", spec.cmd, err)
+		}
+		if name, ok := strings.CutPrefix(line, "id name "); ok && p.name == "" {
+			p.name = name
+		}
+		if line == "uciok" {
+			break
+		}
+	}
+	for _, option := range spec.options {
+		p.send("setoption name " + option[0] + " value " + option[1])
+	}
+	if err := p.ready(); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *player) send(line string) {
+	fmt.Fprintln(p.in, line)
+}
+
+func (p *player) next(timeout time.Duration) (string, error) {
+	// waits for the next line from the engine. zero waits for ever.
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return "", errors.New("engine quit")
+		}
+		return line, nil
+	case <-expired:
+		return "", errTimeout
+	}
+}
+
+func (p *player) ready() error {
+	p.send("isready")
+	for {
+		line, err := p.next(30 * time.Second)
+		if err != nil {
+			return err
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+func (p *player) newGame() error {
+	p.send("ucinewgame")
+	return p.ready()
+}
+
+func (p *player) think(position, goCommand string, timeout time.Duration) (thought, error) {
+	// sends the position and the go command and waits for bestmove
+	p.send(position)
+	p.send(goCommand)
+	var t thought
+	for {
+		line, err := p.next(timeout)
+		if err == errTimeout {
+			// the game is lost anyway, but the engine must be ready
+			// for the next one
+			p.send("stop")
+			for !strings.HasPrefix(line, "bestmove") {
+				if line, err = p.next(5 * time.Second); err != nil {
+					return t, fmt.Errorf("engine hangs after stop: %v", err)
+				}
+			}
+			return t, errTimeout
+		} else if err != nil {
+			return t, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "info":
+			readInfo(&t, fields)
+		case "bestmove":
+			if len(fields) < 2 {
+				return t, errors.New("empty bestmove")
+			}
+			t.move = fields[1]
+			return t, nil
+		}
+	}
+}
+
+func readInfo(t *thought, fields []string) {
+	for i := 1; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			t.depth, _ = strconv.Atoi(fields[i+1])
+		case "score":
+			if i+2 >= len(fields) {
+				return
+			}
+			n, _ := strconv.Atoi(fields[i+2])
+			if fields[i+1] == "mate" {
+				if n < 0 {
+					t.score = fmt.Sprintf("-M%d", -n)
+				} else {
+					t.score = fmt.Sprintf("+M%d", n)
+				}
+			} else {
+				t.score = fmt.Sprintf("%+.2f", float64(n)/100)
+			}
+		case "pv":
+			return
+		}
+	}
+}
+
+func (p *player) close() {
+	p.send("quit")
+	p.stop()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+)
+
+// results from the first engine's point of view
+type score struct {
+	wins, draws, losses int
+}
+
+func (s score) games() int {
+	return s.wins + s.draws + s.losses
+}
+
+func (s score) mean() float64 {
+	return (float64(s.wins) + float64(s.draws)/2) / float64(s.games())
+}
+
+func (s score) variance() float64 {
+	// per game variance of the result around the mean
+	m := s.mean()
+	n := float64(s.games())
+	return (float64(s.wins)*(1-m)*(1-m) + float64(s.draws)*(0.5-m)*(0.5-m) + float64(s.losses)*m*m) / n
+}
+
+func eloFromScore(m float64) float64 {
+	return -400 * math.Log10(1/m-1)
+}
+
+func scoreFromElo(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+func (s score) elo() (diff, margin float64) {
+	// the elo difference with a 95% confidence interval
+	if s.games() == 0 {
+		return 0, math.Inf(1)
+	}
+	m := s.mean()
+	if m == 0 || m == 1 {
+		// one side won everything, there is nothing to measure yet
+		return eloFromScore(m), math.Inf(1)
+	}
+	stderr := math.Sqrt(s.variance() / float64(s.games()))
+	low := math.Max(m-1.959964*stderr, 1e-6)
+	high := math.Min(m+1.959964*stderr, 1-1e-6)
+	return eloFromScore(m), (eloFromScore(high) - eloFromScore(low)) / 2
+}
+
+func (s score) los() float64 {
+	// likelihood of superiority: the chance the first engine is
+	// really the stronger one, from the decisive games
+	if s.wins+s.losses == 0 {
+		return 0.5
+	}
+	return 0.5 * (1 + math.Erf(float64(s.wins-s.losses)/math.Sqrt(2*float64(s.wins+s.losses))))
+}
+
+type sprt struct {
+	elo0, elo1  float64 // the hypotheses: the difference is elo0 against elo1
+	alpha, beta float64 // false positive and false negative rates
+}
+
+func (t sprt) bounds() (lower, upper float64) {
+	return math.Log(t.beta / (1 - t.alpha)), math.Log((1 - t.beta) / t.alpha)
+}
+
+func (t sprt) llr(s score) float64 {
+	// log likelihood ratio of elo1 over elo0, using the normal
+	// approximation of the trinomial game results
+	if s.games() == 0 || s.variance() == 0 {
+		return 0
+	}
+	s0, s1 := scoreFromElo(t.elo0), scoreFromElo(t.elo1)
+	return float64(s.games()) * (s1 - s0) * (2*s.mean() - s0 - s1) / (2 * s.variance())
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance || math.IsInf(got, 0) && got == want
+}
+
+func TestElo(t *testing.T) {
+	// the difference and 95% margin cutechess-cli reports for these
+	// results, worked out from its normal approximation
+	for _, c := range []struct {
+		s            score
+		diff, margin float64
+	}{
+		{score{40, 30, 30}, 34.86, 57.68},
+		{score{100, 200, 100}, 0, 24.11},
+		{score{300, 400, 300}, 0, 16.69},
+		{score{550, 900, 450}, 18.30, 11.34},
+		{score{450, 900, 550}, -18.30, 11.34},
+		{score{3, 0, 1}, 190.85, 1263.22}, // the interval cut off short of 1
+		{score{5, 0, 0}, math.Inf(1), math.Inf(1)},
+		{score{}, 0, math.Inf(1)},
+	} {
+		diff, margin := c.s.elo()
+		if !near(diff, c.diff, 0.01) || !near(margin, c.margin, 0.01) {
+			t.Errorf("%+v: %.2f +/- %.2f, expected %.2f +/- %.2f", c.s, diff, margin, c.diff, c.margin)
+		}
+	}
+}
+
+func TestLOS(t *testing.T) {
+	for _, c := range []struct {
+		s   score
+		los float64
+	}{
+		{score{40, 30, 30}, 0.8840},
+		{score{550, 900, 450}, 0.9992},
+		{score{100, 200, 100}, 0.5},
+		{score{0, 10, 0}, 0.5},
+		{score{0, 0, 1}, 0.1587}, // one standard deviation below
+	} {
+		if los := c.s.los(); !near(los, c.los, 5e-5) {
+			t.Errorf("%+v: LOS %.4f, expected %.4f", c.s, los, c.los)
+		}
+	}
+}
+
+func TestSPRT(t *testing.T) {
+	// fishtest's bounds, and the normal approximation of the log
+	// likelihood ratio for elo0 0 against elo1 5
+	for _, c := range []struct {
+		alpha, beta  float64
+		lower, upper float64
+	}{
+		{0.05, 0.05, -2.944, 2.944},
+		{0.05, 0.1, -2.251, 2.890},
+	} {
+		lower, upper := sprt{0, 5, c.alpha, c.beta}.bounds()
+		if !near(lower, c.lower, 1e-3) || !near(upper, c.upper, 1e-3) {
+			t.Errorf("alpha %v beta %v: bounds %.3f, %.3f, expected %.3f, %.3f", c.alpha, c.beta, lower, upper, c.lower, c.upper)
+		}
+	}
+
+	test := sprt{0, 5, 0.05, 0.05}
+	for _, c := range []struct {
+		s   score
+		llr float64
+	}{
+		{score{550, 900, 450}, 2.373},
+		{score{450, 900, 550}, -3.124},
+		{score{300, 400, 300}, -0.173},
+		{score{0, 20, 0}, 0}, // nothing to go on without variance
+		{score{}, 0},
+	} {
+		if llr := test.llr(c.s); !near(llr, c.llr, 1e-3) {
+			t.Errorf("%+v: LLR %.3f, expected %.3f", c.s, llr, c.llr)
+		}
+	}
+}
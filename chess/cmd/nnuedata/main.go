@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	chess "chess/board"
+	"chess/engine"
+	"chess/nnue"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// a quiet position of a game, scored by the search
+type position struct {
+	board chess.Board
+	score int // for the side to move
+}
+
+type game struct {
+	positions []position
+	result    string
+}
+
+func main() {
+	// plays the engine against itself from random openings and writes
+	// the quiet positions it met, with the search's score and the
+	// game's result, in the text format of the nnue package:
+	//
+	//	nnuedata -games 10000 -depth 8 -out nnue.txt
+	games := flag.Int("games", 100, "number of games")
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "games played at the same time")
+	depth := flag.Int("depth", 6, "depth searched for every move")
+	randomPlies := flag.Int("random-plies", 8, "random moves opening each game, not written")
+	maxPlies := flag.Int("max-plies", 400, "plies after which a game is called a draw")
+	maxScore := flag.Int("max-score", 2000, "positions scored beyond this are left out")
+	outPath := flag.String("out", "nnue.txt", "file the positions are appended to")
+	flag.Parse()
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "nnuedata:", err)
+		os.Exit(1)
+	}
+	f, err := os.OpenFile(*outPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		fail(err)
+	}
+	defer f.Close()
+	out := bufio.NewWriter(f)
+
+	rounds := make(chan int)
+	results := make(chan game)
+	var workers sync.WaitGroup
+	for range max(*concurrency, 1) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			e := engine.New()
+			for range rounds {
+				results <- playGame(e, *depth, *randomPlies, *maxPlies, *maxScore)
+			}
+		}()
+	}
+	go func() {
+		defer close(rounds)
+		for i := range *games {
+			rounds <- i
+		}
+	}()
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	start := time.Now()
+	played, total := 0, 0
+	for g := range results {
+		played++
+		for _, p := range g.positions {
+			if err := nnue.WriteSample(out, &p.board, p.score, g.result); err != nil {
+				fail(err)
+			}
+		}
+		total += len(g.positions)
+		fmt.Printf("game %d: %s, %d positions, %d in all, %s\n", played, g.result, len(g.positions), total, time.Since(start).Round(time.Second))
+	}
+	if err := out.Flush(); err != nil {
+		fail(err)
+	}
+}
+
+func playGame(e *engine.Engine, depth, randomPlies, maxPlies, maxScore int) game {
+	// one game at a fixed depth. positions in check or where the best
+	// move takes something aren't quiet and are skipped.
+	e.NewGame()
+	b := chess.NewBoard()
+	var history []uint64
+	g := game{result: chess.Drawn}
+	for ply := 0; ply < maxPlies; ply++ {
+		if outcome := b.Outcome(history); outcome.Over() {
+			g.result = outcome.Result
+			break
+		}
+		var mv chess.Move
+		if ply < randomPlies {
+			moves := b.LegalMoves()
+			mv = moves[rand.IntN(len(moves))]
+		} else {
+			var info engine.SearchInfo
+			mv, info = e.BestMove(b, history, engine.Limits{Depth: depth})
+			quiet := !b.InCheck() && !b.IsCapture(mv) && mv.Promotion == chess.Empty
+			if quiet && info.Mate == 0 && info.Score >= -maxScore && info.Score <= maxScore {
+				g.positions = append(g.positions, position{*b, info.Score})
+			}
+		}
+		history = append(history, b.Hash())
+		b.MakeMove(mv)
+	}
+	return g
+}
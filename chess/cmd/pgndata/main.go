@@ -0,0 +1,72 @@
+package main
+
+import (
+	"chess/training"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	// turns PGN databases into training shards of the moves played, in
+	// the format cmd/selfplay writes, split into train and val shards:
+	//
+	//	pgndata -min-elo 2000 -min-time 3m -min-ply 8 -out data lichess.pgn
+	outDir := flag.String("out", "data", "directory the shards are written to")
+	minElo := flag.Int("min-elo", 0, "lowest rating of either player")
+	maxElo := flag.Int("max-elo", 0, "highest rating of either player, 0 for no bound")
+	minTime := flag.Duration("min-time", 0, "shortest time control, base plus 40 increments")
+	maxTime := flag.Duration("max-time", 0, "longest time control, 0 for no bound")
+	minPly := flag.Int("min-ply", 0, "first ply of each game sampled")
+	maxPly := flag.Int("max-ply", 0, "last ply of each game sampled, 0 for all")
+	validation := flag.Float64("val", 0.05, "share of the games kept for validation")
+	dedup := flag.Bool("dedup", true, "sample each position only once")
+	dedupSize := flag.Int("dedup-size", training.DefaultDedupSize, "positions remembered for -dedup, 8 bytes each")
+	seed := flag.Uint64("seed", 1, "seed of the train/validation split")
+	shardSize := flag.Int("shard", 8192, "samples in each shard")
+	flag.Parse()
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "pgndata:", err)
+		os.Exit(1)
+	}
+	if flag.NArg() == 0 {
+		fail(fmt.Errorf("give the PGN files to read"))
+	}
+	train, err := training.NewWriter(filepath.Join(*outDir, "train"), "train", *shardSize)
+	if err != nil {
+		fail(err)
+	}
+	val, err := training.NewWriter(filepath.Join(*outDir, "val"), "val", *shardSize)
+	if err != nil {
+		fail(err)
+	}
+	e := training.NewExtractor(train, val, *seed)
+	e.Filter = training.Filter{MinElo: *minElo, MaxElo: *maxElo, MinTime: *minTime, MaxTime: *maxTime, MinPly: *minPly, MaxPly: *maxPly}
+	e.Validation = *validation
+	e.Dedup = *dedup
+	e.DedupSize = *dedupSize
+
+	start := time.Now()
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fail(err)
+		}
+		games, err := e.AddPGN(f)
+		f.Close()
+		if err != nil {
+			fail(fmt.Errorf("%s: %v", path, err))
+		}
+		fmt.Printf("%s: %d games\n", path, games)
+	}
+	for _, w := range []*training.Writer{train, val} {
+		if err := w.Close(); err != nil {
+			fail(err)
+		}
+	}
+	fmt.Printf("%d games used, %d filtered out, %d unreadable, %d duplicate positions; %d training and %d validation samples in %s\n",
+		e.Games, e.Skipped, e.Bad, e.Duplicates, train.Samples, val.Samples, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/mcts"
+	"chess/nn"
+	"chess/tablebase"
+	"chess/training"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type settings struct {
+	sims        int
+	temperature float64 // for the opening moves
+	tempPlies   int     // plies played at temperature
+	finalTemp   float64 // after them
+	resign      float64 // root value a side gives up below, 0 never
+	resignCheck float64 // share of games played out to check resigning
+	maxPlies    int
+}
+
+// a finished game's samples, their values filled in
+type gameResult struct {
+	samples []training.Sample
+	result  string
+	reason  string
+	plies   int
+
+	// in a game played out to check resigning, whether a side would
+	// have resigned and which
+	checked     bool
+	wouldResign bool
+	resigner    chess.Color
+}
+
+func (r gameResult) falseResignation() bool {
+	// a side that would have resigned and didn't go on to lose
+	loss := chess.WhiteWon
+	if r.resigner == chess.White {
+		loss = chess.BlackWon
+	}
+	return r.wouldResign && r.result != loss
+}
+
+func main() {
+	// plays games against itself with the mcts search and writes what
+	// it saw as training shards, see the training package for the format:
+	//
+	//	selfplay -games 1000 -sims 400 -out shards
+	games := flag.Int("games", 100, "number of games")
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "games played at the same time")
+	sims := flag.Int("sims", 200, "playouts per move")
+	batch := flag.Int("batch", mcts.DefaultConfig.Batch, "leaves evaluated together")
+	cpuct := flag.Float64("cpuct", mcts.DefaultConfig.CPuct, "exploration constant")
+	noise := flag.Float64("noise", 0.25, "share of Dirichlet noise in the root priors")
+	alpha := flag.Float64("alpha", mcts.DefaultConfig.DirichletAlpha, "Dirichlet noise alpha")
+	temperature := flag.Float64("temp", 1, "move selection temperature for the opening")
+	tempPlies := flag.Int("temp-plies", 30, "plies played at -temp")
+	finalTemp := flag.Float64("final-temp", 0, "temperature after -temp-plies, 0 for the most visited move")
+	resign := flag.Float64("resign", -0.95, "value below which a side resigns, 0 to play every game out")
+	resignCheck := flag.Float64("resign-check", 0.1, "share of games never resigned, to see resigning is sound")
+	maxPlies := flag.Int("max-plies", 400, "plies after which a game is called a draw")
+	evaluator := flag.String("eval", "handcrafted", "position evaluator: handcrafted, uniform or net")
+	netPath := flag.String("net", "", "weights of the network for -eval net, as cnn.py's export_weights writes them")
+	outDir := flag.String("out", "shards", "directory the shards are written to")
+	shardSize := flag.Int("shard", 8192, "samples in each shard")
+	syzygy := flag.String("syzygy", "", "directories of syzygy tables games are adjudicated with once they reach them")
+	flag.Parse()
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "selfplay:", err)
+		os.Exit(1)
+	}
+	var eval mcts.Evaluator
+	switch *evaluator {
+	case "handcrafted":
+		eval = mcts.Handcrafted{}
+	case "uniform":
+		eval = mcts.Uniform{}
+	case "net":
+		net, err := nn.Load(*netPath)
+		if err != nil {
+			fail(err)
+		}
+		eval = net
+	default:
+		fail(fmt.Errorf("unknown evaluator %q", *evaluator))
+	}
+	if *syzygy != "" {
+		if err := tablebase.Init(*syzygy); err != nil {
+			fail(err)
+		}
+	}
+	config := mcts.DefaultConfig
+	config.CPuct = *cpuct
+	config.Batch = *batch
+	config.DirichletAlpha = *alpha
+	config.DirichletEpsilon = *noise
+	s := settings{*sims, *temperature, *tempPlies, *finalTemp, *resign, *resignCheck, *maxPlies}
+	w, err := training.NewWriter(*outDir, time.Now().Format("selfplay-20060102-150405"), *shardSize)
+	if err != nil {
+		fail(err)
+	}
+
+	rounds := make(chan int)
+	results := make(chan gameResult)
+	var workers sync.WaitGroup
+	for range max(*concurrency, 1) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for range rounds {
+				results <- playGame(eval, config, s)
+			}
+		}()
+	}
+	go func() {
+		defer close(rounds)
+		for i := range *games {
+			rounds <- i
+		}
+	}()
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	start := time.Now()
+	played := 0
+	checked, wouldResign, falseResignations := 0, 0, 0
+	for r := range results {
+		played++
+		if r.checked {
+			checked++
+		}
+		if r.wouldResign {
+			wouldResign++
+		}
+		if r.falseResignation() {
+			falseResignations++
+		}
+		if err := w.Add(r.samples...); err != nil {
+			fail(err)
+		}
+		fmt.Printf("game %d: %s (%s) in %d plies, %d samples, %.1f games/min\n",
+			played, r.result, r.reason, r.plies, len(r.samples), float64(played)/time.Since(start).Minutes())
+	}
+	if err := w.Close(); err != nil {
+		fail(err)
+	}
+	fmt.Printf("%d samples written to %s\n", w.Samples, *outDir)
+	if checked > 0 {
+		rate := 0.0
+		if wouldResign > 0 {
+			rate = 100 * float64(falseResignations) / float64(wouldResign)
+		}
+		fmt.Printf("%d games played out to check resigning, %d would have resigned, %d of those wrongly (%.1f%%)\n",
+			checked, wouldResign, falseResignations, rate)
+	}
+}
+
+func playGame(eval mcts.Evaluator, config mcts.Config, s settings) gameResult {
+	// plays one game, keeping a sample of every position searched. a
+	// side resigns after two searches in a row below the threshold, and
+	// the tablebases decide the game once it reaches them.
+	search := mcts.New(eval, config)
+	b := chess.NewBoard()
+	var history []uint64
+	var samples []training.Sample
+	var turns []chess.Color
+	resignable := s.resign < 0 && rand.Float64() >= s.resignCheck
+	hopeless := [2]int{}
+	r := gameResult{result: chess.Drawn, reason: "max plies", checked: s.resign < 0 && !resignable}
+	for ply := 0; ; ply++ {
+		if outcome := b.Outcome(history); outcome.Over() {
+			r.result, r.reason = outcome.Result, outcome.Reason
+			break
+		}
+		if b.FullBB.Count() <= tablebase.MaxPieces() {
+			if wdl, ok := tablebase.ProbeWDL(b); ok {
+				r.result, r.reason = adjudicate(wdl, b.Turn), "tablebase"
+				break
+			}
+		}
+		if ply == s.maxPlies {
+			break
+		}
+		search.Run(s.sims)
+		samples = append(samples, training.NewSample(b, search.Policy(), 0))
+		turns = append(turns, b.Turn)
+		if search.Value() < s.resign {
+			hopeless[b.Turn]++
+		} else {
+			hopeless[b.Turn] = 0
+		}
+		if resignable && hopeless[b.Turn] >= 2 {
+			r.result, r.reason = chess.WhiteWon, "resignation"
+			if b.Turn == chess.White {
+				r.result = chess.BlackWon
+			}
+			break
+		}
+		// a checked game goes on, noting the first side that would
+		// have resigned
+		if r.checked && !r.wouldResign && hopeless[b.Turn] >= 2 {
+			r.wouldResign, r.resigner = true, b.Turn
+		}
+		temperature := s.temperature
+		if ply >= s.tempPlies {
+			temperature = s.finalTemp
+		}
+		mv := search.SelectMove(temperature)
+		history = append(history, b.Hash())
+		b.MakeMove(mv)
+		search.Play(mv)
+		r.plies++
+	}
+	for i, turn := range turns {
+		switch {
+		case r.result == chess.Drawn:
+		case (r.result == chess.WhiteWon) == (turn == chess.White):
+			samples[i].Value = 1
+		default:
+			samples[i].Value = -1
+		}
+	}
+	r.samples = samples
+	return r
+}
+
+func adjudicate(wdl tablebase.WDL, turn chess.Color) string {
+	// the result of a game the tables give wdl for the side to move.
+	// wins spoiled by the fifty move rule are draws.
+	switch {
+	case wdl == tablebase.Win && turn == chess.White, wdl == tablebase.Loss && turn == chess.Black:
+		return chess.WhiteWon
+	case wdl == tablebase.Win, wdl == tablebase.Loss:
+		return chess.BlackWon
+	}
+	return chess.Drawn
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	chess "chess/board"
+	"chess/engine"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// a position reduced to how much each weight adds to its evaluation,
+// which is linear in the weights
+type position struct {
+	terms  []int32   // indices into Tunable
+	coeffs []float64 // centipawns from white's side per unit of the term
+	result float64   // for white, 1, 0.5 or 0
+}
+
+func main() {
+	// fits the evaluation weights to the results of quiet positions by
+	// Texel's method, minimising the squared error between the results
+	// and the evaluation passed through a sigmoid, and writes the tuned
+	// weights as Go source to replace Weights in engine/eval.go:
+	//
+	//	tune -iters 1000 -out tuned.go quiet-labeled.epd
+	//
+	// positions are EPD lines with the result as "1-0", "0-1" or
+	// "1/2-1/2", or [1.0] [0.5] [0.0], or lines as cmd/nnuedata
+	// writes them.
+	iters := flag.Int("iters", 500, "gradient descent steps")
+	rate := flag.Float64("rate", 1, "learning rate in centipawns")
+	k := flag.Float64("k", 0, "sigmoid scale, fitted to the current weights when 0")
+	limit := flag.Int("limit", 0, "positions read from each file, 0 for all")
+	outPath := flag.String("out", "tuned.go", "file the tuned weights are written to")
+	flag.Parse()
+
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "tune:", err)
+		os.Exit(1)
+	}
+	if flag.NArg() == 0 {
+		fail(fmt.Errorf("give the files of positions to tune on"))
+	}
+	start := time.Now()
+	var boards []*chess.Board
+	var results []float64
+	for _, path := range flag.Args() {
+		b, r, err := loadPositions(path, *limit)
+		if err != nil {
+			fail(err)
+		}
+		boards = append(boards, b...)
+		results = append(results, r...)
+	}
+	if len(boards) == 0 {
+		fail(fmt.Errorf("no positions"))
+	}
+	positions := decompose(boards, results)
+	fmt.Printf("%d positions read in %s\n", len(positions), time.Since(start).Round(time.Millisecond))
+
+	params := engine.Weights
+	terms := params.Tunable()
+	weights := make([]float64, len(terms))
+	for i, t := range terms {
+		weights[i] = float64(*t)
+	}
+	if *k == 0 {
+		*k = fitK(positions, weights)
+	}
+	fmt.Printf("k %.4f, error %.6f\n", *k, loss(positions, weights, *k))
+
+	// Adam, every position in every step
+	m := make([]float64, len(weights))
+	v := make([]float64, len(weights))
+	const beta1, beta2 = 0.9, 0.999
+	for step := 1; step <= *iters; step++ {
+		g := gradient(positions, weights, *k)
+		for i := range weights {
+			m[i] = beta1*m[i] + (1-beta1)*g[i]
+			v[i] = beta2*v[i] + (1-beta2)*g[i]*g[i]
+			mHat := m[i] / (1 - math.Pow(beta1, float64(step)))
+			vHat := v[i] / (1 - math.Pow(beta2, float64(step)))
+			weights[i] -= *rate * mHat / (math.Sqrt(vHat) + 1e-8)
+		}
+		if step%25 == 0 || step == *iters {
+			fmt.Printf("step %d: error %.6f\n", step, loss(positions, weights, *k))
+		}
+	}
+
+	for i, t := range terms {
+		*t = int(math.Round(weights[i]))
+	}
+	fmt.Printf("rounded weights: error %.6f, was %.6f with the old ones\n",
+		evalLoss(boards, results, &params, *k), evalLoss(boards, results, &engine.Weights, *k))
+	f, err := os.Create(*outPath)
+	if err != nil {
+		fail(err)
+	}
+	if err := writeSource(f, &params); err != nil {
+		f.Close()
+		fail(err)
+	}
+	if err := f.Close(); err != nil {
+		fail(err)
+	}
+	fmt.Printf("tuned weights written to %s in %s\n", *outPath, time.Since(start).Round(time.Second))
+}
+
+func loadPositions(path string, limit int) ([]*chess.Board, []float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	var boards []*chess.Board
+	var results []float64
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan() && (limit == 0 || len(boards) < limit); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		b, result, err := parsePosition(text)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		boards = append(boards, b)
+		results = append(results, result)
+	}
+	return boards, results, scanner.Err()
+}
+
+func parsePosition(line string) (*chess.Board, float64, error) {
+	// a position and the result of its game for white
+	if fen, rest, ok := strings.Cut(line, "|"); ok {
+		// fen | score | result
+		fields := strings.Split(rest, "|")
+		result, err := strconv.ParseFloat(strings.TrimSpace(fields[len(fields)-1]), 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("bad result in %q", line)
+		}
+		b, err := chess.NewBoardFromFEN(fen)
+		return b, result, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return nil, 0, fmt.Errorf("no result in %q", line)
+	}
+	// epd keeps the first four fen fields, operations follow
+	b, err := chess.NewBoardFromFEN(strings.Join(fields[:4], " ") + " 0 1")
+	if err != nil {
+		return nil, 0, err
+	}
+	ops := strings.Join(fields[4:], " ")
+	for _, r := range []struct {
+		marks  []string
+		result float64
+	}{
+		{[]string{"1/2-1/2", "[0.5]"}, 0.5},
+		{[]string{"1-0", "[1.0]", "[1]"}, 1},
+		{[]string{"0-1", "[0.0]", "[0]"}, 0},
+	} {
+		for _, mark := range r.marks {
+			if strings.Contains(ops, mark) {
+				return b, r.result, nil
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("no result in %q", line)
+}
+
+func decompose(boards []*chess.Board, results []float64) []position {
+	// finds each weight's share in every evaluation by evaluating with
+	// that weight alone. scaled by 2400 the blending by phase and the
+	// halving of passed pawns in the midgame divide exactly.
+	const unit = 2400
+	positions := make([]position, len(boards))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var params engine.Params
+			terms := params.Tunable()
+			for i := range next {
+				p := position{result: results[i]}
+				for t, term := range terms {
+					*term = unit
+					if score := engine.EvaluateWith(boards[i], &params); score != 0 {
+						p.terms = append(p.terms, int32(t))
+						p.coeffs = append(p.coeffs, float64(score)/unit)
+					}
+					*term = 0
+				}
+				positions[i] = p
+			}
+		}()
+	}
+	for i := range boards {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return positions
+}
+
+func (p *position) eval(weights []float64) float64 {
+	score := 0.0
+	for i, t := range p.terms {
+		score += p.coeffs[i] * weights[t]
+	}
+	return score
+}
+
+func sigmoid(score, k float64) float64 {
+	// the expected result for a score, in [0, 1]
+	return 1 / (1 + math.Pow(10, -k*score/400))
+}
+
+func loss(positions []position, weights []float64, k float64) float64 {
+	sum := 0.0
+	for i := range positions {
+		d := positions[i].result - sigmoid(positions[i].eval(weights), k)
+		sum += d * d
+	}
+	return sum / float64(len(positions))
+}
+
+func evalLoss(boards []*chess.Board, results []float64, params *engine.Params, k float64) float64 {
+	// the error of the evaluation itself, to check the linear model
+	sum := 0.0
+	for i, b := range boards {
+		d := results[i] - sigmoid(float64(engine.EvaluateWith(b, params)), k)
+		sum += d * d
+	}
+	return sum / float64(len(boards))
+}
+
+func gradient(positions []position, weights []float64, k float64) []float64 {
+	// of the loss, split over the cores
+	workers := runtime.NumCPU()
+	parts := make([][]float64, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g := make([]float64, len(weights))
+			for i := w; i < len(positions); i += workers {
+				p := &positions[i]
+				s := sigmoid(p.eval(weights), k)
+				// d/dw (r-s)^2 = -2(r-s) s(1-s) k ln10/400 coeff
+				d := -2 * (p.result - s) * s * (1 - s) * k * math.Ln10 / 400
+				for j, t := range p.terms {
+					g[t] += d * p.coeffs[j]
+				}
+			}
+			parts[w] = g
+		}()
+	}
+	wg.Wait()
+	g := parts[0]
+	for _, part := range parts[1:] {
+		for i, v := range part {
+			g[i] += v
+		}
+	}
+	for i := range g {
+		g[i] /= float64(len(positions))
+	}
+	return g
+}
+
+func fitK(positions []position, weights []float64) float64 {
+	// the scale that best fits the current weights, by golden section
+	lo, hi := 0.01, 5.0
+	ratio := (math.Sqrt(5) - 1) / 2
+	for hi-lo > 1e-4 {
+		a := hi - ratio*(hi-lo)
+		b := lo + ratio*(hi-lo)
+		if loss(positions, weights, a) < loss(positions, weights, b) {
+			hi = b
+		} else {
+			lo = a
+		}
+	}
+	return (lo + hi) / 2
+}
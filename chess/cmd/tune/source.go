@@ -0,0 +1,51 @@
+package main
+
+import (
+	"chess/engine"
+	"fmt"
+	"go/format"
+	"io"
+	"strings"
+)
+
+var pieceNames = []string{"", "pawns", "knights", "bishops", "rooks", "queens", "kings"}
+
+func writeSource(w io.Writer, p *engine.Params) error {
+	// the weights as a Weights declaration in the layout of eval.go
+	var sb strings.Builder
+	sb.WriteString("package engine\n\n")
+	sb.WriteString("// Weights tuned by cmd/tune, to replace those in eval.go.\n")
+	sb.WriteString("var Weights = Params{\n")
+	fmt.Fprintf(&sb, "Material: %#v,\n", p.Material)
+	for _, table := range []struct {
+		name string
+		pst  *[7][64]int
+	}{{"PSTMidgame", &p.PSTMidgame}, {"PSTEndgame", &p.PSTEndgame}} {
+		fmt.Fprintf(&sb, "%s: [7][64]int{\n{},\n", table.name)
+		for piece := 1; piece < 7; piece++ {
+			fmt.Fprintf(&sb, "{ // %s\n", pieceNames[piece])
+			for rank := range 8 {
+				row := make([]string, 8)
+				for file := range 8 {
+					row[file] = fmt.Sprint(table.pst[piece][rank*8+file])
+				}
+				sb.WriteString(strings.Join(row, ", ") + ",\n")
+			}
+			sb.WriteString("},\n")
+		}
+		sb.WriteString("},\n")
+	}
+	fmt.Fprintf(&sb, "DoubledPawn: %d,\n", p.DoubledPawn)
+	fmt.Fprintf(&sb, "IsolatedPawn: %d,\n", p.IsolatedPawn)
+	fmt.Fprintf(&sb, "PassedPawn: %#v,\n", p.PassedPawn)
+	fmt.Fprintf(&sb, "BishopPair: %d,\n", p.BishopPair)
+	fmt.Fprintf(&sb, "RookOpenFile: %d,\n", p.RookOpenFile)
+	fmt.Fprintf(&sb, "RookSemiOpenFile: %d,\n", p.RookSemiOpenFile)
+	sb.WriteString("}\n")
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	chess "chess/board"
+	"chess/engine"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+)
+
+func randomPositions(n int) []*chess.Board {
+	// positions along random games, with pawn structures, bishop pairs
+	// and rooks on open files coming and going
+	r := rand.New(rand.NewPCG(5, 6))
+	var boards []*chess.Board
+	b := chess.NewBoard()
+	for len(boards) < n {
+		moves := b.LegalMoves()
+		if len(moves) == 0 || b.MoveCounter > 160 {
+			b = chess.NewBoard()
+			continue
+		}
+		b.MakeMove(moves[r.IntN(len(moves))])
+		next := *b
+		boards = append(boards, &next)
+	}
+	return boards
+}
+
+func weightsOf(p *engine.Params) []float64 {
+	var weights []float64
+	for _, term := range p.Tunable() {
+		weights = append(weights, float64(*term))
+	}
+	return weights
+}
+
+func TestDecompose(t *testing.T) {
+	// the decomposed evaluation is the engine's. with every weight a
+	// multiple of 48 the engine's halving of passed pawns and division
+	// by the phase are exact, so the two must agree to the last bit of
+	// the coefficients.
+	boards := randomPositions(500)
+	positions := decompose(boards, make([]float64, len(boards)))
+
+	scaled := engine.Weights
+	for _, term := range scaled.Tunable() {
+		*term *= 48
+	}
+	for i, b := range boards {
+		p := positions[i]
+		if got, want := p.eval(weightsOf(&scaled)), float64(engine.EvaluateWith(b, &scaled)); math.Abs(got-want) > 1e-6 {
+			t.Fatalf("%s: decomposed %v, evaluated %v", b.ToFEN(), got, want)
+		}
+	}
+}
+
+// the values of a composite literal in order, an empty one standing
+// for size zeros
+func literalInts(t *testing.T, expr ast.Expr, size int) []int {
+	t.Helper()
+	switch e := expr.(type) {
+	case *ast.CompositeLit:
+		if len(e.Elts) == 0 {
+			return make([]int, size)
+		}
+		var values []int
+		for _, elt := range e.Elts {
+			values = append(values, literalInts(t, elt, size/len(e.Elts))...)
+		}
+		return values
+	case *ast.UnaryExpr:
+		return []int{-literalInts(t, e.X, 1)[0]}
+	case *ast.BasicLit:
+		v, err := strconv.Atoi(e.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []int{v}
+	}
+	t.Fatalf("unexpected %T", expr)
+	return nil
+}
+
+func TestWriteSource(t *testing.T) {
+	// the source parses as a Weights declaration that holds the same
+	// weights, negative ones included
+	r := rand.New(rand.NewPCG(7, 8))
+	params := engine.Weights
+	for _, term := range params.Tunable() {
+		*term = r.IntN(601) - 300
+	}
+	var buf bytes.Buffer
+	if err := writeSource(&buf, &params); err != nil {
+		t.Fatal(err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "weights.go", buf.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+	spec := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	if file.Name.Name != "engine" || spec.Names[0].Name != "Weights" {
+		t.Fatalf("declares %s.%s", file.Name.Name, spec.Names[0].Name)
+	}
+
+	var read engine.Params
+	fields := map[string][]*int{
+		"DoubledPawn":      {&read.DoubledPawn},
+		"IsolatedPawn":     {&read.IsolatedPawn},
+		"BishopPair":       {&read.BishopPair},
+		"RookOpenFile":     {&read.RookOpenFile},
+		"RookSemiOpenFile": {&read.RookSemiOpenFile},
+	}
+	for i := range read.Material {
+		fields["Material"] = append(fields["Material"], &read.Material[i])
+	}
+	for i := range read.PassedPawn {
+		fields["PassedPawn"] = append(fields["PassedPawn"], &read.PassedPawn[i])
+	}
+	for p := range 7 {
+		for sq := range 64 {
+			fields["PSTMidgame"] = append(fields["PSTMidgame"], &read.PSTMidgame[p][sq])
+			fields["PSTEndgame"] = append(fields["PSTEndgame"], &read.PSTEndgame[p][sq])
+		}
+	}
+	for _, elt := range spec.Values[0].(*ast.CompositeLit).Elts {
+		kv := elt.(*ast.KeyValueExpr)
+		name := kv.Key.(*ast.Ident).Name
+		targets, ok := fields[name]
+		if !ok {
+			t.Fatalf("unknown field %s", name)
+		}
+		values := literalInts(t, kv.Value, len(targets))
+		if len(values) != len(targets) {
+			t.Fatalf("%s: %d values, expected %d", name, len(values), len(targets))
+		}
+		for i, v := range values {
+			*targets[i] = v
+		}
+		delete(fields, name)
+	}
+	if len(fields) > 0 {
+		t.Errorf("%d fields not written", len(fields))
+	}
+	if read != params {
+		t.Error("the weights read back differ from those written")
+	}
+}
@@ -1,3 +1,7 @@
+import glob
+import struct
+
+import numpy as np
 import tensorflow as tf
 from go_to_py import ChessGame
 
@@ -24,21 +28,29 @@ def create_chess_model():
         x = tf.keras.layers.ReLU()(x)
 
     # Policy head (move probabilities)
-    policy_head = tf.keras.layers.Conv2D(2, 1, padding="same")(x)
-    policy_head = tf.keras.layers.BatchNormalization()(policy_head)
+    policy_head = tf.keras.layers.Conv2D(2, 1, padding="same", name="policy_conv")(
+        x
+    )
+    policy_head = tf.keras.layers.BatchNormalization(name="policy_bn")(policy_head)
     policy_head = tf.keras.layers.ReLU()(policy_head)
     policy_head = tf.keras.layers.Flatten()(policy_head)
-    policy_head = tf.keras.layers.Dense(1968, activation="softmax")(
+    policy_head = tf.keras.layers.Dense(
+        1968, activation="softmax", name="policy_dense"
+    )(
         policy_head
     )  # 1968 possible moves
 
     # Value head (position evaluation)
-    value_head = tf.keras.layers.Conv2D(1, 1, padding="same")(x)
-    value_head = tf.keras.layers.BatchNormalization()(value_head)
+    value_head = tf.keras.layers.Conv2D(1, 1, padding="same", name="value_conv")(x)
+    value_head = tf.keras.layers.BatchNormalization(name="value_bn")(value_head)
     value_head = tf.keras.layers.ReLU()(value_head)
     value_head = tf.keras.layers.Flatten()(value_head)
-    value_head = tf.keras.layers.Dense(256, activation="relu")(value_head)
-    value_head = tf.keras.layers.Dense(1, activation="tanh")(value_head)
+    value_head = tf.keras.layers.Dense(256, activation="relu", name="value_hidden")(
+        value_head
+    )
+    value_head = tf.keras.layers.Dense(1, activation="tanh", name="value_dense")(
+        value_head
+    )
 
     model = tf.keras.Model(inputs=inputs, outputs=[policy_head, value_head])
     return model
@@ -124,7 +136,7 @@ def self_play(model, num_games=100):
                 # During actual play, choose the most visited move
                 action = policy.index(max(policy))
 
-            game.make_move(action)
+            game = game.make_move(action)
 
             # If game finished, add results
             if game.is_terminal():
@@ -149,3 +161,61 @@ def train_model(model, training_data):
         epochs=10,
     )
     return model
+
+
+def load_shards(pattern):
+    # Reads the shards cmd/selfplay and cmd/pgndata write into training
+    # data for train_model, eg load_shards("shards/*.npz")
+    training_data = []
+    for path in sorted(glob.glob(pattern)):
+        with np.load(path) as shard:
+            training_data.extend(
+                zip(shard["states"], shard["policies"], shard["values"])
+            )
+    return training_data
+
+
+HEAD_LAYERS = [
+    "policy_conv",
+    "policy_bn",
+    "policy_dense",
+    "value_conv",
+    "value_bn",
+    "value_hidden",
+    "value_dense",
+]
+
+
+def export_weights(model, path):
+    # Dumps the weights for the Go inference in package nn, in the format
+    # described in nn/network.go
+    tower = [
+        layer
+        for layer in model.layers
+        if isinstance(
+            layer, (tf.keras.layers.Conv2D, tf.keras.layers.BatchNormalization)
+        )
+        and layer.name not in HEAD_LAYERS
+    ]
+    heads = [model.get_layer(name) for name in HEAD_LAYERS]
+    with open(path, "wb") as f:
+        f.write(b"CHESSNET1\n")
+        f.write(
+            struct.pack(
+                "<7I",
+                model.input_shape[-1],
+                tower[0].filters,
+                (len(tower) - 2) // 4,
+                heads[0].filters,
+                heads[3].filters,
+                heads[5].units,
+                heads[2].units,
+            )
+        )
+        for layer in tower + heads:
+            if isinstance(layer, tf.keras.layers.BatchNormalization):
+                arrays = layer.get_weights() + [[layer.epsilon]]
+            else:
+                arrays = layer.get_weights()
+            for array in arrays:
+                f.write(np.asarray(array, dtype="<f4").tobytes())
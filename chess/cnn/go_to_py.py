@@ -0,0 +1,102 @@
+"""The Go rules engine for training, through the shared library cnn/gotopy
+builds. From the repository root:
+
+    go build -buildmode=c-shared -o cnn/libchess.so ./cnn/gotopy
+
+Actions are indices into the network's 1968 policy outputs, seen from the
+side to move as board.PolicyIndex numbers them with black flipped.
+"""
+
+import ctypes
+import os
+
+import numpy as np
+
+POLICY_SIZE = 1968
+STATE_SHAPE = (8, 8, 19)
+
+_lib = ctypes.CDLL(
+    os.environ.get("CHESS_LIB")
+    or os.path.join(os.path.dirname(os.path.abspath(__file__)), "libchess.so")
+)
+_lib.ChessNew.argtypes = [ctypes.c_char_p]
+_lib.ChessNew.restype = ctypes.c_int
+_lib.ChessFree.argtypes = [ctypes.c_int]
+_lib.ChessMakeMove.argtypes = [ctypes.c_int, ctypes.c_int]
+_lib.ChessMakeMove.restype = ctypes.c_int
+_lib.ChessIsTerminal.argtypes = [ctypes.c_int]
+_lib.ChessIsTerminal.restype = ctypes.c_int
+_lib.ChessResult.argtypes = [ctypes.c_int]
+_lib.ChessResult.restype = ctypes.c_int
+_lib.ChessMoveCount.argtypes = [ctypes.c_int]
+_lib.ChessMoveCount.restype = ctypes.c_int
+_lib.ChessTensor.argtypes = [ctypes.c_int, ctypes.POINTER(ctypes.c_float), ctypes.c_int]
+_lib.ChessLegalMask.argtypes = [ctypes.c_int, ctypes.POINTER(ctypes.c_ubyte)]
+# kept as a pointer so the string can be freed on the Go side
+_lib.ChessFEN.argtypes = [ctypes.c_int]
+_lib.ChessFEN.restype = ctypes.c_void_p
+_lib.ChessFreeString.argtypes = [ctypes.c_void_p]
+
+
+class ChessGame:
+    def __init__(self, fen=None, _handle=None):
+        if _handle is None:
+            _handle = _lib.ChessNew((fen or "").encode())
+            if _handle < 0:
+                raise ValueError("invalid FEN: %r" % fen)
+        self._handle = _handle
+
+    def __del__(self):
+        if getattr(self, "_handle", None) is not None:
+            _lib.ChessFree(self._handle)
+
+    def make_move(self, action):
+        # The game after the move of action, or None if it isn't legal.
+        # This game is left as it was.
+        handle = _lib.ChessMakeMove(self._handle, int(action))
+        if handle < 0:
+            return None
+        return ChessGame(_handle=handle)
+
+    def is_terminal(self):
+        return _lib.ChessIsTerminal(self._handle) == 1
+
+    def get_result(self):
+        # 1 if the player who made the last move won, 0 for a draw
+        return _lib.ChessResult(self._handle)
+
+    def get_canonical_state(self):
+        # The position seen from the side to move, as the network takes it
+        return self._tensor(True)
+
+    def to_tensor(self):
+        # The position as board.ToTensor encodes it, from white's side
+        return self._tensor(False)
+
+    def _tensor(self, relative):
+        buf = (ctypes.c_float * (8 * 8 * 19))()
+        _lib.ChessTensor(self._handle, buf, int(relative))
+        return np.frombuffer(buf, dtype=np.float32).reshape(STATE_SHAPE)
+
+    def legal_mask(self):
+        buf = (ctypes.c_ubyte * POLICY_SIZE)()
+        _lib.ChessLegalMask(self._handle, buf)
+        return np.frombuffer(buf, dtype=np.uint8).astype(bool)
+
+    def legal_actions(self):
+        return [i for i, legal in enumerate(self.legal_mask()) if legal]
+
+    @property
+    def move_count(self):
+        # plies played
+        return _lib.ChessMoveCount(self._handle)
+
+    def fen(self):
+        ptr = _lib.ChessFEN(self._handle)
+        try:
+            return ctypes.string_at(ptr).decode()
+        finally:
+            _lib.ChessFreeString(ptr)
+
+    def __repr__(self):
+        return "ChessGame(%r)" % self.fen()
@@ -0,0 +1,161 @@
+package main
+
+// the board package for Python, built as a shared library that
+// cnn/go_to_py.py loads:
+//
+//	go build -buildmode=c-shared -o cnn/libchess.so ./cnn/gotopy
+//
+// games are kept here and handed out as numbers, cgo not letting C hold
+// Go pointers. moves are policy indices, seen from the side to move.
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	chess "chess/board"
+	"sync"
+	"unsafe"
+)
+
+type game struct {
+	board   *chess.Board
+	history []uint64 // hashes of the earlier positions
+	outcome chess.Outcome
+}
+
+var (
+	mu     sync.Mutex
+	games  = map[C.int]*game{}
+	nextID C.int
+)
+
+func add(g *game) C.int {
+	mu.Lock()
+	defer mu.Unlock()
+	nextID++
+	games[nextID] = g
+	return nextID
+}
+
+func get(id C.int) *game {
+	mu.Lock()
+	defer mu.Unlock()
+	return games[id]
+}
+
+//export ChessNew
+func ChessNew(fen *C.char) C.int {
+	// a game from fen, or the starting position when it is empty.
+	// -1 if the FEN is invalid.
+	b := chess.NewBoard()
+	if s := C.GoString(fen); s != "" {
+		var err error
+		if b, err = chess.NewBoardFromFEN(s); err != nil {
+			return -1
+		}
+	}
+	return add(&game{board: b, outcome: b.Outcome(nil)})
+}
+
+//export ChessFree
+func ChessFree(id C.int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(games, id)
+}
+
+//export ChessMakeMove
+func ChessMakeMove(id C.int, action C.int) C.int {
+	// a new game with the move of the policy index played, the old one
+	// is left as it was. -1 if the move isn't legal or the game is over.
+	g := get(id)
+	if g == nil || g.outcome.Over() {
+		return -1
+	}
+	mv, err := g.board.PolicyMove(int(action), g.board.Turn == chess.Black)
+	if err != nil {
+		return -1
+	}
+	b := *g.board
+	b.MakeMove(mv)
+	history := append(g.history[:len(g.history):len(g.history)], g.board.Hash())
+	return add(&game{board: &b, history: history, outcome: b.Outcome(history)})
+}
+
+//export ChessIsTerminal
+func ChessIsTerminal(id C.int) C.int {
+	if g := get(id); g == nil || g.outcome.Over() {
+		return 1
+	}
+	return 0
+}
+
+//export ChessResult
+func ChessResult(id C.int) C.int {
+	// 1 if the player who made the last move won, 0 for a draw or a
+	// game still going. the side to move never wins on its turn.
+	g := get(id)
+	if g == nil || g.outcome.Result == chess.Drawn || !g.outcome.Over() {
+		return 0
+	}
+	return 1
+}
+
+//export ChessMoveCount
+func ChessMoveCount(id C.int) C.int {
+	// plies played, from the FEN's count for games set up from one
+	g := get(id)
+	if g == nil {
+		return 0
+	}
+	return C.int(g.board.MoveCounter)
+}
+
+//export ChessTensor
+func ChessTensor(id C.int, out *C.float, relative C.int) {
+	// fills out with the 8x8x19 tensor of the position, as ToTensor lays
+	// it out. relative gives it from the side to move.
+	g := get(id)
+	if g == nil {
+		return
+	}
+	tensor := unsafe.Slice((*float32)(unsafe.Pointer(out)), 8*8*chess.PositionPlanes)
+	copy(tensor, chess.ChannelsLast(g.board.Encode(chess.TensorOptions{Relative: relative != 0}, nil)))
+}
+
+//export ChessLegalMask
+func ChessLegalMask(id C.int, out *C.uchar) {
+	// fills out with a byte for each policy index, 1 for legal moves
+	g := get(id)
+	if g == nil {
+		return
+	}
+	mask := unsafe.Slice((*byte)(unsafe.Pointer(out)), chess.PolicySize)
+	if g.outcome.Over() {
+		clear(mask)
+		return
+	}
+	for i, legal := range g.board.PolicyMask(g.board.Turn == chess.Black) {
+		mask[i] = 0
+		if legal {
+			mask[i] = 1
+		}
+	}
+}
+
+//export ChessFEN
+func ChessFEN(id C.int) *C.char {
+	// the position as FEN, to be freed with ChessFreeString
+	g := get(id)
+	if g == nil {
+		return C.CString("")
+	}
+	return C.CString(g.board.ToFEN())
+}
+
+//export ChessFreeString
+func ChessFreeString(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func main() {}
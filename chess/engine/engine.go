@@ -0,0 +1,275 @@
+package engine
+
+import (
+	chess "chess/board"
+	"chess/nnue"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	Infinity  = 32000
+	Mate      = 31000
+	MateBound = Mate - MaxPly
+	TBWin     = MateBound - 1 // a tablebase win, scored just below mates
+	MaxPly    = 128
+)
+
+type Options struct {
+	HashMB int
+
+	// tablebases are probed in the tree from this depth on
+	SyzygyProbeDepth int
+
+	// search techniques, each can be turned off to measure what it's worth
+	NullMove          bool
+	LateMoveReduction bool
+	Futility          bool
+	ReverseFutility   bool
+	AspirationWindows bool
+	CheckExtensions   bool
+	PVS               bool
+
+	// play weaker, at about the given elo
+	LimitStrength bool
+	Elo           int
+}
+
+// DefaultOptions are the settings used by New.
+var DefaultOptions = Options{
+	HashMB:            16,
+	SyzygyProbeDepth:  1,
+	NullMove:          true,
+	LateMoveReduction: true,
+	Futility:          true,
+	ReverseFutility:   true,
+	AspirationWindows: true,
+	CheckExtensions:   true,
+	PVS:               true,
+	Elo:               1500,
+}
+
+type Limits struct {
+	Depth     int
+	Nodes     uint64
+	MoveTime  time.Duration
+	WTime     time.Duration
+	BTime     time.Duration
+	WInc      time.Duration
+	BInc      time.Duration
+	MovesToGo int
+	Infinite  bool
+	Ponder    bool
+}
+
+type SearchInfo struct {
+	Depth    int
+	SelDepth int
+	Score    int // centipawns from the side to move
+	Mate     int // moves to mate, negative when being mated, 0 if none
+	Nodes    uint64
+	Time     time.Duration
+	Hashfull int
+	TBHits   uint64
+	PV       []chess.Move
+}
+
+type Engine struct {
+	Options Options
+
+	tt        *transpositionTable
+	stop      atomic.Bool
+	pondering atomic.Bool
+	started   atomic.Int64 // unix nanos the clock started running
+	wake      chan struct{}
+	done      chan struct{}
+	mu        sync.Mutex
+
+	// state of the running search, only touched by its goroutine
+	limits    Limits
+	softLimit time.Duration
+	hardLimit time.Duration
+	rootDepth int
+	nodes     uint64
+	tbHits    uint64
+	rootMoves []chess.Move // when set only these are searched at the root
+	selDepth  int
+	path      []uint64
+	killers   [MaxPly][2]chess.Move
+	history   [2][64][64]int
+	pv        [MaxPly][MaxPly]chess.Move
+	pvLen     [MaxPly]int
+	strength  strength
+	noiseSeed uint64
+
+	// with a network loaded positions are evaluated by it, each ply
+	// keeping the accumulator of the position searched there
+	nnue         *nnue.Network
+	accumulators []nnue.Accumulator
+}
+
+func New() *Engine {
+	// creates an engine with the default options
+	e := &Engine{
+		Options: DefaultOptions,
+		wake:    make(chan struct{}, 1),
+	}
+	e.tt = newTranspositionTable(e.Options.HashMB)
+	return e
+}
+
+func (e *Engine) SetHash(megabytes int) {
+	// resizes the transposition table, clearing it
+	e.Wait()
+	e.Options.HashMB = megabytes
+	e.tt = newTranspositionTable(megabytes)
+}
+
+func (e *Engine) SetNNUE(n *nnue.Network) {
+	// evaluates with n from the next search on, or with the hand
+	// written evaluation again when n is nil
+	e.Wait()
+	e.nnue = n
+	e.accumulators = nil
+	if n != nil {
+		e.accumulators = make([]nnue.Accumulator, MaxPly+1)
+		for i := range e.accumulators {
+			e.accumulators[i] = n.NewAccumulator()
+		}
+	}
+}
+
+func (e *Engine) NewGame() {
+	// forgets everything learned from the previous game
+	e.Wait()
+	e.tt.clear()
+	e.history = [2][64][64]int{}
+}
+
+func (e *Engine) Analyze(b *chess.Board, history []uint64, limits Limits) <-chan SearchInfo {
+	// starts searching b in the background and streams a SearchInfo
+	// for every finished iteration. history holds the hashes of the
+	// game positions before b so repetitions are seen. the channel is
+	// closed when the search ends; its last value is the final result.
+	// with limits.Ponder or limits.Infinite the search keeps going
+	// until PonderHit or Stop.
+	e.Stop()
+	e.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stop.Store(false)
+	e.pondering.Store(limits.Ponder)
+	e.started.Store(time.Now().UnixNano())
+	select {
+	case <-e.wake:
+	default:
+	}
+	e.limits = limits
+	e.limitStrength()
+	e.softLimit, e.hardLimit = allocateTime(b.Turn, limits)
+	e.path = append(e.path[:0], history...)
+
+	out := make(chan SearchInfo, 64)
+	done := make(chan struct{})
+	e.done = done
+	root := *b
+	go func() {
+		defer close(done)
+		defer close(out)
+		e.iterate(&root, out)
+	}()
+	return out
+}
+
+func (e *Engine) BestMove(b *chess.Board, history []uint64, limits Limits) (chess.Move, SearchInfo) {
+	// searches b to the limits and returns the best move and the
+	// final search info
+	var last SearchInfo
+	for info := range e.Analyze(b, history, limits) {
+		last = info
+	}
+	if len(last.PV) == 0 {
+		return chess.NullMove, last
+	}
+	return last.PV[0], last
+}
+
+func (e *Engine) Stop() {
+	// asks the running search to finish as soon as possible
+	e.stop.Store(true)
+	e.signal()
+}
+
+func (e *Engine) PonderHit() {
+	// the opponent played the pondered move: the search continues
+	// but now runs on our clock, starting from this moment
+	e.started.Store(time.Now().UnixNano())
+	e.pondering.Store(false)
+	e.signal()
+}
+
+func (e *Engine) Wait() {
+	// blocks until the running search, if any, has ended
+	e.mu.Lock()
+	done := e.done
+	e.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+func (e *Engine) signal() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (e *Engine) elapsed() time.Duration {
+	return time.Duration(time.Now().UnixNano() - e.started.Load())
+}
+
+func allocateTime(color chess.Color, limits Limits) (time.Duration, time.Duration) {
+	// works out how long to think: the soft limit stops new iterations
+	// and the hard limit aborts the one in progress. zero means no limit.
+	if limits.MoveTime > 0 {
+		return limits.MoveTime, limits.MoveTime
+	}
+	remaining, inc := limits.WTime, limits.WInc
+	if color == chess.Black {
+		remaining, inc = limits.BTime, limits.BInc
+	}
+	if remaining <= 0 {
+		return 0, 0
+	}
+	movesToGo := limits.MovesToGo
+	if movesToGo <= 0 {
+		movesToGo = 30
+	}
+	const overhead = 30 * time.Millisecond
+	soft := remaining/time.Duration(movesToGo) + inc*3/4
+	hard := min(soft*3, remaining/2)
+	soft = min(soft, hard)
+	if remaining > overhead {
+		hard = min(hard, remaining-overhead)
+	}
+	return max(soft, time.Millisecond), max(hard, time.Millisecond)
+}
+
+func send(out chan SearchInfo, info SearchInfo) {
+	// delivers info without ever blocking the search. if the reader
+	// has fallen behind the oldest update is dropped.
+	for {
+		select {
+		case out <- info:
+			return
+		default:
+			select {
+			case <-out:
+			default:
+			}
+		}
+	}
+}
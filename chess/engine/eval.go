@@ -0,0 +1,290 @@
+package engine
+
+import (
+	chess "chess/board"
+	"math/bits"
+)
+
+type Params struct {
+	Material [7]int
+
+	// piece square tables written from white's side with rank 8 on
+	// top, so white looks up sq^56 and black looks up sq directly.
+	PSTMidgame [7][64]int
+	PSTEndgame [7][64]int
+
+	DoubledPawn      int
+	IsolatedPawn     int
+	PassedPawn       [8]int // by rank from the pawn's own side
+	BishopPair       int
+	RookOpenFile     int
+	RookSemiOpenFile int
+}
+
+// Weights holds the evaluation parameters used by Evaluate.
+var Weights = Params{
+	Material: [7]int{0, 100, 320, 330, 500, 900, 0},
+	PSTMidgame: [7][64]int{
+		{},
+		{ // pawns
+			0, 0, 0, 0, 0, 0, 0, 0,
+			50, 50, 50, 50, 50, 50, 50, 50,
+			10, 10, 20, 30, 30, 20, 10, 10,
+			5, 5, 10, 25, 25, 10, 5, 5,
+			0, 0, 0, 20, 20, 0, 0, 0,
+			5, -5, -10, 0, 0, -10, -5, 5,
+			5, 10, 10, -20, -20, 10, 10, 5,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		knightTable,
+		bishopTable,
+		rookTable,
+		queenTable,
+		{ // kings
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-20, -30, -30, -40, -40, -30, -30, -20,
+			-10, -20, -20, -20, -20, -20, -20, -10,
+			20, 20, 0, 0, 0, 0, 20, 20,
+			20, 30, 10, 0, 0, 10, 30, 20,
+		},
+	},
+	PSTEndgame: [7][64]int{
+		{},
+		{ // pawns
+			0, 0, 0, 0, 0, 0, 0, 0,
+			80, 80, 80, 80, 80, 80, 80, 80,
+			50, 50, 50, 50, 50, 50, 50, 50,
+			30, 30, 30, 30, 30, 30, 30, 30,
+			20, 20, 20, 20, 20, 20, 20, 20,
+			10, 10, 10, 10, 10, 10, 10, 10,
+			0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		knightTable,
+		bishopTable,
+		rookTable,
+		queenTable,
+		{ // kings
+			-50, -40, -30, -20, -20, -30, -40, -50,
+			-30, -20, -10, 0, 0, -10, -20, -30,
+			-30, -10, 20, 30, 30, 20, -10, -30,
+			-30, -10, 30, 40, 40, 30, -10, -30,
+			-30, -10, 30, 40, 40, 30, -10, -30,
+			-30, -10, 20, 30, 30, 20, -10, -30,
+			-30, -30, 0, 0, 0, 0, -30, -30,
+			-50, -30, -30, -30, -30, -30, -30, -50,
+		},
+	},
+	DoubledPawn:      -10,
+	IsolatedPawn:     -15,
+	PassedPawn:       [8]int{0, 5, 10, 20, 35, 60, 100, 0},
+	BishopPair:       30,
+	RookOpenFile:     25,
+	RookSemiOpenFile: 10,
+}
+
+var knightTable = [64]int{
+	-50, -40, -30, -30, -30, -30, -40, -50,
+	-40, -20, 0, 0, 0, 0, -20, -40,
+	-30, 0, 10, 15, 15, 10, 0, -30,
+	-30, 5, 15, 20, 20, 15, 5, -30,
+	-30, 0, 15, 20, 20, 15, 0, -30,
+	-30, 5, 10, 15, 15, 10, 5, -30,
+	-40, -20, 0, 5, 5, 0, -20, -40,
+	-50, -40, -30, -30, -30, -30, -40, -50,
+}
+
+var bishopTable = [64]int{
+	-20, -10, -10, -10, -10, -10, -10, -20,
+	-10, 0, 0, 0, 0, 0, 0, -10,
+	-10, 0, 5, 10, 10, 5, 0, -10,
+	-10, 5, 5, 10, 10, 5, 5, -10,
+	-10, 0, 10, 10, 10, 10, 0, -10,
+	-10, 10, 10, 10, 10, 10, 10, -10,
+	-10, 5, 0, 0, 0, 0, 5, -10,
+	-20, -10, -10, -10, -10, -10, -10, -20,
+}
+
+var rookTable = [64]int{
+	0, 0, 0, 0, 0, 0, 0, 0,
+	5, 10, 10, 10, 10, 10, 10, 5,
+	-5, 0, 0, 0, 0, 0, 0, -5,
+	-5, 0, 0, 0, 0, 0, 0, -5,
+	-5, 0, 0, 0, 0, 0, 0, -5,
+	-5, 0, 0, 0, 0, 0, 0, -5,
+	-5, 0, 0, 0, 0, 0, 0, -5,
+	0, 0, 0, 5, 5, 0, 0, 0,
+}
+
+var queenTable = [64]int{
+	-20, -10, -10, -5, -5, -10, -10, -20,
+	-10, 0, 0, 0, 0, 0, 0, -10,
+	-10, 0, 5, 5, 5, 5, 0, -10,
+	-5, 0, 5, 5, 5, 5, 0, -5,
+	0, 0, 5, 5, 5, 5, 0, -5,
+	-10, 5, 5, 5, 5, 5, 0, -10,
+	-10, 0, 5, 0, 0, 0, 0, -10,
+	-20, -10, -10, -5, -5, -10, -10, -20,
+}
+
+// game phase contribution of each piece, 24 is a full middlegame
+var phaseWeight = [7]int{0, 0, 1, 1, 2, 4, 0}
+
+const maxPhase = 24
+
+var files = [8]chess.Bitboard{
+	chess.FileA, chess.FileB, chess.FileC, chess.FileD,
+	chess.FileE, chess.FileF, chess.FileG, chess.FileH,
+}
+
+func Evaluate(b *chess.Board) int {
+	// scores the position in centipawns from the side to move
+	score := EvaluateWith(b, &Weights)
+	if b.Turn == chess.Black {
+		return -score
+	}
+	return score
+}
+
+func Phase(b *chess.Board) int {
+	// returns the game phase from 24 (all pieces on) down to 0 (pawn endgame)
+	phase := 0
+	for c := chess.White; c <= chess.Black; c++ {
+		for p := chess.Knights; p <= chess.Queens; p++ {
+			phase += phaseWeight[p] * b.PieceBB[c][p].Count()
+		}
+	}
+	return min(phase, maxPhase)
+}
+
+func (w *Params) Tunable() []*int {
+	// every weight that counts in the evaluation, for tuners. kings
+	// have no material value and pawns never stand on the first or
+	// last rank, so those are left out.
+	var terms []*int
+	for p := chess.Pawns; p <= chess.Queens; p++ {
+		terms = append(terms, &w.Material[p])
+	}
+	for p := chess.Pawns; p <= chess.Kings; p++ {
+		for sq := range 64 {
+			if p == chess.Pawns && (sq < 8 || sq >= 56) {
+				continue
+			}
+			terms = append(terms, &w.PSTMidgame[p][sq], &w.PSTEndgame[p][sq])
+		}
+	}
+	terms = append(terms, &w.DoubledPawn, &w.IsolatedPawn)
+	for rank := 1; rank < 7; rank++ {
+		terms = append(terms, &w.PassedPawn[rank])
+	}
+	return append(terms, &w.BishopPair, &w.RookOpenFile, &w.RookSemiOpenFile)
+}
+
+func EvaluateWith(b *chess.Board, w *Params) int {
+	// scores the position in centipawns from white's side using the
+	// given weights. midgame and endgame tables are blended by phase.
+	var mg, eg [2]int
+	for c := chess.White; c <= chess.Black; c++ {
+		flip := chess.Square(56)
+		if c == chess.Black {
+			flip = 0
+		}
+		for p := chess.Pawns; p <= chess.Kings; p++ {
+			bb := b.PieceBB[c][p]
+			for bb != 0 {
+				sq := chess.Square(bits.TrailingZeros64(uint64(bb)))
+				bb &= bb - 1
+				mg[c] += w.Material[p] + w.PSTMidgame[p][sq^flip]
+				eg[c] += w.Material[p] + w.PSTEndgame[p][sq^flip]
+			}
+		}
+		pmg, peg := evalPawns(b, c, w)
+		mg[c] += pmg
+		eg[c] += peg
+		if b.PieceBB[c][chess.Bishops].Count() >= 2 {
+			mg[c] += w.BishopPair
+			eg[c] += w.BishopPair
+		}
+		rooks := b.PieceBB[c][chess.Rooks]
+		for rooks != 0 {
+			sq := chess.Square(bits.TrailingZeros64(uint64(rooks)))
+			rooks &= rooks - 1
+			file := sq.GetFile()
+			if file&b.PieceBB[c][chess.Pawns] == 0 {
+				if file&b.PieceBB[c.Other()][chess.Pawns] == 0 {
+					mg[c] += w.RookOpenFile
+				} else {
+					mg[c] += w.RookSemiOpenFile
+				}
+			}
+		}
+	}
+	phase := Phase(b)
+	mgScore := mg[chess.White] - mg[chess.Black]
+	egScore := eg[chess.White] - eg[chess.Black]
+	return (mgScore*phase + egScore*(maxPhase-phase)) / maxPhase
+}
+
+func evalPawns(b *chess.Board, c chess.Color, w *Params) (int, int) {
+	// pawn structure terms for one side: doubled, isolated and passed pawns.
+	// passed pawns count mostly in the endgame.
+	var mg, eg int
+	pawns := b.PieceBB[c][chess.Pawns]
+	enemy := b.PieceBB[c.Other()][chess.Pawns]
+	for f := range 8 {
+		count := (pawns & files[f]).Count()
+		if count == 0 {
+			continue
+		}
+		if count > 1 {
+			mg += w.DoubledPawn * (count - 1)
+			eg += w.DoubledPawn * (count - 1)
+		}
+		var neighbours chess.Bitboard
+		if f > 0 {
+			neighbours |= files[f-1]
+		}
+		if f < 7 {
+			neighbours |= files[f+1]
+		}
+		if pawns&neighbours == 0 {
+			mg += w.IsolatedPawn * count
+			eg += w.IsolatedPawn * count
+		}
+	}
+	bb := pawns
+	for bb != 0 {
+		sq := chess.Square(bits.TrailingZeros64(uint64(bb)))
+		bb &= bb - 1
+		if PassedMask(sq, c)&enemy == 0 {
+			rank := int(sq / 8)
+			if c == chess.Black {
+				rank = 7 - rank
+			}
+			mg += w.PassedPawn[rank] / 2
+			eg += w.PassedPawn[rank]
+		}
+	}
+	return mg, eg
+}
+
+func PassedMask(sq chess.Square, c chess.Color) chess.Bitboard {
+	// returns the squares in front of a pawn on its own and the
+	// neighbouring files. the pawn is passed if no enemy pawn is there.
+	file := sq.GetFile()
+	mask := file
+	if file != chess.FileA {
+		mask |= file >> 1
+	}
+	if file != chess.FileH {
+		mask |= file << 1
+	}
+	rank := int(sq / 8)
+	if c == chess.White {
+		return mask &^ (chess.Bitboard(1)<<(uint(rank+1)*8) - 1)
+	}
+	return mask & (chess.Bitboard(1)<<(uint(rank)*8) - 1)
+}
@@ -0,0 +1,475 @@
+package engine
+
+import (
+	chess "chess/board"
+	"chess/tablebase"
+	"math"
+	"slices"
+)
+
+func (e *Engine) iterate(root *chess.Board, out chan SearchInfo) {
+	// iterative deepening: searches one ply deeper each time until a
+	// limit is hit, reporting every completed iteration
+	e.nodes = 0
+	e.tbHits = 0
+	if e.nnue != nil {
+		e.nnue.Refresh(&e.accumulators[0], root)
+	}
+	e.killers = [MaxPly][2]chess.Move{}
+	for c := range e.history {
+		for from := range e.history[c] {
+			for to := range e.history[c][from] {
+				e.history[c][from][to] /= 8
+			}
+		}
+	}
+
+	// with a tablebase hit at the root only the moves keeping the
+	// best result are searched
+	e.rootMoves = nil
+	if moves, _, ok := tablebase.ProbeRoot(root); ok && len(moves) > 0 {
+		e.rootMoves = moves
+		e.tbHits++
+	}
+
+	maxDepth := MaxPly - 1
+	if e.limits.Depth > 0 {
+		maxDepth = min(e.limits.Depth, maxDepth)
+	}
+	score := 0
+	var last SearchInfo
+	for depth := 1; depth <= maxDepth; depth++ {
+		e.rootDepth = depth
+		e.selDepth = 0
+		score = e.searchRoot(root, depth, score)
+		if e.aborted() {
+			break
+		}
+		last = e.info(depth, score)
+		send(out, last)
+		if e.pvLen[0] == 0 {
+			// no legal moves, nothing more to search
+			break
+		}
+		if e.limits.Infinite || e.pondering.Load() {
+			continue
+		}
+		if e.softLimit > 0 && e.elapsed() > e.softLimit/2 {
+			break
+		}
+		if e.limits.Nodes > 0 && e.nodes >= e.limits.Nodes {
+			break
+		}
+	}
+	if e.Options.LimitStrength {
+		send(out, e.weakerMove(root, last))
+	}
+	// a ponder or infinite search only reports its move once told to
+	for !e.stop.Load() && (e.pondering.Load() || e.limits.Infinite) {
+		<-e.wake
+	}
+}
+
+func (e *Engine) searchRoot(root *chess.Board, depth, previous int) int {
+	// with aspiration windows the search starts with a narrow window
+	// around the previous score and widens it whenever the score
+	// falls outside
+	if !e.Options.AspirationWindows || depth < 5 || previous > MateBound || previous < -MateBound {
+		return e.negamax(root, depth, -Infinity, Infinity, 0, true)
+	}
+	delta := 25
+	alpha, beta := previous-delta, previous+delta
+	for {
+		score := e.negamax(root, depth, alpha, beta, 0, true)
+		if e.aborted() {
+			return score
+		}
+		if score <= alpha {
+			alpha = max(score-delta, -Infinity)
+		} else if score >= beta {
+			beta = min(score+delta, Infinity)
+		} else {
+			return score
+		}
+		delta *= 2
+	}
+}
+
+func (e *Engine) info(depth, score int) SearchInfo {
+	info := SearchInfo{
+		Depth:    depth,
+		SelDepth: e.selDepth,
+		Score:    score,
+		Nodes:    e.nodes,
+		Time:     e.elapsed(),
+		Hashfull: e.tt.hashfull(),
+		TBHits:   e.tbHits,
+		PV:       slices.Clone(e.pv[0][:e.pvLen[0]]),
+	}
+	info.Mate = mateIn(score)
+	return info
+}
+
+func mateIn(score int) int {
+	// moves to mate for a mate score, 0 otherwise
+	if score > MateBound {
+		return (Mate - score + 1) / 2
+	} else if score < -MateBound {
+		return -(Mate + score) / 2
+	}
+	return 0
+}
+
+func (e *Engine) aborted() bool {
+	// checks the stop flag and the limits. the first iteration always
+	// completes so there is a move to play.
+	if e.rootDepth <= 1 {
+		return false
+	}
+	if e.stop.Load() {
+		return true
+	}
+	if e.nodes&1023 != 0 {
+		return false
+	}
+	if e.limits.Nodes > 0 && e.nodes >= e.limits.Nodes {
+		e.stop.Store(true)
+	} else if e.hardLimit > 0 && !e.limits.Infinite && !e.pondering.Load() && e.elapsed() > e.hardLimit {
+		e.stop.Store(true)
+	}
+	return e.stop.Load()
+}
+
+func (e *Engine) isDraw(b *chess.Board, hash uint64) bool {
+	// fifty move rule, dead positions and repetitions of any earlier
+	// position since the last capture or pawn move
+	if b.HalfMoveClock >= 100 || b.InsufficientMaterial() {
+		return true
+	}
+	n := len(e.path)
+	for i := n - 2; i >= 0 && i >= n-int(b.HalfMoveClock); i -= 2 {
+		if e.path[i] == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// margins for futility pruning by remaining depth
+var futilityMargin = [4]int{0, 150, 300, 500}
+
+func (e *Engine) negamax(b *chess.Board, depth, alpha, beta, ply int, nullOK bool) int {
+	e.pvLen[ply] = 0
+	if e.aborted() {
+		return 0
+	}
+	hash := b.Hash()
+	if ply > 0 && e.isDraw(b, hash) {
+		return 0
+	}
+	inCheck := b.InCheck()
+	if inCheck && e.Options.CheckExtensions {
+		depth++
+	}
+	if depth <= 0 || ply >= MaxPly-1 {
+		return e.quiesce(b, alpha, beta, ply)
+	}
+	e.nodes++
+	pvNode := beta-alpha > 1
+
+	ttMove := chess.NullMove
+	if entry, ok := e.tt.probe(hash); ok {
+		ttMove = entry.move
+		score := scoreFromTT(int(entry.score), ply)
+		// exact hits are only trusted off the principal variation so
+		// the reported line isn't cut short
+		if ply > 0 && int(entry.depth) >= depth {
+			switch {
+			case entry.flag == flagExact && !pvNode,
+				entry.flag == flagLower && score >= beta,
+				entry.flag == flagUpper && score <= alpha:
+				return score
+			}
+		}
+	}
+
+	// right after a capture or pawn move the tablebases give the exact
+	// result of the position
+	if ply > 0 && b.HalfMoveClock == 0 && depth >= e.Options.SyzygyProbeDepth &&
+		b.FullBB.Count() <= tablebase.MaxPieces() {
+		if wdl, ok := tablebase.ProbeWDL(b); ok {
+			e.tbHits++
+			score := 0
+			if wdl == tablebase.Win {
+				score = TBWin - ply
+			} else if wdl == tablebase.Loss {
+				score = -TBWin + ply
+			}
+			e.tt.store(hash, chess.NullMove, score, MaxPly-1, flagExact)
+			return score
+		}
+	}
+
+	e.path = append(e.path, hash)
+	defer func() { e.path = e.path[:len(e.path)-1] }()
+
+	staticEval := 0
+	if !inCheck {
+		staticEval = e.evaluate(b, ply)
+	}
+	notMated := beta < MateBound && beta > -MateBound
+
+	// reverse futility: far enough above beta that a quiet move won't
+	// bring the score back down
+	if e.Options.ReverseFutility && !pvNode && !inCheck && notMated && depth <= 6 &&
+		staticEval-90*depth >= beta {
+		return staticEval - 90*depth
+	}
+
+	// null move: if passing still fails high the position is good
+	// enough to cut. skipped with only pawns left, where zugzwang is
+	// common and passing would be the best move.
+	if e.Options.NullMove && nullOK && !pvNode && !inCheck && notMated && depth >= 3 &&
+		staticEval >= beta && hasPieces(b, b.Turn) {
+		next := *b
+		next.MakeNullMove()
+		e.played(b, &next, ply)
+		r := 2 + depth/4
+		score := -e.negamax(&next, depth-1-r, -beta, -beta+1, ply+1, false)
+		if e.aborted() {
+			return 0
+		}
+		if score >= beta {
+			return beta
+		}
+	}
+
+	// futility: near the leaves quiet moves can't lift a hopeless score
+	futile := e.Options.Futility && !pvNode && !inCheck && depth < len(futilityMargin) &&
+		alpha > -MateBound && staticEval+futilityMargin[depth] <= alpha
+
+	moves := b.PseudoLegalMoves(false)
+	scores := e.orderMoves(b, moves, ttMove, ply)
+	best := -Infinity
+	bestMove := chess.NullMove
+	flag := flagUpper
+	legal := 0
+	for i := range moves {
+		m := pickMove(moves, scores, i)
+		if ply == 0 && e.rootMoves != nil && !slices.Contains(e.rootMoves, m) {
+			continue
+		}
+		next := *b
+		next.MakeMove(m)
+		if next.IsAttacked(next.KingSquare(b.Turn), next.Turn) {
+			continue
+		}
+		e.played(b, &next, ply)
+		legal++
+		quiet := !b.IsCapture(m) && m.Promotion == chess.Empty
+		givesCheck := next.InCheck()
+		if futile && legal > 1 && quiet && !givesCheck {
+			continue
+		}
+
+		// late move reductions: quiet moves ordered late are searched
+		// shallower first and only re-searched if they turn out good
+		reduction := 0
+		if e.Options.LateMoveReduction && depth >= 3 && legal > 3 && quiet && !inCheck && !givesCheck &&
+			m != e.killers[ply][0] && m != e.killers[ply][1] {
+			reduction = lmrTable[min(depth, 63)][min(legal, 63)]
+			if pvNode {
+				reduction--
+			}
+			reduction = max(0, min(reduction, depth-2))
+		}
+
+		var score int
+		switch {
+		case legal == 1:
+			score = -e.negamax(&next, depth-1, -beta, -alpha, ply+1, true)
+		case e.Options.PVS:
+			// principal variation search: later moves only need to
+			// prove they are no better than alpha
+			score = -e.negamax(&next, depth-1-reduction, -alpha-1, -alpha, ply+1, true)
+			if score > alpha && reduction > 0 {
+				score = -e.negamax(&next, depth-1, -alpha-1, -alpha, ply+1, true)
+			}
+			if score > alpha && score < beta {
+				score = -e.negamax(&next, depth-1, -beta, -alpha, ply+1, true)
+			}
+		default:
+			if reduction > 0 {
+				score = -e.negamax(&next, depth-1-reduction, -alpha-1, -alpha, ply+1, true)
+			}
+			if reduction == 0 || score > alpha {
+				score = -e.negamax(&next, depth-1, -beta, -alpha, ply+1, true)
+			}
+		}
+		if e.aborted() {
+			return 0
+		}
+		if score > best {
+			best = score
+			bestMove = m
+			if score > alpha {
+				alpha = score
+				flag = flagExact
+				e.updatePV(ply, m)
+				if alpha >= beta {
+					flag = flagLower
+					if quiet {
+						e.addKiller(ply, m)
+						e.history[b.Turn][m.From][m.To] += depth * depth
+					}
+					break
+				}
+			}
+		}
+	}
+	if legal == 0 {
+		if inCheck {
+			return -Mate + ply
+		}
+		return 0
+	}
+	if best == -Infinity {
+		// every move was pruned as futile
+		best = alpha
+	}
+	e.tt.store(hash, bestMove, scoreToTT(best, ply), depth, flag)
+	return best
+}
+
+// reductions by depth and move number, log(depth)*log(moves)/2
+var lmrTable = func() (t [64][64]int) {
+	for d := 1; d < 64; d++ {
+		for m := 1; m < 64; m++ {
+			t[d][m] = int(0.75 + math.Log(float64(d))*math.Log(float64(m))/2.25)
+		}
+	}
+	return t
+}()
+
+func (e *Engine) played(b, next *chess.Board, ply int) {
+	// brings the network's accumulator of next, one ply below b, up to
+	// date before it is searched
+	if e.nnue != nil {
+		e.nnue.Update(&e.accumulators[ply+1], &e.accumulators[ply], b, next)
+	}
+}
+
+func hasPieces(b *chess.Board, c chess.Color) bool {
+	// checks if c has anything besides pawns and the king
+	pieces := b.PieceBB[c]
+	return pieces[chess.Knights]|pieces[chess.Bishops]|pieces[chess.Rooks]|pieces[chess.Queens] != 0
+}
+
+func (e *Engine) quiesce(b *chess.Board, alpha, beta, ply int) int {
+	// searches captures only until the position is quiet so the
+	// evaluation isn't taken in the middle of an exchange. when in
+	// check every evasion is searched instead.
+	e.pvLen[ply] = 0
+	if e.aborted() {
+		return 0
+	}
+	e.nodes++
+	e.selDepth = max(e.selDepth, ply)
+	if ply >= MaxPly-1 {
+		// no room left for the line below, in check or not
+		return e.evaluate(b, ply)
+	}
+	inCheck := b.InCheck()
+	best := -Infinity
+	if !inCheck {
+		best = e.evaluate(b, ply)
+		if best >= beta {
+			return best
+		}
+		alpha = max(alpha, best)
+	}
+
+	moves := b.PseudoLegalMoves(!inCheck)
+	scores := e.orderMoves(b, moves, chess.NullMove, ply)
+	legal := 0
+	for i := range moves {
+		m := pickMove(moves, scores, i)
+		next := *b
+		next.MakeMove(m)
+		if next.IsAttacked(next.KingSquare(b.Turn), next.Turn) {
+			continue
+		}
+		e.played(b, &next, ply)
+		legal++
+		score := -e.quiesce(&next, -beta, -alpha, ply+1)
+		if score > best {
+			best = score
+			if score > alpha {
+				alpha = score
+				e.updatePV(ply, m)
+				if alpha >= beta {
+					break
+				}
+			}
+		}
+	}
+	if inCheck && legal == 0 {
+		return -Mate + ply
+	}
+	return best
+}
+
+func (e *Engine) updatePV(ply int, m chess.Move) {
+	// the line at ply is m followed by the line found below it
+	e.pv[ply][0] = m
+	n := copy(e.pv[ply][1:], e.pv[ply+1][:e.pvLen[ply+1]])
+	e.pvLen[ply] = n + 1
+}
+
+func (e *Engine) addKiller(ply int, m chess.Move) {
+	if e.killers[ply][0] != m {
+		e.killers[ply][1] = e.killers[ply][0]
+		e.killers[ply][0] = m
+	}
+}
+
+func (e *Engine) orderMoves(b *chess.Board, moves []chess.Move, ttMove chess.Move, ply int) []int {
+	// gives each move a sort key: hash move, captures by most valuable
+	// victim / least valuable attacker, killers, then history
+	scores := make([]int, len(moves))
+	enemy := b.Turn.Other()
+	for i, m := range moves {
+		switch {
+		case m == ttMove:
+			scores[i] = 1 << 30
+		case b.IsCapture(m):
+			victim := b.GetPieceAt(m.To, enemy)
+			if victim == chess.Empty {
+				victim = chess.Pawns
+			}
+			scores[i] = 1<<20 + int(victim)*16 - int(m.Piece)
+		case m.Promotion == chess.Queens:
+			scores[i] = 1<<20 - 1
+		case m == e.killers[ply][0]:
+			scores[i] = 1<<19 + 1
+		case m == e.killers[ply][1]:
+			scores[i] = 1 << 19
+		default:
+			scores[i] = min(e.history[b.Turn][m.From][m.To], 1<<18)
+		}
+	}
+	return scores
+}
+
+func pickMove(moves []chess.Move, scores []int, i int) chess.Move {
+	// selection sort step: swaps the best remaining move into place i
+	best := i
+	for j := i + 1; j < len(moves); j++ {
+		if scores[j] > scores[best] {
+			best = j
+		}
+	}
+	moves[i], moves[best] = moves[best], moves[i]
+	scores[i], scores[best] = scores[best], scores[i]
+	return moves[i]
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	chess "chess/board"
+	"chess/nnue"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func mustBoard(t *testing.T, fen string) *chess.Board {
+	t.Helper()
+	b, err := chess.NewBoardFromFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func randomNetwork(hidden int) *nnue.Network {
+	// a network of small random weights, for exercising the accumulators
+	r := rand.New(rand.NewPCG(1, 2))
+	n := &nnue.Network{
+		Hidden:         hidden,
+		FeatureWeights: make([]int16, nnue.Inputs*hidden),
+		FeatureBias:    make([]int16, hidden),
+		OutputWeights:  make([]int16, 2*hidden),
+	}
+	for _, w := range [][]int16{n.FeatureWeights, n.FeatureBias, n.OutputWeights} {
+		for i := range w {
+			w[i] = int16(r.IntN(64) - 32)
+		}
+	}
+	return n
+}
+
+func TestQuiesceAtMaxPly(t *testing.T) {
+	// a check at the last ply is evaluated rather than searched past
+	// the end of the per ply tables
+	b := mustBoard(t, "4k3/8/8/8/8/8/3q4/4K3 w - - 0 1")
+	e := New()
+	e.quiesce(b, -Infinity, Infinity, MaxPly-1)
+
+	e.SetNNUE(randomNetwork(8))
+	e.nnue.Refresh(&e.accumulators[MaxPly-1], b)
+	if got, want := e.quiesce(b, -Infinity, Infinity, MaxPly-1), e.nnue.EvaluateBoard(b); got != want {
+		t.Errorf("score %d, expected the static evaluation %d", got, want)
+	}
+}
+
+func TestFindsMates(t *testing.T) {
+	for _, c := range []struct {
+		fen  string
+		mate int
+		move string // the only mating move, if there is one
+	}{
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 1, "a1a8"},
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", 1, "h1h8"},
+		{"k7/8/2K5/8/8/8/8/7R w - - 0 1", 2, ""},
+		{"k7/2K5/8/8/8/8/8/1R6 b - - 0 1", -1, "a8a7"},
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", 1, "h5f7"},
+	} {
+		e := New()
+		m, info := e.BestMove(mustBoard(t, c.fen), nil, Limits{Depth: 6})
+		if info.Mate != c.mate || c.move != "" && m.String() != c.move {
+			t.Errorf("%s: %v mate %d, expected %s mate %d", c.fen, m, info.Mate, c.move, c.mate)
+		}
+	}
+}
+
+func TestNoMoves(t *testing.T) {
+	// mated and stalemated roots end the search without a move
+	for _, fen := range []string{"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1", "k7/2Q5/1K6/8/8/8/8/8 b - - 0 1"} {
+		if m, _ := New().BestMove(mustBoard(t, fen), nil, Limits{Depth: 4}); m != chess.NullMove {
+			t.Errorf("%s: played %v", fen, m)
+		}
+	}
+}
+
+func TestAnalyzeClosesChannel(t *testing.T) {
+	// the channel is closed when a limited search ends and once an
+	// infinite one is stopped, its last value the deepest iteration
+	e := New()
+	b := chess.NewBoard()
+	depth := 0
+	for info := range e.Analyze(b, nil, Limits{Depth: 4}) {
+		if info.Depth != depth+1 {
+			t.Errorf("depth %d after %d", info.Depth, depth)
+		}
+		depth = info.Depth
+	}
+	if depth != 4 {
+		t.Errorf("stopped at depth %d, expected 4", depth)
+	}
+
+	infos := e.Analyze(b, nil, Limits{Infinite: true})
+	<-infos
+	e.Stop()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-infos:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the channel wasn't closed after Stop")
+		}
+	}
+}
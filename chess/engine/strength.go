@@ -0,0 +1,167 @@
+package engine
+
+import (
+	chess "chess/board"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// range of UCI_Elo
+const (
+	MinElo = 800
+	MaxElo = 2800
+)
+
+type Difficulty struct {
+	Name string
+	Elo  int
+}
+
+// Difficulties are the levels offered when setting up a game.
+var Difficulties = []Difficulty{
+	{"Easy", 1000},
+	{"Medium", 1500},
+	{"Hard", 2000},
+}
+
+func DifficultyElo(name string) int {
+	// looks up the elo of a difficulty by name, full strength if unknown
+	for _, d := range Difficulties {
+		if d.Name == name {
+			return d.Elo
+		}
+	}
+	return MaxElo
+}
+
+type strength struct {
+	depth   int     // deepest iteration searched
+	nodes   uint64  // most nodes searched per move
+	noise   int     // evaluations are off by up to this many centipawns
+	spread  int     // how much worse than the best a move may be and still be played
+	blunder float64 // chance of choosing the move after only a careless look
+}
+
+func strengthFor(elo int) strength {
+	// maps an elo onto the handicaps. everything scales from the weakest
+	// level (x=0) to the strongest (x=1), which plays without any.
+	elo = max(MinElo, min(elo, MaxElo))
+	x := float64(elo-MinElo) / float64(MaxElo-MinElo)
+	return strength{
+		depth:   1 + int(x*12),
+		nodes:   uint64(400 * math.Pow(2, x*14)),
+		noise:   int(150 * (1 - x)),
+		spread:  int(120 * (1 - x) * (1 - x)),
+		blunder: 0.2 * (1 - x) * (1 - x),
+	}
+}
+
+func (e *Engine) limitStrength() {
+	// caps the limits of the search about to start and picks a new
+	// noise seed for it
+	e.strength = strength{}
+	if !e.Options.LimitStrength {
+		return
+	}
+	e.strength = strengthFor(e.Options.Elo)
+	if e.limits.Depth == 0 || e.limits.Depth > e.strength.depth {
+		e.limits.Depth = e.strength.depth
+	}
+	if e.limits.Nodes == 0 || e.limits.Nodes > e.strength.nodes {
+		e.limits.Nodes = e.strength.nodes
+	}
+	e.noiseSeed = rand.Uint64()
+}
+
+func (e *Engine) evaluate(b *chess.Board, ply int) int {
+	// the static evaluation, blurred when playing weaker. the noise
+	// depends only on the position so the search stays consistent
+	// with itself.
+	score := 0
+	if e.nnue != nil {
+		score = e.nnue.Evaluate(&e.accumulators[ply], b.Turn)
+	} else {
+		score = Evaluate(b)
+	}
+	if e.strength.noise == 0 {
+		return score
+	}
+	h := (b.Hash() ^ e.noiseSeed) * 0x9E3779B97F4A7C15
+	h ^= h >> 29
+	return score + int(h%uint64(2*e.strength.noise+1)) - e.strength.noise
+}
+
+func (e *Engine) weakerMove(root *chess.Board, info SearchInfo) SearchInfo {
+	// instead of always playing the best move a limited engine picks
+	// among the moves that look nearly as good. now and then it only
+	// takes a careless look: one ply and the captures that follow,
+	// which misses quiet threats like forks and mates the way a
+	// hurried player does, and it favours the natural looking moves
+	// (captures, checks, moving forward) while overlooking retreats.
+	if e.strength.spread == 0 && e.strength.blunder == 0 || len(info.PV) == 0 {
+		return info
+	}
+	depth := min(e.rootDepth-1, 2)
+	careless := rand.Float64() < e.strength.blunder
+	if careless {
+		depth = 0
+	}
+
+	// the searches below run to completion, they're shallow enough
+	e.rootDepth = 1
+	e.path = append(e.path, root.Hash())
+	defer func() { e.path = e.path[:len(e.path)-1] }()
+
+	type candidate struct {
+		move  chess.Move
+		score int
+	}
+	var candidates []candidate
+	best := -Infinity
+	for _, m := range root.LegalMoves() {
+		if e.rootMoves != nil && !slices.Contains(e.rootMoves, m) {
+			continue
+		}
+		next := *root
+		next.MakeMove(m)
+		e.played(root, &next, 0)
+		score := -e.negamax(&next, depth, -Infinity, Infinity, 1, true)
+		if careless {
+			if root.IsCapture(m) || next.InCheck() {
+				score += 30
+			}
+			if forward := int(m.To/8) - int(m.From/8); root.Turn == chess.White && forward < 0 || root.Turn == chess.Black && forward > 0 {
+				score -= 50
+			}
+		}
+		candidates = append(candidates, candidate{m, score})
+		best = max(best, score)
+	}
+
+	// moves within the spread are weighted by how close they come to the best
+	spread := max(e.strength.spread, 1)
+	total := 0
+	for _, c := range candidates {
+		if loss := best - c.score; loss <= spread {
+			total += spread - loss + 1
+		}
+	}
+	pick := rand.IntN(total)
+	for _, c := range candidates {
+		loss := best - c.score
+		if loss > spread {
+			continue
+		}
+		pick -= spread - loss + 1
+		if pick < 0 {
+			if c.move != info.PV[0] {
+				info.PV = []chess.Move{c.move}
+				info.Score = c.score
+				info.Mate = mateIn(c.score)
+			}
+			break
+		}
+	}
+	return info
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	chess "chess/board"
+	"unsafe"
+)
+
+const (
+	flagExact uint8 = iota
+	flagLower
+	flagUpper
+)
+
+type ttEntry struct {
+	key   uint64
+	move  chess.Move
+	score int32
+	depth int8
+	flag  uint8
+}
+
+type transpositionTable struct {
+	entries []ttEntry
+	used    int
+}
+
+func newTranspositionTable(megabytes int) *transpositionTable {
+	// allocates a table using roughly the given amount of memory
+	size := max(megabytes, 1) * 1024 * 1024 / int(unsafe.Sizeof(ttEntry{}))
+	return &transpositionTable{entries: make([]ttEntry, size)}
+}
+
+func (tt *transpositionTable) probe(key uint64) (ttEntry, bool) {
+	e := tt.entries[key%uint64(len(tt.entries))]
+	return e, e.key == key
+}
+
+func (tt *transpositionTable) store(key uint64, move chess.Move, score, depth int, flag uint8) {
+	// replaces the slot unless it holds a deeper search of the same position
+	e := &tt.entries[key%uint64(len(tt.entries))]
+	if e.key == key && int(e.depth) > depth && flag != flagExact {
+		return
+	}
+	if e.key == 0 {
+		tt.used++
+	}
+	if move == chess.NullMove && e.key == key {
+		move = e.move
+	}
+	*e = ttEntry{key: key, move: move, score: int32(score), depth: int8(depth), flag: flag}
+}
+
+func (tt *transpositionTable) clear() {
+	clear(tt.entries)
+	tt.used = 0
+}
+
+func (tt *transpositionTable) hashfull() int {
+	// permille of the table in use, as reported by UCI
+	return tt.used * 1000 / len(tt.entries)
+}
+
+func scoreToTT(score, ply int) int {
+	// mate scores are stored relative to the node, not the root
+	if score > MateBound {
+		return score + ply
+	} else if score < -MateBound {
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score, ply int) int {
+	if score > MateBound {
+		return score - ply
+	} else if score < -MateBound {
+		return score + ply
+	}
+	return score
+}
@@ -0,0 +1,294 @@
+package explorer
+
+import (
+	"bufio"
+	chess "chess/board"
+	"chess/pgn"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// the store is a header followed by fixed size records sorted by
+// position hash and move, so a position is found by binary search
+// without reading the whole file
+const (
+	magic      = "CHESSEXP1\n"
+	recordSize = 24 // hash, from, to, promotion, padding, white, draws, black
+)
+
+// results of the games in which a move was played
+type Stats struct {
+	White int // games won by white
+	Draws int
+	Black int
+}
+
+func (s Stats) Games() int {
+	return s.White + s.Draws + s.Black
+}
+
+// a move played in a position and how those games ended
+type MoveStats struct {
+	Move chess.Move
+	Stats
+}
+
+type key struct {
+	hash      uint64
+	from, to  chess.Square
+	promotion chess.Piece
+}
+
+// collects the moves of games before they are written to a store
+type Builder struct {
+	MaxPly  int // moves deeper into a game are left out, 0 for all
+	Bad     int // games AddPGN couldn't read and left out
+	entries map[key]Stats
+}
+
+func NewBuilder(maxPly int) *Builder {
+	return &Builder{MaxPly: maxPly, entries: map[key]Stats{}}
+}
+
+func (b *Builder) AddGame(g *pgn.Game) error {
+	// replays g and counts its result for every move played.
+	// unfinished games tell nothing and are skipped.
+	if g.Result == chess.Unfinished || g.Result == "" {
+		return nil
+	}
+	board, err := g.StartBoard()
+	if err != nil {
+		return err
+	}
+	for i, m := range g.Moves {
+		if b.MaxPly > 0 && i >= b.MaxPly {
+			break
+		}
+		k := key{board.Hash(), m.Move.From, m.Move.To, m.Move.Promotion}
+		s := b.entries[k]
+		switch g.Result {
+		case chess.WhiteWon:
+			s.White++
+		case chess.BlackWon:
+			s.Black++
+		default:
+			s.Draws++
+		}
+		b.entries[k] = s
+		board.MakeMove(m.Move)
+	}
+	return nil
+}
+
+func (b *Builder) AddPGN(r io.Reader) (int, error) {
+	// adds every game of a PGN file, returning how many were read.
+	// games that can't be read are counted in Bad and left out.
+	games := 0
+	pr := pgn.NewReader(r)
+	for {
+		g, err := pr.Next()
+		if err == io.EOF {
+			return games, nil
+		} else if _, ok := err.(*pgn.GameError); ok {
+			b.Bad++
+			continue
+		} else if err != nil {
+			return games, err
+		}
+		if err := b.AddGame(g); err != nil {
+			// only a game whose start position can't be set up fails
+			b.Bad++
+			continue
+		}
+		games++
+	}
+}
+
+func (b *Builder) Load(path string) error {
+	// adds the entries of an existing store, so new games can be
+	// imported on top of it. a missing store is empty.
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if err := readHeader(r); err != nil {
+		return err
+	}
+	var record [recordSize]byte
+	for {
+		if _, err := io.ReadFull(r, record[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		k, s := decode(record[:])
+		old := b.entries[k]
+		b.entries[k] = Stats{old.White + s.White, old.Draws + s.Draws, old.Black + s.Black}
+	}
+}
+
+func (b *Builder) WriteFile(path string) error {
+	// writes the store, replacing any file at path
+	keys := make([]key, 0, len(b.entries))
+	for k := range b.entries {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, compareKeys)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(magic)
+	var record [recordSize]byte
+	for _, k := range keys {
+		encode(record[:], k, b.entries[k])
+		w.Write(record[:])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (b *Builder) Positions() int {
+	// the number of different position and move pairs collected
+	return len(b.entries)
+}
+
+func compareKeys(a, b key) int {
+	return cmp.Or(
+		cmp.Compare(a.hash, b.hash),
+		cmp.Compare(a.from, b.from),
+		cmp.Compare(a.to, b.to),
+		cmp.Compare(a.promotion, b.promotion),
+	)
+}
+
+func encode(record []byte, k key, s Stats) {
+	binary.LittleEndian.PutUint64(record[0:], k.hash)
+	record[8], record[9], record[10], record[11] = byte(k.from), byte(k.to), byte(k.promotion), 0
+	binary.LittleEndian.PutUint32(record[12:], uint32(s.White))
+	binary.LittleEndian.PutUint32(record[16:], uint32(s.Draws))
+	binary.LittleEndian.PutUint32(record[20:], uint32(s.Black))
+}
+
+func decode(record []byte) (key, Stats) {
+	k := key{
+		hash:      binary.LittleEndian.Uint64(record[0:]),
+		from:      chess.Square(record[8]),
+		to:        chess.Square(record[9]),
+		promotion: chess.Piece(record[10]),
+	}
+	s := Stats{
+		White: int(binary.LittleEndian.Uint32(record[12:])),
+		Draws: int(binary.LittleEndian.Uint32(record[16:])),
+		Black: int(binary.LittleEndian.Uint32(record[20:])),
+	}
+	return k, s
+}
+
+func readHeader(r io.Reader) error {
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != magic {
+		return fmt.Errorf("not an explorer store")
+	}
+	return nil
+}
+
+// a store opened for lookups
+type DB struct {
+	f       *os.File
+	records int64
+}
+
+func DefaultPath() (string, error) {
+	// where the TUI looks for the store unless told otherwise
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chess", "explorer.db"), nil
+}
+
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := readHeader(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &DB{f, (info.Size() - int64(len(magic))) / recordSize}, nil
+}
+
+func (db *DB) Close() error {
+	return db.f.Close()
+}
+
+func (db *DB) record(i int64) (key, Stats, error) {
+	var record [recordSize]byte
+	if _, err := db.f.ReadAt(record[:], int64(len(magic))+i*recordSize); err != nil {
+		return key{}, Stats{}, err
+	}
+	k, s := decode(record[:])
+	return k, s, nil
+}
+
+func (db *DB) Lookup(b *chess.Board) ([]MoveStats, error) {
+	// the moves played in b, the most played first. moves that are
+	// illegal in b, from another position with the same hash, are
+	// left out.
+	hash := b.Hash()
+	lo, hi := int64(0), db.records
+	for lo < hi {
+		mid := (lo + hi) / 2
+		k, _, err := db.record(mid)
+		if err != nil {
+			return nil, err
+		}
+		if k.hash < hash {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	legal := b.LegalMoves()
+	var moves []MoveStats
+	for i := lo; i < db.records; i++ {
+		k, s, err := db.record(i)
+		if err != nil {
+			return nil, err
+		}
+		if k.hash != hash {
+			break
+		}
+		for _, m := range legal {
+			if m.From == k.from && m.To == k.to && m.Promotion == k.promotion {
+				moves = append(moves, MoveStats{m, s})
+			}
+		}
+	}
+	slices.SortStableFunc(moves, func(a, b MoveStats) int { return b.Games() - a.Games() })
+	return moves, nil
+}
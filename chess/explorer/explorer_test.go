@@ -0,0 +1,40 @@
+package explorer
+
+import (
+	chess "chess/board"
+	"strings"
+	"testing"
+)
+
+func TestAddPGNSkipsBadGames(t *testing.T) {
+	// a game with an illegal move or a start position that can't be set
+	// up is counted and left out, and the games after it still go in
+	b := NewBuilder(0)
+	games, err := b.AddPGN(strings.NewReader(`[Event "a"]
+
+1. e4 e5 1-0
+
+[Event "b"]
+
+1. e4 Ke7 2. Kf3 1-0
+
+[Event "c"]
+[FEN "not a position"]
+
+0-1
+
+[Event "d"]
+
+1. e4 c5 1/2-1/2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if games != 2 || b.Bad != 2 {
+		t.Errorf("%d games read and %d bad, expected 2 and 2", games, b.Bad)
+	}
+	k := key{chess.NewBoard().Hash(), chess.NotationToIndex["e2"], chess.NotationToIndex["e4"], chess.Empty}
+	if s := b.entries[k]; s != (Stats{White: 1, Draws: 1}) {
+		t.Errorf("1. e4 counted %+v, expected a win and a draw", s)
+	}
+}
@@ -0,0 +1,159 @@
+package features
+
+import (
+	chess "chess/board"
+	"chess/engine"
+	"math/bits"
+)
+
+// Side holds what one side's pieces make of a position
+type Side struct {
+	Pawns    int `json:"pawns"`
+	Knights  int `json:"knights"`
+	Bishops  int `json:"bishops"`
+	Rooks    int `json:"rooks"`
+	Queens   int `json:"queens"`
+	Material int `json:"material"` // centipawns by the engine's weights
+
+	// squares each kind of piece can move to, captures included but
+	// pins, checks and castling left out
+	PawnMobility   int `json:"pawn_mobility"`
+	KnightMobility int `json:"knight_mobility"`
+	BishopMobility int `json:"bishop_mobility"`
+	RookMobility   int `json:"rook_mobility"`
+	QueenMobility  int `json:"queen_mobility"`
+	KingMobility   int `json:"king_mobility"`
+
+	PawnIslands int `json:"pawn_islands"` // groups of pawns on neighbouring files
+	PassedPawns int `json:"passed_pawns"`
+
+	// minor and major pieces attacking the squares around the enemy
+	// king, and how many of those squares they attack between them
+	KingAttackers   int `json:"king_attackers"`
+	KingZoneAttacks int `json:"king_zone_attacks"`
+
+	SemiOpenFiles        int `json:"semi_open_files"` // files with only their pawns
+	RooksOnOpenFiles     int `json:"rooks_on_open_files"`
+	RooksOnSemiOpenFiles int `json:"rooks_on_semi_open_files"`
+
+	// squares of the c-f files on our second to fourth ranks that our
+	// pawns don't stand on and theirs don't attack
+	Space int `json:"space"`
+}
+
+// Position holds the features of a position. Game and Result are only
+// set for positions taken from PGN games.
+type Position struct {
+	Game      int    `json:"game,omitempty"`
+	Ply       int    `json:"ply"`
+	FEN       string `json:"fen"`
+	Turn      string `json:"turn"` // "w" or "b"
+	Result    string `json:"result,omitempty"`
+	InCheck   bool   `json:"in_check"`
+	Phase     int    `json:"phase"` // 24 with all pieces on down to 0
+	OpenFiles int    `json:"open_files"`
+	White     Side   `json:"white"`
+	Black     Side   `json:"black"`
+}
+
+// the c-f files of the second to fourth ranks from each side
+var spaceZone = [2]chess.Bitboard{
+	(chess.FileC | chess.FileD | chess.FileE | chess.FileF) & (chess.Rank2 | chess.Rank3 | chess.Rank4),
+	(chess.FileC | chess.FileD | chess.FileE | chess.FileF) & (chess.Rank7 | chess.Rank6 | chess.Rank5),
+}
+
+func Extract(b *chess.Board) Position {
+	// the features of b, computed from its bitboards
+	p := Position{
+		Ply:     int(b.MoveCounter),
+		FEN:     b.ToFEN(),
+		Turn:    "w",
+		InCheck: b.InCheck(),
+		Phase:   engine.Phase(b),
+		White:   side(b, chess.White),
+		Black:   side(b, chess.Black),
+	}
+	if b.Turn == chess.Black {
+		p.Turn = "b"
+	}
+	pawns := b.PieceBB[chess.White][chess.Pawns] | b.PieceBB[chess.Black][chess.Pawns]
+	for f := range 8 {
+		if pawns&(chess.FileA<<f) == 0 {
+			p.OpenFiles++
+		}
+	}
+	return p
+}
+
+func side(b *chess.Board, c chess.Color) Side {
+	// the features of c's pieces
+	pieces := b.PieceBB[c]
+	own := b.ColorBB[c]
+	them := c.Other()
+	s := Side{
+		Pawns:   pieces[chess.Pawns].Count(),
+		Knights: pieces[chess.Knights].Count(),
+		Bishops: pieces[chess.Bishops].Count(),
+		Rooks:   pieces[chess.Rooks].Count(),
+		Queens:  pieces[chess.Queens].Count(),
+	}
+	for p := chess.Pawns; p <= chess.Queens; p++ {
+		s.Material += engine.Weights.Material[p] * pieces[p].Count()
+	}
+
+	kingSq := b.KingSquare(them)
+	zone := chess.PieceAttacks(chess.Kings, kingSq, 0) | 1<<kingSq
+	mobility := [7]*int{nil, &s.PawnMobility, &s.KnightMobility, &s.BishopMobility, &s.RookMobility, &s.QueenMobility, &s.KingMobility}
+	for p := chess.Pawns; p <= chess.Kings; p++ {
+		for bb := pieces[p]; bb != 0; bb &= bb - 1 {
+			sq := chess.Square(bits.TrailingZeros64(uint64(bb)))
+			if p == chess.Pawns {
+				*mobility[p] += chess.GetPawnMoves(sq, b.FullBB, c, b.ColorBB[them]).Count()
+				continue
+			}
+			attacks := chess.PieceAttacks(p, sq, b.FullBB)
+			*mobility[p] += (attacks &^ own).Count()
+			if p != chess.Kings && attacks&zone != 0 {
+				s.KingAttackers++
+				s.KingZoneAttacks += (attacks & zone).Count()
+			}
+		}
+	}
+
+	enemyPawns := b.PieceBB[them][chess.Pawns]
+	var files uint8
+	for bb := pieces[chess.Pawns]; bb != 0; bb &= bb - 1 {
+		sq := chess.Square(bits.TrailingZeros64(uint64(bb)))
+		files |= 1 << (sq % 8)
+		if engine.PassedMask(sq, c)&enemyPawns == 0 {
+			s.PassedPawns++
+		}
+	}
+	// an island starts at every pawn file whose left neighbour has none
+	s.PawnIslands = bits.OnesCount8(files &^ (files << 1))
+
+	for f := range 8 {
+		file := chess.FileA << f
+		if pieces[chess.Pawns]&file != 0 {
+			continue
+		}
+		rooks := (pieces[chess.Rooks] & file).Count()
+		if enemyPawns&file == 0 {
+			s.RooksOnOpenFiles += rooks
+		} else {
+			s.SemiOpenFiles++
+			s.RooksOnSemiOpenFiles += rooks
+		}
+	}
+
+	s.Space = (spaceZone[c] &^ pieces[chess.Pawns] &^ pawnAttacks(enemyPawns, them)).Count()
+	return s
+}
+
+func pawnAttacks(pawns chess.Bitboard, c chess.Color) chess.Bitboard {
+	// the squares pawns of colour c attack
+	if c == chess.White {
+		return ((pawns << 7) &^ chess.FileH) | ((pawns << 9) &^ chess.FileA)
+	}
+	return ((pawns >> 7) &^ chess.FileA) | ((pawns >> 9) &^ chess.FileH)
+}
@@ -0,0 +1,196 @@
+package features
+
+import (
+	"bytes"
+	chess "chess/board"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func extract(t *testing.T, fen string) Position {
+	t.Helper()
+	b, err := chess.NewBoardFromFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Extract(b)
+}
+
+func TestExtract(t *testing.T) {
+	// counted by hand on the board
+	for _, c := range []struct {
+		fen       string
+		openFiles int
+		white     Side
+		black     Side
+	}{
+		{
+			// Nd4 reaches 8 squares, Ra1 the a file and b1-d1, Qh4 g4-e4,
+			// h5-h8, h3-h2, g5-d8 and g3-f2, Bh1 the long diagonal. Qh4
+			// alone hits the black king's zone, on e7 and d8. c3 takes
+			// d2 from white's space, and leaves each side a semi-open file.
+			fen:       "4k3/8/8/8/3N3Q/2p5/1P6/R3K2B w - - 0 1",
+			openFiles: 6,
+			white: Side{
+				Pawns: 1, Knights: 1, Bishops: 1, Rooks: 1, Queens: 1,
+				PawnMobility: 3, KnightMobility: 8, BishopMobility: 7, RookMobility: 10, QueenMobility: 15, KingMobility: 5,
+				PawnIslands: 1, KingAttackers: 1, KingZoneAttacks: 2,
+				SemiOpenFiles: 1, RooksOnOpenFiles: 1, Space: 11,
+			},
+			black: Side{
+				Pawns: 1, PawnMobility: 2, KingMobility: 5,
+				PawnIslands: 1, SemiOpenFiles: 1, Space: 12,
+			},
+		},
+		{
+			// white's pawns make islands a-b, d and f-h, the a and b
+			// pawns passed; black's e and h pawns are both stopped.
+			// white's pawns hit c5, e5 and f6 in black's space.
+			fen:       "2k5/7p/4p3/5PP1/3P4/1P6/P6P/2K5 w - - 0 1",
+			openFiles: 1,
+			white: Side{
+				Pawns: 6, PawnMobility: 9, KingMobility: 5,
+				PawnIslands: 3, PassedPawns: 2, SemiOpenFiles: 1, Space: 11,
+			},
+			black: Side{
+				Pawns: 2, PawnMobility: 4, KingMobility: 5,
+				PawnIslands: 2, SemiOpenFiles: 5, Space: 8,
+			},
+		},
+		{
+			// rooks on the open b file and the semi-open f file, and
+			// knights on the king's zone from f6 (g8, h7) and g5 (f7,
+			// h7). e2 stops f7 and the knight g7's push, which takes it.
+			fen:       "6k1/5pp1/5N2/6N1/8/8/P3P3/1R3RK1 b - - 0 1",
+			openFiles: 4,
+			white: Side{
+				Pawns: 2, Knights: 2, Rooks: 2,
+				PawnMobility: 4, KnightMobility: 14, RookMobility: 18, KingMobility: 4,
+				PawnIslands: 2, PassedPawns: 1, KingAttackers: 2, KingZoneAttacks: 4,
+				SemiOpenFiles: 2, RooksOnOpenFiles: 1, RooksOnSemiOpenFiles: 1, Space: 11,
+			},
+			black: Side{
+				Pawns: 2, PawnMobility: 2, KingMobility: 3,
+				PawnIslands: 1, PassedPawns: 1, SemiOpenFiles: 2, Space: 11,
+			},
+		},
+	} {
+		p := extract(t, c.fen)
+		if p.OpenFiles != c.openFiles {
+			t.Errorf("%s: %d open files, expected %d", c.fen, p.OpenFiles, c.openFiles)
+		}
+		for _, s := range []struct {
+			name      string
+			got, want Side
+		}{{"white", p.White, c.white}, {"black", p.Black, c.black}} {
+			// material is the engine's to weigh, not counted here
+			s.got.Material = 0
+			if s.got != s.want {
+				t.Errorf("%s: %s\n%+v, expected\n%+v", c.fen, s.name, s.got, s.want)
+			}
+		}
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	// every row has a value for each column of the header, the side's
+	// ones under their json names
+	var buf bytes.Buffer
+	w := NewCSVWriter(&buf)
+	for _, fen := range []string{chess.StartFEN, "4k3/8/8/8/3N3Q/2p5/1P6/R3K2B w - - 0 1"} {
+		p := extract(t, fen)
+		if err := w.Write(&p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || len(records[0]) != 8+2*len(sideColumns) {
+		t.Fatalf("%d rows of %d columns", len(records), len(records[0]))
+	}
+
+	p := extract(t, "4k3/8/8/8/3N3Q/2p5/1P6/R3K2B w - - 0 1")
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range records[0] {
+		value := fields[name]
+		if prefix, column, ok := strings.Cut(name, "_"); ok && (prefix == "white" || prefix == "black") {
+			value = fields[prefix].(map[string]any)[column]
+		}
+		var want string
+		switch v := value.(type) {
+		case float64:
+			want = strconv.Itoa(int(v))
+		case bool:
+			want = strconv.FormatBool(v)
+		case string:
+			want = v
+		case nil:
+			// left out of the json when empty
+			if name == "game" {
+				want = "0"
+			}
+		}
+		if records[2][i] != want {
+			t.Errorf("column %s: %q, expected %q", name, records[2][i], want)
+		}
+	}
+}
+
+func TestWritePGNSkipsBadGames(t *testing.T) {
+	// a game that can't be read is left out without ending the file or
+	// taking a number
+	var buf bytes.Buffer
+	w := NewJSONWriter(&buf)
+	games, bad, err := WritePGN(strings.NewReader(`[Event "a"]
+
+1. e4 e5 1-0
+
+[Event "b"]
+
+1. e4 Ke7 2. Kf3 0-1
+
+[Event "c"]
+[FEN "not a position"]
+
+*
+
+[Event "d"]
+
+1. d4 1/2-1/2
+`), w, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if games != 12 || bad != 2 {
+		t.Errorf("last game %d with %d bad, expected 12 with 2", games, bad)
+	}
+	var numbers []int
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var p Position
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			t.Fatal(err)
+		}
+		numbers = append(numbers, p.Game)
+	}
+	if len(numbers) != 5 || numbers[0] != 11 || numbers[4] != 12 {
+		t.Errorf("positions of games %v, expected 3 of game 11 and 2 of game 12", numbers)
+	}
+}
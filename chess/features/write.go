@@ -0,0 +1,139 @@
+package features
+
+import (
+	"bufio"
+	"chess/pgn"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Writer writes positions' features one after another
+type Writer interface {
+	Write(p *Position) error
+	Flush() error
+}
+
+// the columns of a side, prefixed white_ or black_ in csv, in the order
+// of Side.values
+var sideColumns = []string{
+	"pawns", "knights", "bishops", "rooks", "queens", "material",
+	"pawn_mobility", "knight_mobility", "bishop_mobility", "rook_mobility", "queen_mobility", "king_mobility",
+	"pawn_islands", "passed_pawns", "king_attackers", "king_zone_attacks",
+	"semi_open_files", "rooks_on_open_files", "rooks_on_semi_open_files", "space",
+}
+
+func (s *Side) values() []int {
+	return []int{
+		s.Pawns, s.Knights, s.Bishops, s.Rooks, s.Queens, s.Material,
+		s.PawnMobility, s.KnightMobility, s.BishopMobility, s.RookMobility, s.QueenMobility, s.KingMobility,
+		s.PawnIslands, s.PassedPawns, s.KingAttackers, s.KingZoneAttacks,
+		s.SemiOpenFiles, s.RooksOnOpenFiles, s.RooksOnSemiOpenFiles, s.Space,
+	}
+}
+
+// CSVWriter writes a row for each position under a header of the
+// json field names
+type CSVWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *CSVWriter) Write(p *Position) error {
+	if !cw.header {
+		header := []string{"game", "ply", "fen", "turn", "result", "in_check", "phase", "open_files"}
+		for _, prefix := range []string{"white_", "black_"} {
+			for _, name := range sideColumns {
+				header = append(header, prefix+name)
+			}
+		}
+		if err := cw.w.Write(header); err != nil {
+			return err
+		}
+		cw.header = true
+	}
+	row := []string{
+		strconv.Itoa(p.Game), strconv.Itoa(p.Ply), p.FEN, p.Turn, p.Result,
+		strconv.FormatBool(p.InCheck), strconv.Itoa(p.Phase), strconv.Itoa(p.OpenFiles),
+	}
+	for _, s := range []*Side{&p.White, &p.Black} {
+		for _, v := range s.values() {
+			row = append(row, strconv.Itoa(v))
+		}
+	}
+	return cw.w.Write(row)
+}
+
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// JSONWriter writes each position as a JSON object on a line of its own
+type JSONWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	bw := bufio.NewWriter(w)
+	return &JSONWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (jw *JSONWriter) Write(p *Position) error {
+	return jw.enc.Encode(p)
+}
+
+func (jw *JSONWriter) Flush() error {
+	return jw.w.Flush()
+}
+
+func WritePGN(r io.Reader, w Writer, games int) (int, int, error) {
+	// writes every position of every game of a PGN file, the final ones
+	// included, numbering the games on from games. returns the number
+	// of the last game written and how many couldn't be read and were
+	// left out.
+	bad := 0
+	pr := pgn.NewReader(r)
+	for {
+		g, err := pr.Next()
+		if err == io.EOF {
+			return games, bad, nil
+		} else if _, ok := err.(*pgn.GameError); ok {
+			bad++
+			continue
+		} else if err != nil {
+			return games, bad, err
+		}
+		if _, err := g.StartBoard(); err != nil {
+			bad++
+			continue
+		}
+		games++
+		if err := WriteGame(g, games, w); err != nil {
+			return games, bad, fmt.Errorf("game %d: %v", games, err)
+		}
+	}
+}
+
+func WriteGame(g *pgn.Game, number int, w Writer) error {
+	boards, err := g.Positions()
+	if err != nil {
+		return err
+	}
+	for _, b := range boards {
+		p := Extract(b)
+		p.Game = number
+		p.Result = g.Result
+		if err := w.Write(&p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package mcts
+
+import (
+	chess "chess/board"
+	"chess/engine"
+	"math"
+)
+
+// what an evaluator makes of a position
+type Output struct {
+	// a probability for each of the chess.PolicySize moves, numbered
+	// from the side to move as chess.PolicyIndex does with black
+	// flipped. nil gives every legal move the same prior.
+	Policy []float32
+	// the expected result for the side to move, from -1 to 1
+	Value float32
+}
+
+// Evaluator scores the leaves of the tree, a batch at a time so a
+// network can take them together. it is called from every worker at
+// once and must be safe for that.
+type Evaluator interface {
+	Evaluate(batch []*chess.Board) []Output
+}
+
+// Uniform knows nothing: every move is as likely and every position
+// even, leaving the search to play the games out.
+type Uniform struct{}
+
+func (Uniform) Evaluate(batch []*chess.Board) []Output {
+	return make([]Output, len(batch))
+}
+
+// Handcrafted values positions with the engine's evaluation, with
+// uniform priors.
+type Handcrafted struct{}
+
+func (Handcrafted) Evaluate(batch []*chess.Board) []Output {
+	out := make([]Output, len(batch))
+	for i, b := range batch {
+		out[i].Value = float32(CentipawnsToValue(engine.Evaluate(b)))
+	}
+	return out
+}
+
+func CentipawnsToValue(cp int) float64 {
+	// squashes a centipawn score into -1..1, a pawn up being about 0.25
+	return math.Tanh(float64(cp) / 400)
+}
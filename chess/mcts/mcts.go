@@ -0,0 +1,391 @@
+package mcts
+
+import (
+	chess "chess/board"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+type Config struct {
+	CPuct float64 // weight of the priors against the values found
+
+	// noise mixed into the root's priors so self-play tries other moves,
+	// Epsilon of it drawn from a Dirichlet distribution. 0 for none.
+	DirichletAlpha   float64
+	DirichletEpsilon float64
+
+	Workers     int // goroutines searching the tree together
+	Batch       int // leaves each worker gathers before evaluating them
+	VirtualLoss int // losses counted on a path while its leaf is evaluated
+}
+
+// DefaultConfig has the settings of AlphaZero's chess, without noise.
+var DefaultConfig = Config{
+	CPuct:          1.5,
+	DirichletAlpha: 0.3,
+	Workers:        1,
+	Batch:          8,
+	VirtualLoss:    3,
+}
+
+type node struct {
+	move     chess.Move // the move leading here
+	prior    float64
+	visits   int
+	value    float64 // summed results for the player who made move
+	virtual  int     // visits of leaves still being evaluated
+	children []*node
+
+	expanded bool
+	pending  bool // waiting for the evaluator
+	checked  bool // terminal has been worked out
+	terminal bool
+	result   float64 // of a terminal position, for the side to move
+}
+
+// Search is a tree of PUCT search kept from move to move. its methods
+// are safe to call from several goroutines.
+type Search struct {
+	Config Config
+
+	eval      Evaluator
+	mu        sync.Mutex
+	evaluated *sync.Cond // signalled when a batch has been backed up
+	root      *node
+	board     chess.Board
+	history   []uint64 // hashes of the positions before board
+	inflight  int      // leaves being evaluated
+	noised    bool     // the root's priors have their noise
+}
+
+// a position reached by selection
+type leaf struct {
+	path  []*node // from the root, the leaf last
+	board chess.Board
+}
+
+func New(eval Evaluator, config Config) *Search {
+	// a search from the starting position
+	s := &Search{Config: config, eval: eval}
+	s.evaluated = sync.NewCond(&s.mu)
+	s.SetPosition(chess.NewBoard(), nil)
+	return s
+}
+
+func (s *Search) SetPosition(b *chess.Board, history []uint64) {
+	// starts a new tree on b. history holds the hashes of the game
+	// positions before it so repetitions are seen.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.root = &node{}
+	s.board = *b
+	s.history = slices.Clone(history)
+	s.noised = false
+}
+
+func (s *Search) Play(mv chess.Move) {
+	// moves the root along mv, keeping what was searched below it
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := &node{move: mv}
+	for _, c := range s.root.children {
+		if c.move == mv {
+			next = c
+			break
+		}
+	}
+	s.history = append(s.history, s.board.Hash())
+	s.board.MakeMove(mv)
+	s.root = next
+	s.noised = false
+}
+
+func (s *Search) Run(simulations int) {
+	// adds simulations playouts to the tree
+	s.mu.Lock()
+	target := s.root.visits + simulations
+	s.mu.Unlock()
+	var wg sync.WaitGroup
+	for range max(s.Config.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(target)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Search) work(target int) {
+	// gathers a batch of leaves, evaluates them and backs the values
+	// up, until the root has target visits. the virtual losses on the
+	// paths of pending leaves steer the next selections elsewhere.
+	for {
+		s.mu.Lock()
+		var leaves []leaf
+		for len(leaves) < max(s.Config.Batch, 1) && s.root.visits+s.inflight < target {
+			l, ok := s.selectLeaf()
+			if !ok {
+				break
+			}
+			n := l.path[len(l.path)-1]
+			if n.terminal {
+				s.backup(l.path, n.result, 0)
+				continue
+			}
+			n.pending = true
+			s.inflight++
+			leaves = append(leaves, l)
+		}
+		if len(leaves) == 0 {
+			if s.root.visits+s.inflight >= target && s.inflight == 0 {
+				s.mu.Unlock()
+				return
+			}
+			// every path ends at a leaf another worker is evaluating
+			s.evaluated.Wait()
+			s.mu.Unlock()
+			continue
+		}
+		s.mu.Unlock()
+
+		batch := make([]*chess.Board, len(leaves))
+		for i := range leaves {
+			batch[i] = &leaves[i].board
+		}
+		outputs := s.eval.Evaluate(batch)
+		children := make([][]*node, len(leaves))
+		for i, l := range leaves {
+			children[i] = newChildren(&l.board, outputs[i].Policy)
+		}
+
+		s.mu.Lock()
+		for i, l := range leaves {
+			n := l.path[len(l.path)-1]
+			n.children = children[i]
+			n.expanded, n.pending = true, false
+			s.backup(l.path, float64(outputs[i].Value), s.Config.VirtualLoss)
+		}
+		s.inflight -= len(leaves)
+		if s.root.expanded && !s.noised {
+			s.addNoise()
+		}
+		s.evaluated.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+func (s *Search) selectLeaf() (leaf, bool) {
+	// follows the best children down to a position not yet expanded,
+	// putting a virtual loss on the way. false if it is already being
+	// evaluated.
+	n := s.root
+	l := leaf{path: []*node{n}, board: s.board}
+	history := slices.Clip(s.history)
+	for n.expanded {
+		n = s.pick(n)
+		history = append(history, l.board.Hash())
+		l.board.MakeMove(n.move)
+		l.path = append(l.path, n)
+	}
+	if n.pending {
+		return leaf{}, false
+	}
+	if !n.checked {
+		n.checked = true
+		switch outcome := l.board.Outcome(history); {
+		case !outcome.Over():
+		case outcome.Result == chess.Drawn:
+			n.terminal = true
+		default:
+			// the side to move has been mated
+			n.terminal, n.result = true, -1
+		}
+	}
+	if !n.terminal {
+		for _, p := range l.path {
+			p.virtual += s.Config.VirtualLoss
+		}
+	}
+	return l, true
+}
+
+func (s *Search) pick(n *node) *node {
+	// the child with the highest upper confidence bound
+	parent := math.Sqrt(float64(max(n.visits+n.virtual, 1)))
+	var best *node
+	bestScore := math.Inf(-1)
+	for _, c := range n.children {
+		visits := float64(c.visits + c.virtual)
+		q := 0.0
+		if visits > 0 {
+			q = (c.value - float64(c.virtual)) / visits
+		}
+		score := q + s.Config.CPuct*c.prior*parent/(1+visits)
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
+
+func (s *Search) backup(path []*node, value float64, virtual int) {
+	// adds value, for the side to move at the leaf, to every node on
+	// the path from the point of view of the player moving into it
+	for i := len(path) - 1; i >= 0; i-- {
+		value = -value
+		n := path[i]
+		n.visits++
+		n.value += value
+		n.virtual -= virtual
+	}
+}
+
+func newChildren(b *chess.Board, policy []float32) []*node {
+	// the legal moves of b with their priors taken from policy
+	moves := b.LegalMoves()
+	children := make([]*node, len(moves))
+	sum := 0.0
+	for i, mv := range moves {
+		children[i] = &node{move: mv}
+		if policy != nil {
+			index, _ := chess.PolicyIndex(mv, b.Turn == chess.Black)
+			children[i].prior = float64(policy[index])
+			sum += children[i].prior
+		}
+	}
+	for _, c := range children {
+		if sum > 0 {
+			c.prior /= sum
+		} else {
+			c.prior = 1 / float64(len(children))
+		}
+	}
+	return children
+}
+
+func (s *Search) addNoise() {
+	// mixes Dirichlet noise into the priors of the root's children
+	s.noised = true
+	eps := s.Config.DirichletEpsilon
+	children := s.root.children
+	if eps <= 0 || len(children) == 0 {
+		return
+	}
+	noise := make([]float64, len(children))
+	sum := 0.0
+	for i := range noise {
+		noise[i] = gamma(s.Config.DirichletAlpha)
+		sum += noise[i]
+	}
+	for i, c := range children {
+		c.prior = (1-eps)*c.prior + eps*noise[i]/sum
+	}
+}
+
+func gamma(alpha float64) float64 {
+	// a sample of the gamma distribution with scale 1, by Marsaglia and
+	// Tsang's method, boosted for alpha below 1
+	if alpha < 1 {
+		return gamma(alpha+1) * math.Pow(rand.Float64(), 1/alpha)
+	}
+	d := alpha - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rand.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rand.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < x*x/2+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
+
+// a move of the root and what the search found of it
+type Child struct {
+	Move   chess.Move
+	Visits int
+	Value  float64 // average result for the side to move
+	Prior  float64
+}
+
+func (s *Search) Children() []Child {
+	// the root's moves, the most visited first
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	children := make([]Child, len(s.root.children))
+	for i, c := range s.root.children {
+		children[i] = Child{c.move, c.visits, c.value / float64(max(c.visits, 1)), c.prior}
+	}
+	slices.SortStableFunc(children, func(a, b Child) int { return b.Visits - a.Visits })
+	return children
+}
+
+func (s *Search) Visits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.root.visits
+}
+
+func (s *Search) Value() float64 {
+	// the average result of the search for the side to move at the root
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return -s.root.value / float64(max(s.root.visits, 1))
+}
+
+func (s *Search) BestMove() chess.Move {
+	// the most visited move, NullMove when nothing has been searched
+	children := s.Children()
+	if len(children) == 0 {
+		return chess.NullMove
+	}
+	return children[0].Move
+}
+
+func (s *Search) Policy() []float32 {
+	// the share of the visits each move got, laid out like the network's
+	// policy output from the side to move
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policy := make([]float32, chess.PolicySize)
+	total := 0
+	for _, c := range s.root.children {
+		total += c.visits
+	}
+	for _, c := range s.root.children {
+		index, _ := chess.PolicyIndex(c.move, s.board.Turn == chess.Black)
+		policy[index] = float32(c.visits) / float32(max(total, 1))
+	}
+	return policy
+}
+
+func (s *Search) SelectMove(temperature float64) chess.Move {
+	// picks a move with a chance of its visits to the power of
+	// 1/temperature, the most visited one for temperature 0
+	children := s.Children()
+	if temperature <= 0 || len(children) == 0 || children[0].Visits == 0 {
+		return s.BestMove()
+	}
+	weights := make([]float64, len(children))
+	sum := 0.0
+	for i, c := range children {
+		// relative to the most visited so the powers stay finite
+		weights[i] = math.Pow(float64(c.Visits)/float64(children[0].Visits), 1/temperature)
+		sum += weights[i]
+	}
+	r := rand.Float64() * sum
+	for i, w := range weights {
+		if r < w {
+			return children[i].Move
+		}
+		r -= w
+	}
+	return children[0].Move
+}
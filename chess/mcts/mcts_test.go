@@ -0,0 +1,126 @@
+package mcts
+
+import (
+	chess "chess/board"
+	"math"
+	"testing"
+)
+
+func searchFrom(t *testing.T, fen string, config Config) *Search {
+	t.Helper()
+	b, err := chess.NewBoardFromFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(Uniform{}, config)
+	s.SetPosition(b, nil)
+	return s
+}
+
+func TestFindsMateInOne(t *testing.T) {
+	// with nothing known of the positions the mate still stands out,
+	// being the only move whose value isn't even
+	for _, c := range []struct{ fen, move string }{
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8"},
+		{"r5k1/8/8/8/8/8/5PPP/6K1 b - - 0 1", "a8a1"},
+	} {
+		s := searchFrom(t, c.fen, DefaultConfig)
+		s.Run(800)
+		if m := s.BestMove(); m.String() != c.move {
+			t.Errorf("%s: played %v, expected %s", c.fen, m, c.move)
+		}
+		if s.Value() < 0.5 {
+			t.Errorf("%s: value %.2f, expected near 1", c.fen, s.Value())
+		}
+	}
+}
+
+func TestTerminalRoot(t *testing.T) {
+	for _, c := range []struct {
+		fen   string
+		value float64
+	}{
+		{"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1", -1}, // mated
+		{"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", 0},  // stalemated
+	} {
+		s := searchFrom(t, c.fen, DefaultConfig)
+		s.Run(50)
+		if s.Visits() != 50 || s.Value() != c.value || s.BestMove() != chess.NullMove || len(s.Children()) != 0 {
+			t.Errorf("%s: %d visits, value %v, move %v", c.fen, s.Visits(), s.Value(), s.BestMove())
+		}
+	}
+}
+
+func TestPlayKeepsSubtree(t *testing.T) {
+	s := New(Uniform{}, DefaultConfig)
+	s.Run(400)
+	best := s.Children()[0]
+	s.Play(best.Move)
+	if s.Visits() != best.Visits {
+		t.Errorf("%d visits kept below %v, expected %d", s.Visits(), best.Move, best.Visits)
+	}
+	s.Run(100)
+	if s.Visits() != best.Visits+100 {
+		t.Errorf("%d visits after 100 more, expected %d", s.Visits(), best.Visits+100)
+	}
+
+	// a move the search never tried starts over
+	s = New(Uniform{}, DefaultConfig)
+	s.Run(5)
+	children := s.Children()
+	last := children[len(children)-1]
+	if last.Visits != 0 {
+		t.Fatalf("%v visited with 20 moves and 5 simulations", last.Move)
+	}
+	s.Play(last.Move)
+	if s.Visits() != 0 {
+		t.Errorf("%d visits below an unsearched move", s.Visits())
+	}
+}
+
+func TestVisitsWithWorkers(t *testing.T) {
+	// the workers together stop at exactly the visits asked for, run
+	// with -race to check they share the tree safely
+	config := DefaultConfig
+	config.Workers = 4
+	config.Batch = 4
+	config.DirichletEpsilon = 0.25
+	s := New(Uniform{}, config)
+	for _, n := range []int{1, 7, 256, 1000} {
+		before := s.Visits()
+		s.Run(n)
+		if s.Visits() != before+n {
+			t.Errorf("%d visits after %d more simulations, expected %d", s.Visits(), n, before+n)
+		}
+	}
+	total := 0
+	for _, c := range s.Children() {
+		total += c.Visits
+	}
+	if total != s.Visits()-1 {
+		t.Errorf("children have %d visits, root %d", total, s.Visits())
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	// the visit shares sum to 1, every one on a legal move
+	for _, fen := range []string{
+		chess.StartFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R b KQkq - 0 1",
+	} {
+		s := searchFrom(t, fen, DefaultConfig)
+		s.Run(300)
+		b, _ := chess.NewBoardFromFEN(fen)
+		legal := b.PolicyMask(b.Turn == chess.Black)
+		sum := 0.0
+		for i, p := range s.Policy() {
+			if p != 0 && !legal[i] {
+				t.Errorf("%s: share %v on %s, not a legal move", fen, p, chess.PolicyLabel(i, b.Turn == chess.Black))
+			}
+			sum += float64(p)
+		}
+		if math.Abs(sum-1) > 1e-5 {
+			t.Errorf("%s: policy sums to %v", fen, sum)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package nn
+
+import (
+	chess "chess/board"
+	"chess/mcts"
+	"runtime"
+	"sync"
+)
+
+func (n *Network) Predict(b *chess.Board) mcts.Output {
+	// the network's view of b, seen from the side to move as it was
+	// trained
+	policy, value := n.Forward(chess.ChannelsLast(b.Encode(chess.TensorOptions{Relative: true}, nil)))
+	return mcts.Output{Policy: policy, Value: value}
+}
+
+func (n *Network) Evaluate(batch []*chess.Board) []mcts.Output {
+	// runs the positions of batch on every core, making the network an
+	// mcts.Evaluator
+	out := make([]mcts.Output, len(batch))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(batch)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				out[i] = n.Predict(batch[i])
+			}
+		}()
+	}
+	for i := range batch {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return out
+}
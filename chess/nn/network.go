@@ -0,0 +1,232 @@
+package nn
+
+import (
+	"bufio"
+	chess "chess/board"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// the network of cnn.py's create_chess_model, as export_weights dumps
+// it: the magic "CHESSNET1\n", then seven uint32s giving the input
+// planes, the tower's filters, its residual blocks, the channels of the
+// policy and value convolutions, the value head's hidden units and the
+// policy outputs, then the layers as little-endian float32s:
+//
+//	the input convolution and its batch norm
+//	two convolutions with their batch norms for each block
+//	the policy convolution, batch norm and dense layer
+//	the value convolution, batch norm and both dense layers
+//
+// a convolution is its kernel, [height][width][in][out] as Keras keeps
+// it, then its bias [out]. a batch norm is gamma, beta, the moving mean
+// and variance, each [channels], then its epsilon. a dense layer is its
+// kernel [in][out] then its bias [out].
+const magic = "CHESSNET1\n"
+
+// a convolution with its batch norm folded in, over the 64 squares
+type conv struct {
+	size    int // of the kernel, 1 or 3
+	in, out int
+	weights []float32 // [size][size][in][out]
+	bias    []float32
+}
+
+type dense struct {
+	in, out int
+	weights []float32 // [in][out]
+	bias    []float32
+}
+
+type block struct {
+	first, second conv
+}
+
+type Network struct {
+	Planes     int
+	Filters    int
+	PolicySize int
+
+	input       conv
+	tower       []block
+	policyConv  conv
+	policy      dense
+	valueConv   conv
+	valueHidden dense
+	value       dense
+}
+
+func Load(path string) (*Network, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(bufio.NewReader(f))
+}
+
+func Read(r io.Reader) (*Network, error) {
+	// reads weights in the format above
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, head); err != nil || string(head) != magic {
+		return nil, fmt.Errorf("not a network file")
+	}
+	var sizes [7]uint32
+	if err := binary.Read(r, binary.LittleEndian, &sizes); err != nil {
+		return nil, err
+	}
+	planes, filters, blocks := int(sizes[0]), int(sizes[1]), int(sizes[2])
+	policyChannels, valueChannels, hidden, policySize := int(sizes[3]), int(sizes[4]), int(sizes[5]), int(sizes[6])
+	if planes != chess.PositionPlanes || policySize != chess.PolicySize || filters > 4096 || blocks > 256 || hidden > 65536 {
+		return nil, fmt.Errorf("network of %d planes and %d moves, %d filters and %d blocks doesn't fit the board",
+			planes, policySize, filters, blocks)
+	}
+
+	rd := reader{r: r}
+	n := &Network{Planes: planes, Filters: filters, PolicySize: policySize}
+	n.input = rd.conv(3, planes, filters)
+	n.tower = make([]block, blocks)
+	for i := range n.tower {
+		n.tower[i] = block{rd.conv(3, filters, filters), rd.conv(3, filters, filters)}
+	}
+	n.policyConv = rd.conv(1, filters, policyChannels)
+	n.policy = rd.dense(64*policyChannels, policySize)
+	n.valueConv = rd.conv(1, filters, valueChannels)
+	n.valueHidden = rd.dense(64*valueChannels, hidden)
+	n.value = rd.dense(hidden, 1)
+	if rd.err != nil {
+		return nil, fmt.Errorf("reading network: %v", rd.err)
+	}
+	return n, nil
+}
+
+// reads the layers one after another, keeping the first error
+type reader struct {
+	r   io.Reader
+	err error
+}
+
+func (rd *reader) floats(n int) []float32 {
+	out := make([]float32, n)
+	if rd.err == nil {
+		rd.err = binary.Read(rd.r, binary.LittleEndian, out)
+	}
+	return out
+}
+
+func (rd *reader) conv(size, in, out int) conv {
+	// a convolution and the batch norm after it, folded into one:
+	// gamma*(x-mean)/sqrt(variance+epsilon)+beta scales the weights and
+	// shifts the bias
+	c := conv{size: size, in: in, out: out, weights: rd.floats(size * size * in * out), bias: rd.floats(out)}
+	gamma, beta, mean, variance := rd.floats(out), rd.floats(out), rd.floats(out), rd.floats(out)
+	epsilon := rd.floats(1)[0]
+	for o := range out {
+		scale := gamma[o] / float32(math.Sqrt(float64(variance[o]+epsilon)))
+		for i := o; i < len(c.weights); i += out {
+			c.weights[i] *= scale
+		}
+		c.bias[o] = (c.bias[o]-mean[o])*scale + beta[o]
+	}
+	return c
+}
+
+func (rd *reader) dense(in, out int) dense {
+	return dense{in: in, out: out, weights: rd.floats(in * out), bias: rd.floats(out)}
+}
+
+func (c *conv) apply(x []float32) []float32 {
+	// convolves x, [64][in] squares by channels, padding the board with
+	// zeros, into [64][out]
+	y := make([]float32, 64*c.out)
+	for sq := range 64 {
+		copy(y[sq*c.out:(sq+1)*c.out], c.bias)
+	}
+	half := c.size / 2
+	for sq := range 64 {
+		row, col := sq/8, sq%8
+		out := y[sq*c.out : (sq+1)*c.out]
+		for dr := range c.size {
+			for dc := range c.size {
+				r, cl := row+dr-half, col+dc-half
+				if r < 0 || r >= 8 || cl < 0 || cl >= 8 {
+					continue
+				}
+				in := x[(r*8+cl)*c.in : (r*8+cl+1)*c.in]
+				tap := c.weights[(dr*c.size+dc)*c.in*c.out:]
+				for i, v := range in {
+					if v == 0 {
+						continue
+					}
+					saxpy(out, tap[i*c.out:(i+1)*c.out], v)
+				}
+			}
+		}
+	}
+	return y
+}
+
+func (d *dense) apply(x []float32) []float32 {
+	y := make([]float32, d.out)
+	copy(y, d.bias)
+	for i, v := range x {
+		if v != 0 {
+			saxpy(y, d.weights[i*d.out:(i+1)*d.out], v)
+		}
+	}
+	return y
+}
+
+func saxpy(y, w []float32, a float32) {
+	// y += a*w
+	w = w[:len(y)]
+	for i := range y {
+		y[i] += a * w[i]
+	}
+}
+
+func relu(x []float32) []float32 {
+	for i, v := range x {
+		x[i] = max(v, 0)
+	}
+	return x
+}
+
+func (n *Network) Forward(input []float32) (policy []float32, value float32) {
+	// runs the network on one position, its planes last as
+	// chess.ChannelsLast gives them. the policy is softmaxed over every
+	// output, the value is from the side the input is seen from.
+	x := relu(n.input.apply(input))
+	for _, b := range n.tower {
+		y := relu(b.first.apply(x))
+		y = b.second.apply(y)
+		for i := range y {
+			y[i] += x[i]
+		}
+		x = relu(y)
+	}
+	policy = softmax(n.policy.apply(relu(n.policyConv.apply(x))))
+	hidden := relu(n.valueHidden.apply(relu(n.valueConv.apply(x))))
+	value = float32(math.Tanh(float64(n.value.apply(hidden)[0])))
+	return policy, value
+}
+
+func softmax(x []float32) []float32 {
+	top := x[0]
+	for _, v := range x {
+		top = max(top, v)
+	}
+	sum := 0.0
+	for i, v := range x {
+		e := math.Exp(float64(v - top))
+		x[i] = float32(e)
+		sum += e
+	}
+	for i := range x {
+		x[i] = float32(float64(x[i]) / sum)
+	}
+	return x
+}
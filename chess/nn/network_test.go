@@ -0,0 +1,126 @@
+package nn
+
+import (
+	"bytes"
+	chess "chess/board"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"testing"
+)
+
+// the network testdata/reference.py computed reference.json with
+const (
+	testFilters        = 8
+	testBlocks         = 1
+	testPolicyChannels = 2
+	testValueChannels  = 1
+	testHidden         = 16
+	testInputs         = 2
+)
+
+// the generator reference.py draws the weights and inputs from, with
+// 23 bits after the point so float32 holds them exactly
+type generator uint32
+
+func (g *generator) uniform() float32 {
+	*g = *g*1664525 + 1013904223
+	return float32(*g>>9) / (1 << 23)
+}
+
+func (g *generator) weights(n int, scale float32) []float32 {
+	w := make([]float32, n)
+	for i := range w {
+		w[i] = (g.uniform() - 0.5) * scale
+	}
+	return w
+}
+
+func writeConv(buf *bytes.Buffer, g *generator, size, in, out int) {
+	// a convolution and its batch norm, drawn in reference.py's order
+	floats := g.weights(size*size*in*out, 0.5)
+	floats = append(floats, g.weights(out, 0.25)...)
+	for range out {
+		floats = append(floats, 0.5+g.uniform())
+	}
+	floats = append(floats, g.weights(out, 0.25)...)
+	floats = append(floats, g.weights(out, 0.25)...)
+	for range out {
+		floats = append(floats, 0.5+g.uniform())
+	}
+	floats = append(floats, 0.001)
+	binary.Write(buf, binary.LittleEndian, floats)
+}
+
+func writeDense(buf *bytes.Buffer, g *generator, in, out int, scale float32) {
+	binary.Write(buf, binary.LittleEndian, g.weights(in*out, scale))
+	binary.Write(buf, binary.LittleEndian, g.weights(out, 0.25))
+}
+
+func TestForwardMatchesReference(t *testing.T) {
+	// a small network dumped as export_weights would, run on the inputs
+	// reference.py used: the batch norms folded into the convolutions
+	// must give what Keras' layers give one after another
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	binary.Write(&buf, binary.LittleEndian, [7]uint32{chess.PositionPlanes, testFilters, testBlocks,
+		testPolicyChannels, testValueChannels, testHidden, chess.PolicySize})
+	g := generator(1)
+	writeConv(&buf, &g, 3, chess.PositionPlanes, testFilters)
+	for range 2 * testBlocks {
+		writeConv(&buf, &g, 3, testFilters, testFilters)
+	}
+	writeConv(&buf, &g, 1, testFilters, testPolicyChannels)
+	writeDense(&buf, &g, 64*testPolicyChannels, chess.PolicySize, 2)
+	writeConv(&buf, &g, 1, testFilters, testValueChannels)
+	writeDense(&buf, &g, 64*testValueChannels, testHidden, 1)
+	writeDense(&buf, &g, testHidden, 1, 1)
+	n, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile("testdata/reference.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reference []struct {
+		Policy []float64
+		Value  float64
+	}
+	if err := json.Unmarshal(data, &reference); err != nil {
+		t.Fatal(err)
+	}
+	if len(reference) != testInputs {
+		t.Fatalf("%d reference outputs, expected %d", len(reference), testInputs)
+	}
+	for k, want := range reference {
+		input := make([]float32, 0, 64*chess.PositionPlanes)
+		for range 64 {
+			for p := range chess.PositionPlanes {
+				switch u := g.uniform(); {
+				case p == chess.PositionPlanes-1:
+					input = append(input, u)
+				case u < 0.1:
+					input = append(input, 1)
+				default:
+					input = append(input, 0)
+				}
+			}
+		}
+		policy, value := n.Forward(input)
+		if math.Abs(float64(value)-want.Value) > 1e-5 {
+			t.Errorf("input %d: value %v, expected %v", k, value, want.Value)
+		}
+		if len(policy) != len(want.Policy) {
+			t.Fatalf("input %d: %d policy outputs, expected %d", k, len(policy), len(want.Policy))
+		}
+		for i, p := range policy {
+			if math.Abs(float64(p)-want.Policy[i]) > 1e-5*want.Policy[i]+1e-8 {
+				t.Errorf("input %d: policy output %d is %v, expected %v", k, i, p, want.Policy[i])
+				break
+			}
+		}
+	}
+}
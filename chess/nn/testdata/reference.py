@@ -0,0 +1,133 @@
+"""Writes reference.json, the outputs network_test.go checks Forward against.
+
+The weights and inputs come from the same linear congruential generator
+the test uses, so only the outputs need keeping. The forward pass here
+follows Keras' layers one by one, batch norms applied as they are at
+inference rather than folded into the convolutions, and plain Python so
+that it runs without TensorFlow or NumPy:
+
+    python3 nn/testdata/reference.py
+"""
+
+import json
+import math
+import os
+import struct
+
+PLANES, FILTERS, BLOCKS = 19, 8, 1
+POLICY_CHANNELS, VALUE_CHANNELS, HIDDEN, POLICY_SIZE = 2, 1, 16, 1968
+EPSILON = struct.unpack("<f", struct.pack("<f", 0.001))[0]
+INPUTS = 2
+
+
+class Generator:
+    # numbers with 23 bits after the point, exact as float32
+    def __init__(self, seed):
+        self.state = seed
+
+    def uniform(self):
+        self.state = (self.state * 1664525 + 1013904223) % (1 << 32)
+        return (self.state >> 9) / (1 << 23)
+
+    def weights(self, n, scale):
+        return [(self.uniform() - 0.5) * scale for _ in range(n)]
+
+
+def conv_layer(g, size, cin, cout):
+    kernel = g.weights(size * size * cin * cout, 0.5)
+    bias = g.weights(cout, 0.25)
+    gamma = [0.5 + g.uniform() for _ in range(cout)]
+    beta = g.weights(cout, 0.25)
+    mean = g.weights(cout, 0.25)
+    variance = [0.5 + g.uniform() for _ in range(cout)]
+    return size, cin, cout, kernel, bias, gamma, beta, mean, variance
+
+
+def dense_layer(g, cin, cout, scale):
+    return cin, cout, g.weights(cin * cout, scale), g.weights(cout, 0.25)
+
+
+def conv2d(x, layer):
+    # Conv2D with padding="same", then BatchNormalization at inference,
+    # x being [8][8][channels] flattened
+    size, cin, cout, kernel, bias, gamma, beta, mean, variance = layer
+    half = size // 2
+    y = []
+    for row in range(8):
+        for col in range(8):
+            for o in range(cout):
+                total = bias[o]
+                for dr in range(size):
+                    for dc in range(size):
+                        r, c = row + dr - half, col + dc - half
+                        if not (0 <= r < 8 and 0 <= c < 8):
+                            continue
+                        for i in range(cin):
+                            total += (
+                                x[(r * 8 + c) * cin + i]
+                                * kernel[((dr * size + dc) * cin + i) * cout + o]
+                            )
+                total = (total - mean[o]) / math.sqrt(variance[o] + EPSILON)
+                y.append(total * gamma[o] + beta[o])
+    return y
+
+
+def dense(x, layer):
+    cin, cout, kernel, bias = layer
+    return [
+        bias[o] + sum(x[i] * kernel[i * cout + o] for i in range(cin))
+        for o in range(cout)
+    ]
+
+
+def relu(x):
+    return [max(v, 0.0) for v in x]
+
+
+def main():
+    g = Generator(1)
+    stem = conv_layer(g, 3, PLANES, FILTERS)
+    tower = [
+        (conv_layer(g, 3, FILTERS, FILTERS), conv_layer(g, 3, FILTERS, FILTERS))
+        for _ in range(BLOCKS)
+    ]
+    policy_conv = conv_layer(g, 1, FILTERS, POLICY_CHANNELS)
+    policy_dense = dense_layer(g, 64 * POLICY_CHANNELS, POLICY_SIZE, 2)
+    value_conv = conv_layer(g, 1, FILTERS, VALUE_CHANNELS)
+    value_hidden = dense_layer(g, 64 * VALUE_CHANNELS, HIDDEN, 1)
+    value_dense = dense_layer(g, HIDDEN, 1, 1)
+
+    outputs = []
+    for _ in range(INPUTS):
+        # channels last, sparse ones like the piece planes and fractions
+        # in the last plane like the move count
+        x = []
+        for _ in range(64):
+            for p in range(PLANES):
+                u = g.uniform()
+                x.append(u if p == PLANES - 1 else float(u < 0.1))
+        h = relu(conv2d(x, stem))
+        for first, second in tower:
+            y = conv2d(relu(conv2d(h, first)), second)
+            h = relu([a + b for a, b in zip(y, h)])
+        logits = dense(relu(conv2d(h, policy_conv)), policy_dense)
+        top = max(logits)
+        exps = [math.exp(v - top) for v in logits]
+        total = sum(exps)
+        hidden = relu(dense(relu(conv2d(h, value_conv)), value_hidden))
+        value = math.tanh(dense(hidden, value_dense)[0])
+        outputs.append(
+            {
+                "policy": [float("%.7g" % (e / total)) for e in exps],
+                "value": float("%.7g" % value),
+            }
+        )
+
+    path = os.path.join(os.path.dirname(os.path.abspath(__file__)), "reference.json")
+    with open(path, "w") as f:
+        json.dump(outputs, f)
+        f.write("\n")
+
+
+if __name__ == "__main__":
+    main()
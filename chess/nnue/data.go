@@ -0,0 +1,31 @@
+package nnue
+
+import (
+	chess "chess/board"
+	"fmt"
+	"io"
+)
+
+// positions for training are written one to a line as
+//
+//	<fen> | <score> | <result>
+//
+// the score in centipawns from white's side and the result of the game
+// for white, 1.0, 0.5 or 0.0, the text format most NNUE trainers read.
+// the inputs of a position are Features of it from each side.
+
+func WriteSample(w io.Writer, b *chess.Board, score int, result string) error {
+	// writes b with score, from the side to move, and the game's result
+	if b.Turn == chess.Black {
+		score = -score
+	}
+	wdl := "0.5"
+	switch result {
+	case chess.WhiteWon:
+		wdl = "1.0"
+	case chess.BlackWon:
+		wdl = "0.0"
+	}
+	_, err := fmt.Fprintf(w, "%s | %d | %s\n", b.ToFEN(), score, wdl)
+	return err
+}
@@ -0,0 +1,230 @@
+package nnue
+
+import (
+	"bufio"
+	chess "chess/board"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// the network sees the board twice, once from each side, through 768
+// features per king bucket: a piece of one of the 12 kinds on one of
+// the 64 squares. from black's side the board is mirrored and its
+// pieces count as "ours", so both halves are alike. the feature of a
+// piece is
+//
+//	bucket*768 + (side*6 + piece-1)*64 + square
+//
+// with side 0 for our pieces and 1 for theirs, piece from chess.Pawns
+// to chess.Kings and square mirrored (sq^56) from black's side. the
+// bucket is our king's quarter of the (mirrored) board: 0 for the a-d
+// files of the first two ranks, 1 for e-h there, 2 and 3 further up.
+//
+// each side sums the weights of its features into an accumulator of
+// Hidden values. the side to move's accumulator and then the other's,
+// clipped to 0..QA, are dotted with the output weights, which with the
+// output bias and times Scale/(QA*QB) gives centipawns for the side to
+// move.
+const (
+	Buckets = 4
+	Inputs  = Buckets * 768
+
+	QA    = 255 // the feature weights are the trained ones times QA
+	QB    = 64  // the output weights times QB
+	Scale = 400 // centipawns per unit of the trained output
+)
+
+// the weights file is the magic "CHESSNNUE1\n", the uint32s Inputs and
+// Hidden, then little-endian int16 feature weights [Inputs][Hidden],
+// int16 feature biases [Hidden], int16 output weights [2*Hidden] and
+// an int32 output bias, quantized by QA, QB and QA*QB
+const magic = "CHESSNNUE1\n"
+
+type Network struct {
+	Hidden         int
+	FeatureWeights []int16
+	FeatureBias    []int16
+	OutputWeights  []int16
+	OutputBias     int32
+}
+
+func Load(path string) (*Network, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(bufio.NewReader(f))
+}
+
+func Read(r io.Reader) (*Network, error) {
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, head); err != nil || string(head) != magic {
+		return nil, fmt.Errorf("not an nnue file")
+	}
+	var sizes [2]uint32
+	if err := binary.Read(r, binary.LittleEndian, &sizes); err != nil {
+		return nil, err
+	}
+	if sizes[0] != Inputs || sizes[1] == 0 || sizes[1] > 4096 {
+		return nil, fmt.Errorf("nnue of %d inputs and %d hidden, expected %d inputs", sizes[0], sizes[1], Inputs)
+	}
+	hidden := int(sizes[1])
+	n := &Network{
+		Hidden:         hidden,
+		FeatureWeights: make([]int16, Inputs*hidden),
+		FeatureBias:    make([]int16, hidden),
+		OutputWeights:  make([]int16, 2*hidden),
+	}
+	for _, v := range []any{n.FeatureWeights, n.FeatureBias, n.OutputWeights, &n.OutputBias} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("reading nnue: %v", err)
+		}
+	}
+	return n, nil
+}
+
+func (n *Network) Write(w io.Writer) error {
+	// writes the network in the format Read takes
+	if _, err := io.WriteString(w, magic); err != nil {
+		return err
+	}
+	for _, v := range []any{[2]uint32{Inputs, uint32(n.Hidden)}, n.FeatureWeights, n.FeatureBias, n.OutputWeights, n.OutputBias} {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bucket(b *chess.Board, side chess.Color) int {
+	king := orient(b.KingSquare(side), side)
+	bucket := 0
+	if king%8 >= 4 {
+		bucket = 1
+	}
+	if king/8 >= 2 {
+		bucket += 2
+	}
+	return bucket
+}
+
+func orient(sq chess.Square, side chess.Color) chess.Square {
+	if side == chess.Black {
+		return sq ^ 56
+	}
+	return sq
+}
+
+func feature(bucket int, side, c chess.Color, p chess.Piece, sq chess.Square) int {
+	// the input of a piece of colour c seen from side
+	them := 0
+	if c != side {
+		them = 1
+	}
+	return bucket*768 + (them*6+int(p)-1)*64 + int(orient(sq, side))
+}
+
+func Features(b *chess.Board, side chess.Color) []int {
+	// the active inputs of b seen from side, for trainers
+	var features []int
+	k := bucket(b, side)
+	for c := chess.White; c <= chess.Black; c++ {
+		for p := chess.Pawns; p <= chess.Kings; p++ {
+			for bb := b.PieceBB[c][p]; bb != 0; bb &= bb - 1 {
+				features = append(features, feature(k, side, c, p, chess.Square(bits.TrailingZeros64(uint64(bb)))))
+			}
+		}
+	}
+	return features
+}
+
+// Accumulator holds both sides' sums of feature weights for a position
+type Accumulator struct {
+	values  [2][]int16
+	buckets [2]int
+}
+
+func (n *Network) NewAccumulator() Accumulator {
+	return Accumulator{values: [2][]int16{make([]int16, n.Hidden), make([]int16, n.Hidden)}}
+}
+
+func (n *Network) Refresh(a *Accumulator, b *chess.Board) {
+	// sums the features of b from scratch
+	for side := chess.White; side <= chess.Black; side++ {
+		n.refreshSide(a, b, side)
+	}
+}
+
+func (n *Network) refreshSide(a *Accumulator, b *chess.Board, side chess.Color) {
+	copy(a.values[side], n.FeatureBias)
+	a.buckets[side] = bucket(b, side)
+	for _, f := range Features(b, side) {
+		n.add(a.values[side], f)
+	}
+}
+
+func (n *Network) Update(a, prev *Accumulator, before, after *chess.Board) {
+	// sets a to the accumulator of after from prev, before's, changing
+	// only the pieces that moved. a side whose king changed bucket is
+	// summed again.
+	for side := chess.White; side <= chess.Black; side++ {
+		k := bucket(after, side)
+		if k != prev.buckets[side] {
+			n.refreshSide(a, after, side)
+			continue
+		}
+		a.buckets[side] = k
+		values := a.values[side]
+		copy(values, prev.values[side])
+		for c := chess.White; c <= chess.Black; c++ {
+			for p := chess.Pawns; p <= chess.Kings; p++ {
+				changed := before.PieceBB[c][p] ^ after.PieceBB[c][p]
+				for bb := changed; bb != 0; bb &= bb - 1 {
+					sq := chess.Square(bits.TrailingZeros64(uint64(bb)))
+					if after.PieceBB[c][p]&(1<<sq) != 0 {
+						n.add(values, feature(k, side, c, p, sq))
+					} else {
+						n.sub(values, feature(k, side, c, p, sq))
+					}
+				}
+			}
+		}
+	}
+}
+
+func (n *Network) add(values []int16, f int) {
+	w := n.FeatureWeights[f*n.Hidden : (f+1)*n.Hidden]
+	for i := range values {
+		values[i] += w[i]
+	}
+}
+
+func (n *Network) sub(values []int16, f int) {
+	w := n.FeatureWeights[f*n.Hidden : (f+1)*n.Hidden]
+	for i := range values {
+		values[i] -= w[i]
+	}
+}
+
+func (n *Network) Evaluate(a *Accumulator, turn chess.Color) int {
+	// the score in centipawns for turn, the side to move
+	sum := n.OutputBias
+	for half, side := range []chess.Color{turn, turn.Other()} {
+		w := n.OutputWeights[half*n.Hidden : (half+1)*n.Hidden]
+		for i, v := range a.values[side] {
+			sum += int32(min(max(v, 0), QA)) * int32(w[i])
+		}
+	}
+	return int(sum) * Scale / (QA * QB)
+}
+
+func (n *Network) EvaluateBoard(b *chess.Board) int {
+	// evaluates b from scratch, for when no accumulator is kept
+	a := n.NewAccumulator()
+	n.Refresh(&a, b)
+	return n.Evaluate(&a, b.Turn)
+}
@@ -0,0 +1,150 @@
+package nnue
+
+import (
+	"bytes"
+	chess "chess/board"
+	"encoding/binary"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func randomNetwork(hidden int) *Network {
+	// a network of small random weights, enough to tell any two feature
+	// sets apart
+	r := rand.New(rand.NewPCG(1, 2))
+	n := &Network{
+		Hidden:         hidden,
+		FeatureWeights: make([]int16, Inputs*hidden),
+		FeatureBias:    make([]int16, hidden),
+		OutputWeights:  make([]int16, 2*hidden),
+		OutputBias:     r.Int32N(1 << 16),
+	}
+	for _, w := range [][]int16{n.FeatureWeights, n.FeatureBias, n.OutputWeights} {
+		for i := range w {
+			w[i] = int16(r.IntN(64) - 32)
+		}
+	}
+	return n
+}
+
+func checkUpdate(t *testing.T, n *Network, prev *Accumulator, before *chess.Board, m chess.Move) (Accumulator, *chess.Board) {
+	// plays m and checks the updated accumulator against one summed
+	// from scratch
+	t.Helper()
+	after := *before
+	after.MakeMove(m)
+	a := n.NewAccumulator()
+	n.Update(&a, prev, before, &after)
+	want := n.NewAccumulator()
+	n.Refresh(&want, &after)
+	if !reflect.DeepEqual(a, want) {
+		t.Fatalf("%s after %v: the update differs from a refresh", after.ToFEN(), m)
+	}
+	if got, want := n.Evaluate(&a, after.Turn), n.EvaluateBoard(&after); got != want {
+		t.Fatalf("%s after %v: evaluated %d, from scratch %d", after.ToFEN(), m, got, want)
+	}
+	return a, &after
+}
+
+func TestUpdateMatchesRefresh(t *testing.T) {
+	n := randomNetwork(16)
+	for _, c := range []struct {
+		name, fen, move string
+		bucketChange    bool
+	}{
+		{"castles king side", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", false},
+		{"castles queen side", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1c1", true},
+		{"black castles queen side", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", true},
+		{"Chess960 castle", "1r2k1r1/1p4p1/8/8/8/8/8/1R2K1R1 w GBgb - 0 1", "e1b1", true},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", "e5d6", false},
+		{"promotion", "4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8q", false},
+		{"promotion capturing", "r3k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7a8n", false},
+		{"king leaves the back ranks", "4k3/8/8/8/8/8/4K3/8 w - - 0 1", "e2e3", true},
+		{"king crosses to the d file", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", "e1d1", true},
+		{"black king crosses", "4k3/8/8/8/8/8/8/4K3 b - - 0 1", "e8d7", true},
+	} {
+		b, err := chess.NewBoardFromFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := b.ParseMove(c.move)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		prev := n.NewAccumulator()
+		n.Refresh(&prev, b)
+		a, _ := checkUpdate(t, n, &prev, b, m)
+		if changed := a.buckets != prev.buckets; changed != c.bucketChange {
+			t.Errorf("%s: buckets %v to %v", c.name, prev.buckets, a.buckets)
+		}
+	}
+
+	// and along random games, each accumulator updated from the last
+	r := rand.New(rand.NewPCG(3, 4))
+	for _, fen := range []string{
+		chess.StartFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+	} {
+		for range 5 {
+			b, err := chess.NewBoardFromFEN(fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := n.NewAccumulator()
+			n.Refresh(&a, b)
+			for range 200 {
+				moves := b.LegalMoves()
+				if len(moves) == 0 {
+					break
+				}
+				a, b = checkUpdate(t, n, &a, b, moves[r.IntN(len(moves))])
+			}
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	n := randomNetwork(8)
+	var buf bytes.Buffer
+	if err := n.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	size := len(magic) + 8 + 2*(Inputs*8+8+16) + 4
+	if buf.Len() != size {
+		t.Errorf("wrote %d bytes, expected %d", buf.Len(), size)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, n) {
+		t.Error("the network read back differs from the one written")
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	var good bytes.Buffer
+	if err := randomNetwork(8).Write(&good); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name  string
+		patch func([]byte)
+	}{
+		{"wrong magic", func(data []byte) { data[len(magic)-2] = '2' }},
+		{"wrong input count", func(data []byte) { binary.LittleEndian.PutUint32(data[len(magic):], Inputs/Buckets) }},
+		{"no hidden values", func(data []byte) { binary.LittleEndian.PutUint32(data[len(magic)+4:], 0) }},
+	} {
+		data := slices.Clone(good.Bytes())
+		c.patch(data)
+		if _, err := Read(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: read without an error", c.name)
+		}
+	}
+	if _, err := Read(bytes.NewReader(good.Bytes()[:good.Len()-1])); err == nil {
+		t.Error("a truncated file read without an error")
+	}
+}
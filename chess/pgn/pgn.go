@@ -0,0 +1,372 @@
+package pgn
+
+import (
+	"bufio"
+	chess "chess/board"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Tag struct {
+	Name  string
+	Value string
+}
+
+type Move struct {
+	Move    chess.Move
+	SAN     string
+	NAGs    []int  // numeric annotations, eg 2 for ? and 4 for ??
+	Comment string // the comment following the move, without braces
+}
+
+type Game struct {
+	Tags   []Tag
+	Moves  []Move
+	Result string
+}
+
+// the seven tag roster, written first and in this order
+var roster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+func (g *Game) Tag(name string) string {
+	// returns the value of a tag, empty if it isn't set
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+func (g *Game) SetTag(name, value string) {
+	for i, t := range g.Tags {
+		if t.Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, Tag{name, value})
+}
+
+func (g *Game) StartBoard() (*chess.Board, error) {
+	// the position the game starts from, taken from the FEN tag and
+	// played by Chess960's castling rules if the Variant tag says so
+	b := chess.NewBoard()
+	if fen := g.Tag("FEN"); fen != "" {
+		var err error
+		if b, err = chess.NewBoardFromFEN(fen); err != nil {
+			return nil, err
+		}
+	}
+	if strings.EqualFold(g.Tag("Variant"), "chess960") {
+		b.Chess960 = true
+	}
+	return b, nil
+}
+
+func (g *Game) Positions() ([]*chess.Board, error) {
+	// returns the board before every move and after the last one
+	b, err := g.StartBoard()
+	if err != nil {
+		return nil, err
+	}
+	boards := []*chess.Board{b}
+	for _, m := range g.Moves {
+		next := *boards[len(boards)-1]
+		next.MakeMove(m.Move)
+		boards = append(boards, &next)
+	}
+	return boards, nil
+}
+
+func (g *Game) String() string {
+	// writes the game in export format with lines of at most 80 characters
+	var sb strings.Builder
+	result := g.Result
+	if result == "" {
+		result = chess.Unfinished
+	}
+	for _, name := range roster {
+		value := g.Tag(name)
+		if name == "Result" {
+			value = result
+		} else if value == "" {
+			value = "?"
+		}
+		fmt.Fprintf(&sb, "[%s %s]\n", name, strconv.Quote(value))
+	}
+	for _, t := range g.Tags {
+		if !isRoster(t.Name) {
+			fmt.Fprintf(&sb, "[%s %s]\n", t.Name, strconv.Quote(t.Value))
+		}
+	}
+	sb.WriteByte('\n')
+
+	b, err := g.StartBoard()
+	if err != nil {
+		b = chess.NewBoard()
+	}
+	number := int(b.MoveCounter)/2 + 1
+	var tokens []string
+	for i, m := range g.Moves {
+		if b.Turn == chess.White {
+			tokens = append(tokens, strconv.Itoa(number)+".")
+		} else if i == 0 || g.Moves[i-1].Comment != "" {
+			tokens = append(tokens, strconv.Itoa(number)+"...")
+		}
+		san := m.SAN
+		if san == "" {
+			san = b.SAN(m.Move)
+		}
+		tokens = append(tokens, san)
+		for _, nag := range m.NAGs {
+			tokens = append(tokens, "$"+strconv.Itoa(nag))
+		}
+		if m.Comment != "" {
+			tokens = append(tokens, strings.Fields("{"+m.Comment+"}")...)
+		}
+		if b.Turn == chess.Black {
+			number++
+		}
+		b.MakeMove(m.Move)
+	}
+	tokens = append(tokens, result)
+
+	line := 0
+	for i, t := range tokens {
+		if i > 0 {
+			if line+1+len(t) > 80 {
+				sb.WriteByte('\n')
+				line = 0
+			} else {
+				sb.WriteByte(' ')
+				line++
+			}
+		}
+		sb.WriteString(t)
+		line += len(t)
+	}
+	sb.WriteString("\n\n")
+	return sb.String()
+}
+
+func isRoster(name string) bool {
+	for _, r := range roster {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+type Reader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 1<<16)}
+}
+
+func (r *Reader) Next() (*Game, error) {
+	// reads the next game, returning io.EOF when there are no more.
+	// variations are skipped, only the main line is kept. a game that
+	// can't be read is skipped as far as the next game's tags, so after
+	// its *GameError Next goes on with the game after.
+	g := &Game{}
+	var b *chess.Board
+	depth := 0 // nesting of variations being skipped
+	started := false
+	for {
+		c, err := r.r.ReadByte()
+		if err == io.EOF {
+			if !started {
+				return nil, io.EOF
+			}
+			if g.Result == "" {
+				g.Result = chess.Unfinished
+			}
+			return g, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case c == '\n':
+			r.line++
+		case c == ' ' || c == '\t' || c == '\r' || c == '.':
+		case c == '%' || c == ';':
+			// escaped lines and rest of line comments
+			if _, err := r.r.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+			r.line++
+		case c == '[' && len(g.Moves) > 0:
+			// the next game starts without this one giving a result
+			r.r.UnreadByte()
+			g.Result = chess.Unfinished
+			return g, nil
+		case c == '[':
+			started = true
+			line, err := r.r.ReadString(']')
+			if err != nil {
+				return nil, r.errorf("unterminated tag")
+			}
+			name, value, _ := strings.Cut(strings.TrimSuffix(line, "]"), " ")
+			if unquoted, err := strconv.Unquote(strings.TrimSpace(value)); err == nil {
+				value = unquoted
+			}
+			g.Tags = append(g.Tags, Tag{name, value})
+		case c == '{':
+			started = true
+			text, err := r.r.ReadString('}')
+			if err != nil {
+				return nil, r.errorf("unterminated comment")
+			}
+			r.line += strings.Count(text, "\n")
+			text = strings.Join(strings.Fields(strings.TrimSuffix(text, "}")), " ")
+			if depth == 0 && len(g.Moves) > 0 {
+				last := &g.Moves[len(g.Moves)-1]
+				if last.Comment != "" {
+					last.Comment += " "
+				}
+				last.Comment += text
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth = max(depth-1, 0)
+		default:
+			started = true
+			r.r.UnreadByte()
+			token, err := r.token()
+			if err != nil {
+				return nil, err
+			}
+			if depth > 0 {
+				continue
+			}
+			switch {
+			case token == chess.WhiteWon || token == chess.BlackWon || token == chess.Drawn || token == chess.Unfinished:
+				g.Result = token
+				return g, nil
+			case token[0] == '$':
+				nag, err := strconv.Atoi(token[1:])
+				if err != nil || len(g.Moves) == 0 {
+					return nil, r.badGame("bad annotation %q", token)
+				}
+				g.Moves[len(g.Moves)-1].NAGs = append(g.Moves[len(g.Moves)-1].NAGs, nag)
+			case token[0] >= '0' && token[0] <= '9' && !zeroCastling(token):
+				// move number
+			default:
+				if b == nil {
+					if b, err = g.StartBoard(); err != nil {
+						return nil, r.badGame("%v", err)
+					}
+				}
+				m, nag, err := parseMove(b, token)
+				if err != nil {
+					return nil, r.badGame("%v", err)
+				}
+				g.Moves = append(g.Moves, Move{Move: m, SAN: b.SAN(m)})
+				if nag != 0 {
+					g.Moves[len(g.Moves)-1].NAGs = append(g.Moves[len(g.Moves)-1].NAGs, nag)
+				}
+				b.MakeMove(m)
+			}
+		}
+	}
+}
+
+func (r *Reader) token() (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := r.r.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if strings.IndexByte(" \t\r\n.{}()[];", c) >= 0 {
+			r.r.UnreadByte()
+			break
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String(), nil
+}
+
+func zeroCastling(token string) bool {
+	// castling written with zeros, which move numbers would swallow
+	switch strings.TrimRight(token, "+#!?") {
+	case "0-0", "0-0-0":
+		return true
+	}
+	return false
+}
+
+// GameError is the error of a game that couldn't be read, after which
+// Next goes on with the next game. any other error is the input's.
+type GameError struct {
+	Line int
+	Msg  string
+}
+
+func (e *GameError) Error() string {
+	return fmt.Sprintf("pgn line %d: %s", e.Line, e.Msg)
+}
+
+func (r *Reader) errorf(format string, args ...any) error {
+	return &GameError{r.line + 1, fmt.Sprintf(format, args...)}
+}
+
+func (r *Reader) badGame(format string, args ...any) error {
+	// the error of the game being read, after skipping the rest of it:
+	// up to a line starting with a tag or the end of the input
+	err := r.errorf(format, args...)
+	lineStart := false
+	for {
+		c, e := r.r.ReadByte()
+		if e != nil {
+			return err
+		}
+		switch {
+		case c == '[' && lineStart:
+			r.r.UnreadByte()
+			return err
+		case c == '\n':
+			r.line++
+			lineStart = true
+		case c != ' ' && c != '\t' && c != '\r':
+			lineStart = false
+		}
+	}
+}
+
+// suffix annotations and the NAGs they stand for
+var suffixNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+func parseMove(b *chess.Board, token string) (chess.Move, int, error) {
+	san := strings.TrimRight(token, "!?")
+	m, err := b.ParseSAN(san)
+	if err != nil {
+		return chess.NullMove, 0, err
+	}
+	return m, suffixNAGs[token[len(san):]], nil
+}
+
+func ReadAll(r io.Reader) ([]*Game, error) {
+	// reads every game from r
+	var games []*Game
+	pr := NewReader(r)
+	for {
+		g, err := pr.Next()
+		if err == io.EOF {
+			return games, nil
+		} else if err != nil {
+			return games, err
+		}
+		games = append(games, g)
+	}
+}
@@ -0,0 +1,104 @@
+package pgn
+
+import (
+	chess "chess/board"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadZeroCastling(t *testing.T) {
+	// castling written with zeros is read as moves, not move numbers,
+	// and results still end the game
+	games, err := ReadAll(strings.NewReader(`[Event "a"]
+
+1. e4 e5 2. Nf3 Nc6 3. Bc4 Bc5 4. 0-0 d6 5. d3 Bg4 6. Nc3 Qd7 7. Be3 0-0-0! 1-0
+
+[Event "b"]
+
+1. d4 d5 0-1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("read %d games, expected 2", len(games))
+	}
+	var sans []string
+	for _, m := range games[0].Moves {
+		sans = append(sans, m.SAN)
+	}
+	want := "e4 e5 Nf3 Nc6 Bc4 Bc5 O-O d6 d3 Bg4 Nc3 Qd7 Be3 O-O-O"
+	if got := strings.Join(sans, " "); got != want {
+		t.Errorf("read %q, expected %q", got, want)
+	}
+	if games[0].Result != chess.WhiteWon || games[1].Result != chess.BlackWon || len(games[1].Moves) != 2 {
+		t.Errorf("results %q and %q, second game %d moves", games[0].Result, games[1].Result, len(games[1].Moves))
+	}
+}
+
+func TestReadChess960(t *testing.T) {
+	// a Chess960 game castles by the king taking its own rook, even
+	// from the standard start
+	games, err := ReadAll(strings.NewReader(`[Event "a"]
+[Variant "Chess960"]
+[SetUp "1"]
+[FEN "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"]
+
+1. Nf3 Nf6 2. g3 g6 3. Bg2 Bg7 4. O-O O-O *
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := games[0].Moves
+	if len(moves) != 8 || moves[6].Move.String() != "e1h1" || moves[7].Move.String() != "e8h8" {
+		t.Fatalf("read %v", moves)
+	}
+	boards, err := games[0].Positions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen := boards[8].ToFEN(); fen != "rnbq1rk1/ppppppbp/5np1/8/8/5NP1/PPPPPPBP/RNBQ1RK1 w - - 4 5" {
+		t.Errorf("castled to %s", fen)
+	}
+}
+
+func TestNextSkipsBadGames(t *testing.T) {
+	// a game with an illegal move or a bad annotation is reported and
+	// reading carries on with the next one
+	r := NewReader(strings.NewReader(`[Event "a"]
+
+1. e4 e5 2. Ke3 Nc6 1-0
+
+[Event "b"]
+
+1. d4 d5 $x 2. c4 0-1
+
+[Event "c"]
+[FEN "8/8/8/8/8/8/8/8 w - - 0 1"]
+
+1. e4 *
+
+[Event "d"]
+
+1. c4 c5 1/2-1/2
+`))
+	var events []string
+	bad := 0
+	for {
+		g, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			if _, ok := err.(*GameError); !ok {
+				t.Fatal(err)
+			}
+			bad++
+			continue
+		}
+		events = append(events, g.Tag("Event"))
+	}
+	if bad != 3 || len(events) != 1 || events[0] != "d" {
+		t.Errorf("read %v with %d errors, expected d with 3", events, bad)
+	}
+}
@@ -0,0 +1,93 @@
+package puzzle
+
+import "math"
+
+// a Glicko-2 rating, on the Glicko scale
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+const (
+	glickoScale  = 173.7178 // between the Glicko and Glicko-2 scales
+	tau          = 0.75     // how fast volatility may change, as on lichess
+	minDeviation = 45
+	maxDeviation = 350
+)
+
+func NewRating() Rating {
+	// the rating of a newcomer
+	return Rating{1500, maxDeviation, 0.06}
+}
+
+// a game of a rating period: the opponent's rating and deviation and
+// the score against them
+type result struct {
+	opponent, deviation, score float64
+}
+
+func (r Rating) Update(opponent, opponentDeviation, score float64) Rating {
+	// the rating after one game against opponent, scoring 1 for a win,
+	// 0.5 for a draw and 0 for a loss, the game being the rating period
+	return r.update([]result{{opponent, opponentDeviation, score}}, tau)
+}
+
+func (r Rating) update(games []result, tau float64) Rating {
+	// the rating after a rating period of games, following the steps
+	// of Glickman's Glicko-2 paper
+	mu := (r.Rating - 1500) / glickoScale
+	phi := r.Deviation / glickoScale
+
+	var vInv, improvement float64 // 1/v and the sum delta is v times
+	for _, game := range games {
+		muJ := (game.opponent - 1500) / glickoScale
+		phiJ := game.deviation / glickoScale
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		expected := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * expected * (1 - expected)
+		improvement += g * (game.score - expected)
+	}
+	v := 1 / vInv
+	delta := v * improvement
+
+	// the new volatility, found with the Illinois algorithm
+	a := math.Log(r.Volatility * r.Volatility)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > 1e-6 {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	volatility := math.Exp(A / 2)
+
+	phiStar := math.Sqrt(phi*phi + volatility*volatility)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * improvement
+	return Rating{
+		Rating:     mu*glickoScale + 1500,
+		Deviation:  min(max(phi*glickoScale, minDeviation), maxDeviation),
+		Volatility: volatility,
+	}
+}
@@ -0,0 +1,51 @@
+package puzzle
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGlickmanExample(t *testing.T) {
+	// the worked example of Glickman's paper: a player of 1500, RD 200
+	// and volatility 0.06 beats a 1400 and loses to a 1550 and a 1700,
+	// with tau 0.5
+	r := Rating{1500, 200, 0.06}.update([]result{{1400, 30, 1}, {1550, 100, 0}, {1700, 300, 0}}, 0.5)
+	for _, c := range []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"rating", r.Rating, 1464.06, 0.01},
+		{"deviation", r.Deviation, 151.52, 0.01},
+		{"volatility", r.Volatility, 0.05999, 0.00001},
+	} {
+		if math.Abs(c.got-c.want) > c.tolerance {
+			t.Errorf("%s %v, expected %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	// a single game moves the rating towards the result, further
+	// against a surer opponent
+	r := NewRating()
+	win, loss, draw := r.Update(1500, 50, 1), r.Update(1500, 50, 0), r.Update(1500, 50, 0.5)
+	if win.Rating <= r.Rating || loss.Rating >= r.Rating || math.Abs(draw.Rating-r.Rating) > 1e-9 {
+		t.Errorf("win %v, loss %v, draw %v from %v", win.Rating, loss.Rating, draw.Rating, r.Rating)
+	}
+	if unsure := r.Update(1500, 350, 1); unsure.Rating >= win.Rating {
+		t.Errorf("a win against an unsure rating gained %v, against a sure one %v", unsure.Rating-r.Rating, win.Rating-r.Rating)
+	}
+	// settling as the games go by but never below minDeviation
+	settled := r
+	for range 200 {
+		next := settled.Update(1500, minDeviation, 0.5)
+		if next.Deviation > settled.Deviation || next.Deviation < minDeviation {
+			t.Fatalf("deviation %v after %v", next.Deviation, settled.Deviation)
+		}
+		settled = next
+	}
+	if settled.Deviation > 100 {
+		t.Errorf("deviation %v after 200 games", settled.Deviation)
+	}
+}
@@ -0,0 +1,99 @@
+package puzzle
+
+import (
+	chess "chess/board"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// a tactics puzzle as in the lichess puzzle database. the opponent
+// plays the first move and the solver has to find every other one.
+type Puzzle struct {
+	ID              string
+	FEN             string   // the position before the opponent's move
+	Moves           []string // the line in UCI notation
+	Rating          int
+	RatingDeviation int
+	Themes          []string
+}
+
+func Read(r io.Reader, theme string) ([]Puzzle, error) {
+	// reads puzzles from CSV in the lichess format:
+	// PuzzleId,FEN,Moves,Rating,RatingDeviation,Popularity,NbPlays,Themes,...
+	// only those with theme among their themes are kept when it is set.
+	// a header line is skipped.
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	var puzzles []Puzzle
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return puzzles, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if line == 1 && record[0] == "PuzzleId" {
+			continue
+		}
+		if len(record) < 8 {
+			return nil, fmt.Errorf("line %d: expected at least 8 fields, got %d", line, len(record))
+		}
+		p := Puzzle{
+			ID:     record[0],
+			FEN:    record[1],
+			Moves:  strings.Fields(record[2]),
+			Themes: strings.Fields(record[7]),
+		}
+		if theme != "" && !p.HasTheme(theme) {
+			continue
+		}
+		if p.Rating, err = strconv.Atoi(record[3]); err != nil {
+			return nil, fmt.Errorf("line %d: bad rating %q", line, record[3])
+		}
+		if p.RatingDeviation, err = strconv.Atoi(record[4]); err != nil {
+			return nil, fmt.Errorf("line %d: bad rating deviation %q", line, record[4])
+		}
+		if len(p.Moves) < 2 {
+			return nil, fmt.Errorf("line %d: a puzzle needs at least two moves", line)
+		}
+		puzzles = append(puzzles, p)
+	}
+}
+
+func (p Puzzle) HasTheme(theme string) bool {
+	return slices.Contains(p.Themes, theme)
+}
+
+func (p Puzzle) Start() (*chess.Board, chess.Move, error) {
+	// the position the puzzle starts from and the opponent's move
+	// leading to the one the solver sees
+	b, err := chess.NewBoardFromFEN(p.FEN)
+	if err != nil {
+		return nil, chess.NullMove, err
+	}
+	mv, err := b.ParseMove(p.Moves[0])
+	if err != nil {
+		return nil, chess.NullMove, err
+	}
+	return b, mv, nil
+}
+
+func (p Puzzle) Try(b *chess.Board, ply int, mv chess.Move) (correct, solved bool) {
+	// checks mv, played in b as the move at ply of the line. it is
+	// correct when it is the move of the solution, or when it mates,
+	// as any mate solves the puzzle.
+	after := *b
+	after.MakeMove(mv)
+	if after.InCheck() && !after.HasLegalMoves() {
+		return true, true
+	}
+	if ply >= len(p.Moves) || mv.String() != p.Moves[ply] {
+		return false, false
+	}
+	return true, ply == len(p.Moves)-1
+}
@@ -0,0 +1,273 @@
+package tablebase
+
+import (
+	chess "chess/board"
+	"math/bits"
+	"sort"
+)
+
+// index tables shared by every syzygy file, built once at start up
+var (
+	mapA1D1D4     [64]int
+	mapB1H1H7     [64]int
+	mapKK         [10][64]int
+	binomial      [7][64]uint64
+	mapPawns      [64]int
+	leadPawnIdx   [7][64]uint64
+	leadPawnsSize [7][4]uint64
+)
+
+func offA1H8(sq int) int {
+	// positive above the a1-h8 diagonal, negative below, zero on it
+	return sq/8 - sq%8
+}
+
+func init() {
+	// squares below the a1-h8 diagonal map to 0..27
+	code := 0
+	for sq := range 64 {
+		if offA1H8(sq) < 0 {
+			mapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	// squares in the a1-d1-d4 triangle map to 0..9 with the diagonal last
+	var diagonal []int
+	code = 0
+	for sq := 0; sq <= 27; sq++ {
+		if offA1H8(sq) < 0 && sq%8 <= 3 {
+			mapA1D1D4[sq] = code
+			code++
+		} else if offA1H8(sq) == 0 && sq%8 <= 3 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		mapA1D1D4[sq] = code
+		code++
+	}
+
+	// the 462 legal placements of two kings with the first one in the
+	// a1-d1-d4 triangle. if the first king is on the diagonal the second
+	// may not be above it; both on the diagonal are numbered last.
+	type pair struct{ idx, sq int }
+	var bothOnDiagonal []pair
+	code = 0
+	for idx := range 10 {
+		for s1 := 0; s1 <= 27; s1++ {
+			if mapA1D1D4[s1] != idx || (idx == 0 && s1 != 1) {
+				continue
+			}
+			for s2 := range 64 {
+				near := chess.AllKingMoves[s1] | chess.Bitboard(1)<<uint(s1)
+				if near.GetBit(chess.Square(s2)) {
+					continue
+				}
+				if offA1H8(s1) == 0 && offA1H8(s2) > 0 {
+					continue
+				}
+				if offA1H8(s1) == 0 && offA1H8(s2) == 0 {
+					bothOnDiagonal = append(bothOnDiagonal, pair{idx, s2})
+				} else {
+					mapKK[idx][s2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		mapKK[p.idx][p.sq] = code
+		code++
+	}
+
+	// binomial[k][n] ways to choose k of n squares
+	binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 7 && k <= n; k++ {
+			if k > 0 {
+				binomial[k][n] += binomial[k-1][n-1]
+			}
+			if k < n {
+				binomial[k][n] += binomial[k][n-1]
+			}
+		}
+	}
+
+	// mapPawns numbers a2-h7 so the leading pawn (nearest the edge,
+	// then lowest rank) has the highest value. leadPawnIdx and
+	// leadPawnsSize index the leading pawn group per file a-d.
+	available := 47
+	for count := 1; count <= 6; count++ {
+		for file := range 4 {
+			var idx uint64
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				if count == 1 {
+					mapPawns[sq] = available
+					available--
+					mapPawns[sq^7] = available
+					available--
+				}
+				leadPawnIdx[count][sq] = idx
+				idx += binomial[count-1][mapPawns[sq]]
+			}
+			leadPawnsSize[count][file] = idx
+		}
+	}
+}
+
+func (t *table) encode(b *chess.Board, td *tableData, stm int, flip bool) (*pairsData, uint64, int, bool) {
+	// maps the position to its index in the table, following the
+	// syzygy layout: leading group, then the remaining groups in the
+	// order stored in the file. returns false when a one sided dtz
+	// table holds the other side to move.
+	var squares, pieces [7]int
+	size, leadPawnsCnt, tbFile := 0, 0, 0
+	flipColor, flipSquares := 0, 0
+	if flip {
+		flipColor, flipSquares = 8, 56
+	}
+
+	var leadPawns chess.Bitboard
+	if t.hasPawns {
+		// the leading pawns are the ones of the colour listed first
+		pc := td.items[0][0].pieces[0] ^ flipColor
+		leadPawns = b.PieceBB[pc>>3][chess.Pawns]
+		for bb := leadPawns; bb != 0; bb &= bb - 1 {
+			squares[size] = bits.TrailingZeros64(uint64(bb)) ^ flipSquares
+			size++
+		}
+		leadPawnsCnt = size
+		best := 0
+		for i := 1; i < leadPawnsCnt; i++ {
+			if mapPawns[squares[i]] > mapPawns[squares[best]] {
+				best = i
+			}
+		}
+		squares[0], squares[best] = squares[best], squares[0]
+		tbFile = squares[0] % 8
+		if tbFile > 3 {
+			tbFile = (squares[0] ^ 7) % 8
+		}
+	}
+
+	if td.dtz && !td.checkSTM(t, stm, tbFile) {
+		return nil, 0, 0, false
+	}
+
+	for bb := b.FullBB &^ leadPawns; bb != 0; bb &= bb - 1 {
+		sq := bits.TrailingZeros64(uint64(bb))
+		squares[size] = sq ^ flipSquares
+		pieces[size] = pieceCode(b, chess.Square(sq)) ^ flipColor
+		size++
+	}
+
+	d := td.get(t, stm, tbFile)
+
+	// put the pieces in the order the table was compressed with
+	for i := leadPawnsCnt; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// mirror so the leading piece is on files a-d
+	if squares[0]%8 > 3 {
+		for i := range size {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx uint64
+	if t.hasPawns {
+		idx = leadPawnIdx[leadPawnsCnt][squares[0]]
+		lead := squares[1:leadPawnsCnt]
+		sort.SliceStable(lead, func(i, j int) bool { return mapPawns[lead[i]] < mapPawns[lead[j]] })
+		for i := 1; i < leadPawnsCnt; i++ {
+			idx += binomial[i][mapPawns[squares[i]]]
+		}
+	} else {
+		// without pawns also mirror so the leading piece is on ranks 1-4
+		if squares[0]/8 > 3 {
+			for i := range size {
+				squares[i] ^= 56
+			}
+		}
+		// and flip along the diagonal so the first leading piece off
+		// the a1-h8 diagonal is below it
+		for i := 0; i < d.groupLen[0]; i++ {
+			if offA1H8(squares[i]) == 0 {
+				continue
+			}
+			if offA1H8(squares[i]) > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = ((squares[j] >> 3) | (squares[j] << 3)) & 63
+				}
+			}
+			break
+		}
+
+		if t.hasUniquePieces {
+			adjust1 := b2i(squares[1] > squares[0])
+			adjust2 := b2i(squares[2] > squares[0]) + b2i(squares[2] > squares[1])
+			switch {
+			case offA1H8(squares[0]) != 0:
+				idx = uint64((mapA1D1D4[squares[0]]*63+(squares[1]-adjust1))*62 + squares[2] - adjust2)
+			case offA1H8(squares[1]) != 0:
+				idx = uint64((6*63+(squares[0]/8)*28+mapB1H1H7[squares[1]])*62 + squares[2] - adjust2)
+			case offA1H8(squares[2]) != 0:
+				idx = uint64(6*63*62 + 4*28*62 + (squares[0]/8)*7*28 + (squares[1]/8-adjust1)*28 + mapB1H1H7[squares[2]])
+			default:
+				idx = uint64(6*63*62 + 4*28*62 + 4*7*28 + (squares[0]/8)*7*6 + (squares[1]/8-adjust1)*6 + (squares[2]/8 - adjust2))
+			}
+		} else {
+			idx = uint64(mapKK[mapA1D1D4[squares[0]]][squares[1]])
+		}
+	}
+
+	// the remaining groups, each squeezed past the squares already used
+	idx *= d.groupIdx[0]
+	start := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+		var n uint64
+		for i, sq := range group {
+			adjust := 0
+			for _, s := range squares[:start] {
+				if sq > s {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += binomial[i+1][sq-adjust]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += d.groupLen[next]
+	}
+	return d, idx, tbFile, true
+}
+
+func pieceCode(b *chess.Board, sq chess.Square) int {
+	// piece numbering used inside syzygy files: type 1-6 plus 8 for black
+	if p := b.GetPieceAt(sq, chess.White); p != chess.Empty {
+		return int(p)
+	}
+	return int(b.GetPieceAt(sq, chess.Black)) | 8
+}
+
+func b2i(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
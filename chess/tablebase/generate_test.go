@@ -0,0 +1,626 @@
+package tablebase
+
+import (
+	chess "chess/board"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// the tests probe tables generated here rather than downloaded ones.
+// endings of a king and one piece against a king are solved by
+// retrograde analysis with the board package's move generator. a few
+// four piece endings, too large to solve this way, hold a value hashed
+// from each position instead so their indices are still checked. all
+// are laid out by the decoder in index_test.go and compressed into the
+// syzygy layout the prober reads.
+
+// solution holds the value of every position of an ending with white
+// holding the piece, by solutionIndex
+type solution struct {
+	piece chess.Piece
+	valid []bool
+	wdl   []WDL
+	dtz   []int
+}
+
+const solutionSize = 2 << 18
+
+func solutionIndex(stm chess.Color, wk, x, bk int) int {
+	return int(stm)<<18 | wk<<12 | x<<6 | bk
+}
+
+func solutionKey(b *chess.Board, p chess.Piece) int {
+	x := bits.TrailingZeros64(uint64(b.PieceBB[chess.White][p]))
+	return solutionIndex(b.Turn, int(b.KingSquare(chess.White)), x, int(b.KingSquare(chess.Black)))
+}
+
+func position(p chess.Piece, idx int) (*chess.Board, bool) {
+	// the position of idx, false if it can't arise in a game
+	stm := chess.Color(idx >> 18)
+	wk, x, bk := idx>>12&63, idx>>6&63, idx&63
+	if wk == x || wk == bk || x == bk || p == chess.Pawns && (x < 8 || x >= 56) {
+		return nil, false
+	}
+	b := &chess.Board{RKRmoved: [2][3]bool{{true, true, true}, {true, true, true}}}
+	b.PieceBB[chess.White][chess.Kings] = 1 << wk
+	b.PieceBB[chess.White][p] = 1 << x
+	b.PieceBB[chess.Black][chess.Kings] = 1 << bk
+	b.CombineBB()
+	// the side that just moved can't be in check
+	b.Turn = stm.Other()
+	if b.InCheck() {
+		return nil, false
+	}
+	b.Turn = stm
+	return b, true
+}
+
+func solve(p chess.Piece, promoted map[chess.Piece]*solution) *solution {
+	// values every position of the ending. positions left by a
+	// promotion are looked up in promoted, other endings are draws.
+	s := &solution{
+		piece: p,
+		valid: make([]bool, solutionSize),
+		wdl:   make([]WDL, solutionSize),
+		dtz:   make([]int, solutionSize),
+	}
+	// the moves of position i are start[i] to start[i+1]: the position
+	// reached, or -1 with its value in outside when it's another ending
+	start := make([]int, solutionSize+1)
+	var next []int
+	var outside []WDL
+	var zeroing []bool
+	const unknown = WDL(-100)
+	for idx := range solutionSize {
+		start[idx] = len(next)
+		b, ok := position(p, idx)
+		if !ok {
+			continue
+		}
+		s.valid[idx] = true
+		s.wdl[idx] = unknown
+		moves := b.LegalMoves()
+		if len(moves) == 0 {
+			s.wdl[idx] = Draw
+			if b.InCheck() {
+				s.wdl[idx] = Loss
+			}
+		}
+		for _, m := range moves {
+			child := *b
+			child.MakeMove(m)
+			zeroing = append(zeroing, b.IsCapture(m) || m.Piece == chess.Pawns)
+			switch {
+			case child.FullBB.Count() == 2:
+				next, outside = append(next, -1), append(outside, Draw)
+			case m.Promotion != chess.Empty:
+				v := Draw
+				if sub := promoted[m.Promotion]; sub != nil {
+					v = sub.wdl[solutionKey(&child, m.Promotion)]
+				}
+				next, outside = append(next, -1), append(outside, v)
+			default:
+				next, outside = append(next, solutionKey(&child, p)), append(outside, Draw)
+			}
+		}
+	}
+	start[solutionSize] = len(next)
+	value := func(i int) WDL {
+		if next[i] < 0 {
+			return outside[i]
+		}
+		return s.wdl[next[i]]
+	}
+
+	// a position is won once a move reaches a lost one and lost once
+	// every move reaches a won one. what's never settled is drawn.
+	for changed := true; changed; {
+		changed = false
+		for idx := range solutionSize {
+			if s.wdl[idx] != unknown {
+				continue
+			}
+			win, loss := false, true
+			for i := start[idx]; i < start[idx+1]; i++ {
+				v := value(i)
+				win = win || v == Loss
+				loss = loss && v == Win
+			}
+			if win {
+				s.wdl[idx], changed = Win, true
+			} else if loss {
+				s.wdl[idx], changed = Loss, true
+			}
+		}
+	}
+	for idx := range solutionSize {
+		if s.wdl[idx] == unknown {
+			s.wdl[idx] = Draw
+		}
+	}
+
+	// dtz in plies, layer by layer. a win is 1 when a zeroing move or
+	// mate wins, else one more than the quickest lost reply. a loss
+	// is -1 when only zeroing moves are left, else one more than the
+	// slowest win it lets the other side have.
+	mated := func(i int) bool { return next[i] >= 0 && start[next[i]] == start[next[i]+1] && s.wdl[next[i]] == Loss }
+	left := 0
+	for idx := range solutionSize {
+		if !s.valid[idx] || s.wdl[idx] == Draw {
+			continue
+		}
+		left++
+		quiet := false
+		for i := start[idx]; i < start[idx+1]; i++ {
+			if s.wdl[idx] == Win && (zeroing[i] && value(i) == Loss || mated(i)) {
+				s.dtz[idx] = 1
+			}
+			quiet = quiet || !zeroing[i]
+		}
+		if s.wdl[idx] == Loss && !quiet {
+			s.dtz[idx] = -1
+		}
+		if s.dtz[idx] != 0 {
+			left--
+		}
+	}
+	for k := 2; left > 0; k++ {
+		for idx := range solutionSize {
+			if !s.valid[idx] || s.wdl[idx] == Draw || s.dtz[idx] != 0 {
+				continue
+			}
+			if s.wdl[idx] == Win {
+				for i := start[idx]; i < start[idx+1]; i++ {
+					if !zeroing[i] && s.dtz[next[i]] == -(k-1) {
+						s.dtz[idx] = k
+						break
+					}
+				}
+			} else {
+				longest := 0
+				for i := start[idx]; i < start[idx+1]; i++ {
+					if zeroing[i] {
+						continue
+					}
+					if s.dtz[next[i]] <= 0 {
+						longest = -1
+						break
+					}
+					longest = max(longest, s.dtz[next[i]])
+				}
+				if longest+1 == k {
+					s.dtz[idx] = -k
+				}
+			}
+			if s.dtz[idx] != 0 {
+				left--
+			}
+		}
+		if k > 1000 {
+			panic(fmt.Sprintf("%d positions without a dtz", left))
+		}
+	}
+	return s
+}
+
+// the pieces of every generated table in the order its file lists
+// them, upper case for white, and where the leading group (and the
+// other colour's pawns) come among the multipliers of the index
+var fixtures = map[string]struct {
+	pieces string
+	order  [2]int
+}{
+	"KQvK":  {"QKk", [2]int{0, 0xF}},
+	"KRvK":  {"RKk", [2]int{0, 0xF}},
+	"KPvK":  {"PKk", [2]int{0, 0xF}},
+	"KBvK":  {"BKk", [2]int{0, 0xF}},
+	"KNvK":  {"NKk", [2]int{0, 0xF}},
+	"KRvKN": {"RKkn", [2]int{1, 0xF}},
+	"KNNvK": {"KkNN", [2]int{0, 0xF}},
+	"KPvKP": {"PpKk", [2]int{1, 0}},
+	"KPPvK": {"PPKk", [2]int{2, 0xF}},
+}
+
+func pieceCodes(pieces string) []int {
+	var codes []int
+	for _, r := range pieces {
+		p := strings.IndexRune("PNBRQK", unicode.ToUpper(r)) + 1
+		if unicode.IsLower(r) {
+			p |= 8
+		}
+		codes = append(codes, p)
+	}
+	return codes
+}
+
+func writeTables(dir string, s *solution, code string) error {
+	// writes the wdl and dtz files of a solved ending. the dtz file
+	// holds white to move, or black for rook endings, so both ways of
+	// reading it are tested.
+	stored := chess.White
+	if s.piece == chess.Rooks {
+		stored = chess.Black
+	}
+	wdl := func(b *chess.Board) int {
+		if idx := solutionKey(b, s.piece); s.valid[idx] {
+			return int(s.wdl[idx]) + 2
+		}
+		return -1
+	}
+	dtz := func(b *chess.Board) int {
+		if idx := solutionKey(b, s.piece); s.valid[idx] {
+			return max(abs(s.dtz[idx])-1, 0)
+		}
+		return -1
+	}
+	if err := writeTable(dir, code, false, 0, wdl); err != nil {
+		return err
+	}
+	return writeTable(dir, code, true, uint8(stored)|flagWinPlies|flagLossPlies, dtz)
+}
+
+func writeDrawnTable(dir, code string) error {
+	// a wdl file holding a single value, for the drawn minor endings
+	return writeTable(dir, code, false, 0, func(*chess.Board) int { return int(Draw) + 2 })
+}
+
+func writeHashedTable(dir, code string) error {
+	// a wdl file of values hashed from the positions, for tables too
+	// large to solve here that still need their index checked
+	t := newTable(code)
+	return writeTable(dir, code, false, 0, func(b *chess.Board) int { return hashedValue(b, symmetries(t)) })
+}
+
+func symmetries(t *table) int {
+	// pawns only allow the board to be mirrored left to right
+	if t.hasPawns {
+		return 2
+	}
+	return 8
+}
+
+func hashedValue(b *chess.Board, symmetries int) int {
+	// a value from 0 to 4 that's the same for positions the first
+	// symmetries of the board map into each other: mirrored left to
+	// right, top to bottom and along the a1-h8 diagonal
+	key := uint64(math.MaxUint64)
+	for k := range symmetries {
+		var items [7]uint64
+		n := 0
+		for c := chess.White; c <= chess.Black; c++ {
+			for p := chess.Pawns; p <= chess.Kings; p++ {
+				for bb := b.PieceBB[c][p]; bb != 0; bb &= bb - 1 {
+					sq := bits.TrailingZeros64(uint64(bb))
+					if k&1 != 0 {
+						sq ^= 7
+					}
+					if k&2 != 0 {
+						sq ^= 56
+					}
+					if k&4 != 0 {
+						sq = (sq>>3 | sq<<3) & 63
+					}
+					items[n] = uint64(c)<<9 | uint64(p)<<6 | uint64(sq)
+					n++
+				}
+			}
+		}
+		slices.Sort(items[:n])
+		h := uint64(b.Turn)
+		for _, item := range items[:n] {
+			h = h<<10 | item
+		}
+		key = min(key, h)
+	}
+	return int(key*0x9E3779B97F4A7C15>>32) % 5
+}
+
+func abs(v int) int {
+	return max(v, -v)
+}
+
+func writeTable(dir, code string, dtz bool, flags uint8, value func(b *chess.Board) int) error {
+	// writes the wdl or dtz file of code with value of every position,
+	// -1 when it has none, laid out in the order tableData.setup reads
+	// it. a dtz file holds the side to move in flags, a wdl file both
+	// unless they have the same material.
+	t := newTable(code)
+	fixture := fixtures[code]
+	pieces := pieceCodes(fixture.pieces)
+	stms := []chess.Color{chess.White, chess.Black}
+	if dtz {
+		stms = []chess.Color{chess.Color(flags & flagSTM)}
+	} else if t.key == t.key2 {
+		stms = stms[:1]
+	}
+	files := 1
+	if t.hasPawns {
+		files = 4
+	}
+	var parts []packed
+	for f := range files {
+		gs := groups(t, pieces, fixture.order, f)
+		for _, stm := range stms {
+			values := make([]int, indexSize(gs))
+			for idx := range values {
+				values[idx] = value(setUp(pieces, place(t, pieces, gs, f, uint64(idx)), stm))
+			}
+			parts = append(parts, pack(flags, values))
+		}
+	}
+
+	magic := wdlMagic
+	if dtz {
+		magic = dtzMagic
+	}
+	buf := append([]byte{}, magic[:]...)
+	var header byte
+	if len(stms) == 2 {
+		header |= 1
+	}
+	if t.hasPawns {
+		header |= 2
+	}
+	buf = append(buf, header)
+	for range files {
+		// both sides list the pieces the same way
+		buf = append(buf, byte(fixture.order[0]|fixture.order[0]<<4))
+		if t.hasPawns && t.pawnCount[1] > 0 {
+			buf = append(buf, byte(fixture.order[1]|fixture.order[1]<<4))
+		}
+		for _, p := range pieces {
+			buf = append(buf, byte(p|p<<4))
+		}
+	}
+	buf = append(buf, make([]byte, len(buf)&1)...)
+	for _, p := range parts {
+		buf = append(buf, p.sizes...)
+	}
+	if dtz {
+		buf = append(buf, make([]byte, len(buf)&1)...)
+	}
+	for _, p := range parts {
+		buf = append(buf, p.sparse...)
+	}
+	for _, p := range parts {
+		buf = append(buf, p.lengths...)
+	}
+	for _, p := range parts {
+		buf = append(buf, make([]byte, -len(buf)&0x3F)...)
+		buf = append(buf, p.data...)
+	}
+	// room for the decoder reading ahead, then the length syzygy files have
+	buf = append(buf, make([]byte, 64)...)
+	buf = append(buf, make([]byte, (16-len(buf))&0x3F)...)
+	path := filepath.Join(dir, code+".rtbw")
+	if dtz {
+		path = filepath.Join(dir, code+".rtbz")
+	}
+	return os.WriteFile(path, buf, 0o644)
+}
+
+// one part of a file: its entry among the sizes, sparse index, block
+// lengths and blocks
+type packed struct {
+	sizes, sparse, lengths, data []byte
+}
+
+// a value, or a pair of symbols standing for their values one after
+// the other
+type symbol struct {
+	left, right int // right is -1 for a value
+	length      int // the values it stands for
+}
+
+func pack(flags uint8, values []int) packed {
+	// compresses values the way the syzygy generator does: the pairs
+	// of symbols seen most often become new symbols while they save
+	// something, then the symbols left are huffman coded into blocks.
+	// an index no position has takes the value before it so that it
+	// pairs up with it.
+	const blockBits, spanBits = 6, 8
+	const blockSize, span = 1 << blockBits, 1 << spanBits
+	const maxSymbols = 1024
+
+	first := slices.IndexFunc(values, func(v int) bool { return v >= 0 })
+	if first < 0 {
+		first = 0
+		values = []int{0}
+	}
+	var symbols []symbol
+	leaves := map[int]int32{}
+	stream := make([]int32, len(values))
+	v := values[first]
+	for i := range values {
+		if values[i] >= 0 {
+			v = values[i]
+		}
+		leaf, ok := leaves[v]
+		if !ok {
+			leaf = int32(len(symbols))
+			leaves[v] = leaf
+			symbols = append(symbols, symbol{v, -1, 1})
+		}
+		stream[i] = leaf
+	}
+	if len(symbols) == 1 {
+		return packed{sizes: []byte{flags | flagSingleValue, byte(symbols[0].left)}}
+	}
+
+	counts := make([]int32, maxSymbols*maxSymbols)
+	pairs := make([]int32, maxSymbols*maxSymbols) // the symbol of a pair, 0 for none
+	for len(symbols) < maxSymbols {
+		clear(counts)
+		for i := 1; i < len(stream); i++ {
+			counts[stream[i-1]*maxSymbols+stream[i]]++
+		}
+		var frequent []int
+		for k, n := range counts {
+			// a symbol stands for 256 values at most
+			if n >= 16 && symbols[k/maxSymbols].length+symbols[k%maxSymbols].length <= 256 {
+				frequent = append(frequent, k)
+			}
+		}
+		if len(frequent) == 0 {
+			break
+		}
+		slices.SortStableFunc(frequent, func(a, b int) int { return int(counts[b] - counts[a]) })
+		frequent = frequent[:min(len(frequent), 64, maxSymbols-len(symbols))]
+		for _, k := range frequent {
+			l, r := k/maxSymbols, k%maxSymbols
+			pairs[k] = int32(len(symbols))
+			symbols = append(symbols, symbol{l, r, symbols[l].length + symbols[r].length})
+		}
+		n := 0
+		for i := 0; i < len(stream); i++ {
+			if i+1 < len(stream) {
+				if p := pairs[stream[i]*maxSymbols+stream[i+1]]; p != 0 {
+					stream[n] = p
+					n++
+					i++
+					continue
+				}
+			}
+			stream[n] = stream[i]
+			n++
+		}
+		stream = stream[:n]
+		for _, k := range frequent {
+			pairs[k] = 0
+		}
+	}
+
+	// canonical huffman code: longer codes come first and have lower
+	// values, each length starting where the longer ones left off
+	weights := make([]int, len(symbols))
+	for _, s := range stream {
+		weights[s]++
+	}
+	lengths := codeLengths(weights)
+	minLen, maxLen := slices.Min(lengths), slices.Max(lengths)
+	if maxLen > 32 {
+		panic("huffman code longer than 32 bits")
+	}
+	ids := make([]int, len(symbols))
+	byLength := make([]int, len(symbols))
+	for s := range byLength {
+		byLength[s] = s
+	}
+	slices.SortStableFunc(byLength, func(a, b int) int { return lengths[b] - lengths[a] })
+	perLength := make([]int, maxLen+2)
+	for id, s := range byLength {
+		ids[s] = id
+		perLength[lengths[s]]++
+	}
+	lowest := make([]int, maxLen+1)
+	base := make([]int, maxLen+1)
+	for l := maxLen - 1; l >= minLen; l-- {
+		lowest[l] = lowest[l+1] + perLength[l+1]
+		if (base[l+1]+perLength[l+1])%2 != 0 {
+			panic("huffman code isn't complete")
+		}
+		base[l] = (base[l+1] + perLength[l+1]) / 2
+	}
+
+	// whole symbols in each block, none standing for more values than
+	// the block lengths and sparse index offsets hold
+	var p packed
+	var starts []int
+	bit, total := blockSize*8, 0
+	for _, s := range stream {
+		l := lengths[s]
+		if bit+l > blockSize*8 || total+symbols[s].length-starts[len(starts)-1] > 1<<15 {
+			if len(starts) > 0 {
+				p.lengths = binary.LittleEndian.AppendUint16(p.lengths, uint16(total-starts[len(starts)-1]-1))
+			}
+			starts = append(starts, total)
+			p.data = append(p.data, make([]byte, blockSize)...)
+			bit = 0
+		}
+		code := base[l] + ids[s] - lowest[l]
+		block := p.data[len(p.data)-blockSize:]
+		for j := range l {
+			if code>>(l-1-j)&1 != 0 {
+				block[(bit+j)/8] |= 0x80 >> ((bit + j) % 8)
+			}
+		}
+		bit += l
+		total += symbols[s].length
+	}
+	p.lengths = binary.LittleEndian.AppendUint16(p.lengths, uint16(total-starts[len(starts)-1]-1))
+
+	// the sparse index gives the block and offset of the middle of
+	// every span, past the end of the last block for the last span
+	block := 0
+	for middle := span / 2; middle-span/2 < len(values); middle += span {
+		for block+1 < len(starts) && starts[block+1] <= middle {
+			block++
+		}
+		p.sparse = binary.LittleEndian.AppendUint32(p.sparse, uint32(block))
+		p.sparse = binary.LittleEndian.AppendUint16(p.sparse, uint16(middle-starts[block]))
+	}
+
+	p.sizes = []byte{flags, blockBits, spanBits, 0}
+	p.sizes = binary.LittleEndian.AppendUint32(p.sizes, uint32(len(starts)))
+	p.sizes = append(p.sizes, byte(maxLen), byte(minLen))
+	for l := minLen; l <= maxLen; l++ {
+		p.sizes = binary.LittleEndian.AppendUint16(p.sizes, uint16(lowest[l]))
+	}
+	p.sizes = binary.LittleEndian.AppendUint16(p.sizes, uint16(len(symbols)))
+	for _, s := range byLength {
+		left, right := symbols[s].left, 0xFFF
+		if symbols[s].right >= 0 {
+			left, right = ids[symbols[s].left], ids[symbols[s].right]
+		}
+		p.sizes = append(p.sizes, byte(left), byte(left>>8)|byte(right<<4), byte(right>>4))
+	}
+	if len(symbols)&1 != 0 {
+		p.sizes = append(p.sizes, 0)
+	}
+	return p
+}
+
+func codeLengths(weights []int) []int {
+	// the huffman code length of every symbol. each is counted once
+	// more than it's used so that the ones only found inside pairs
+	// get a code too.
+	type node struct{ weight, parent int }
+	nodes := make([]node, len(weights))
+	leaves := make([]int, len(weights))
+	for s, w := range weights {
+		nodes[s] = node{w + 1, -1}
+		leaves[s] = s
+	}
+	slices.SortStableFunc(leaves, func(a, b int) int { return nodes[a].weight - nodes[b].weight })
+	// merged nodes come out in order of weight too, so the lightest
+	// two are always at the front of one queue or the other
+	var merged []int
+	lightest := func() int {
+		if len(merged) > 0 && (len(leaves) == 0 || nodes[merged[0]].weight < nodes[leaves[0]].weight) {
+			n := merged[0]
+			merged = merged[1:]
+			return n
+		}
+		n := leaves[0]
+		leaves = leaves[1:]
+		return n
+	}
+	for range len(weights) - 1 {
+		a, b := lightest(), lightest()
+		nodes = append(nodes, node{nodes[a].weight + nodes[b].weight, -1})
+		nodes[a].parent, nodes[b].parent = len(nodes)-1, len(nodes)-1
+		merged = append(merged, len(nodes)-1)
+	}
+	depth := make([]int, len(nodes))
+	for n := len(nodes) - 2; n >= 0; n-- {
+		depth[n] = depth[nodes[n].parent] + 1
+	}
+	return depth[:len(weights)]
+}
@@ -0,0 +1,371 @@
+package tablebase
+
+import (
+	chess "chess/board"
+	"testing"
+)
+
+// the generated tables are laid out by the decoder here, which goes
+// from an index to the position it stands for following the syzygy
+// format description, so encode is checked against something other
+// than itself
+
+// squares a2-h7 in the order leading pawns are numbered, from 0 up:
+// central files before the edge, higher ranks before lower ones
+var pawnSquares []int
+
+// the placements of the leading pieces of pawnless tables, by index
+var (
+	leadingTriples [][3]int // three unique pieces
+	leadingKings   [][2]int // the two kings
+)
+
+func init() {
+	for file := 3; file >= 0; file-- {
+		for rank := 6; rank >= 1; rank-- {
+			sq := rank*8 + file
+			pawnSquares = append(pawnSquares, sq^7, sq)
+		}
+	}
+
+	// the first leading piece is in the a1-d1-d4 triangle, the squares
+	// off the diagonal coming first. once it's on the diagonal the next
+	// piece off it is below.
+	triangle := []int{1, 2, 3, 10, 11, 19, 0, 9, 18, 27}
+	var below, diagonal []int
+	for sq := range 64 {
+		if sq/8 < sq%8 {
+			below = append(below, sq)
+		} else if sq/8 == sq%8 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, s0 := range triangle[:6] {
+		for s1 := range 64 {
+			for s2 := range 64 {
+				if s1 != s0 && s2 != s0 && s2 != s1 {
+					leadingTriples = append(leadingTriples, [3]int{s0, s1, s2})
+				}
+			}
+		}
+	}
+	for _, s0 := range triangle[6:] {
+		for _, s1 := range below {
+			for s2 := range 64 {
+				if s2 != s0 && s2 != s1 {
+					leadingTriples = append(leadingTriples, [3]int{s0, s1, s2})
+				}
+			}
+		}
+	}
+	for _, s0 := range triangle[6:] {
+		for _, s1 := range diagonal {
+			for _, s2 := range below {
+				if s1 != s0 {
+					leadingTriples = append(leadingTriples, [3]int{s0, s1, s2})
+				}
+			}
+		}
+	}
+	for _, s0 := range triangle[6:] {
+		for _, s1 := range diagonal {
+			for _, s2 := range diagonal {
+				if s1 != s0 && s2 != s0 && s2 != s1 {
+					leadingTriples = append(leadingTriples, [3]int{s0, s1, s2})
+				}
+			}
+		}
+	}
+
+	// the kings can't touch, and with both on the diagonal they're
+	// numbered last
+	var bothOnDiagonal [][2]int
+	for _, s0 := range triangle {
+		for s1 := range 64 {
+			if max(abs(s0/8-s1/8), abs(s0%8-s1%8)) <= 1 {
+				continue
+			}
+			switch {
+			case s0/8 == s0%8 && s1/8 > s1%8:
+			case s0/8 == s0%8 && s1/8 == s1%8:
+				bothOnDiagonal = append(bothOnDiagonal, [2]int{s0, s1})
+			default:
+				leadingKings = append(leadingKings, [2]int{s0, s1})
+			}
+		}
+	}
+	leadingKings = append(leadingKings, bothOnDiagonal...)
+}
+
+func choose(n, k int) uint64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	c := uint64(1)
+	for i := range k {
+		c = c * uint64(n-i) / uint64(i+1)
+	}
+	return c
+}
+
+func unrank(n uint64, k int) []int {
+	// the k numbers v1 < ... < vk with n = C(v1,1) + ... + C(vk,k)
+	v := make([]int, k)
+	for i := k; i >= 1; i-- {
+		x := i - 1
+		for choose(x+1, i) <= n {
+			x++
+		}
+		v[i-1] = x
+		n -= choose(x, i)
+	}
+	return v
+}
+
+func nthFree(v, from int, taken []int) int {
+	// the v-th square counting up from from that isn't taken
+	for sq := from; ; sq++ {
+		if !contains(taken, sq) {
+			if v == 0 {
+				return sq
+			}
+			v--
+		}
+	}
+}
+
+func contains(squares []int, sq int) bool {
+	for _, s := range squares {
+		if s == sq {
+			return true
+		}
+	}
+	return false
+}
+
+// a group of pieces placed together: pieces[first:first+count]
+type group struct {
+	first, count int
+	size         uint64 // the ways to place it
+}
+
+func groups(t *table, pieces []int, order [2]int, file int) []group {
+	// splits the pieces into the groups of the index, in the order of
+	// their multipliers from 1 up
+	var lead group
+	switch {
+	case t.hasPawns:
+		lead.count = t.pawnCount[0]
+		for rank := 1; rank <= 6; rank++ {
+			lead.size += choose(pawnValue(rank*8+file), lead.count-1)
+		}
+	case t.hasUniquePieces:
+		lead.count, lead.size = 3, uint64(len(leadingTriples))
+	default:
+		lead.count, lead.size = 2, uint64(len(leadingKings))
+	}
+	placed := lead.count
+	var second []group // the other colour's pawns
+	if t.hasPawns && t.pawnCount[1] > 0 {
+		second = append(second, group{placed, t.pawnCount[1], choose(48-placed, t.pawnCount[1])})
+		placed += t.pawnCount[1]
+	}
+	var rest []group
+	for placed < len(pieces) {
+		g := group{first: placed, count: 1}
+		for placed+g.count < len(pieces) && pieces[placed+g.count] == pieces[placed] {
+			g.count++
+		}
+		g.size = choose(64-placed, g.count)
+		rest = append(rest, g)
+		placed += g.count
+	}
+
+	var ordered []group
+	for k := 0; len(rest) > 0 || k == order[0] || k == order[1] && second != nil; k++ {
+		switch {
+		case k == order[0]:
+			ordered = append(ordered, lead)
+		case k == order[1] && second != nil:
+			ordered = append(ordered, second...)
+		default:
+			ordered, rest = append(ordered, rest[0]), rest[1:]
+		}
+	}
+	return ordered
+}
+
+func pawnValue(sq int) int {
+	for v, s := range pawnSquares {
+		if s == sq {
+			return v
+		}
+	}
+	return -1
+}
+
+func indexSize(gs []group) uint64 {
+	size := uint64(1)
+	for _, g := range gs {
+		size *= g.size
+	}
+	return size
+}
+
+func place(t *table, pieces []int, gs []group, file int, idx uint64) []int {
+	// the squares of pieces at idx in the part of the table for file
+	n := make([]uint64, len(pieces))
+	for _, g := range gs {
+		n[g.first] = idx % g.size
+		idx /= g.size
+	}
+
+	squares := make([]int, 0, len(pieces))
+	for _, g := range gs {
+		if g.first != 0 {
+			continue
+		}
+		switch {
+		case t.hasPawns:
+			k := n[0]
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				block := choose(pawnValue(sq), g.count-1)
+				if k < block {
+					squares = append(squares, sq)
+					for _, v := range unrank(k, g.count-1) {
+						squares = append(squares, pawnSquares[v])
+					}
+					break
+				}
+				k -= block
+			}
+		case g.count == 3:
+			squares = append(squares, leadingTriples[n[0]][:]...)
+		default:
+			squares = append(squares, leadingKings[n[0]][:]...)
+		}
+	}
+	for len(squares) < len(pieces) {
+		first := len(squares)
+		for _, g := range gs {
+			if g.first != first {
+				continue
+			}
+			// the other colour's pawns are numbered from a2, the rest
+			// of the pieces from a1, skipping the squares taken
+			from := 0
+			if t.hasPawns && first == t.pawnCount[0] && t.pawnCount[1] > 0 {
+				from = 8
+			}
+			for _, v := range unrank(n[first], g.count) {
+				squares = append(squares, nthFree(v, from, squares[:first]))
+			}
+		}
+	}
+	return squares
+}
+
+func setUp(pieces, squares []int, stm chess.Color) *chess.Board {
+	// the board with pieces (in the syzygy numbering) on squares
+	b := &chess.Board{Turn: stm, RKRmoved: [2][3]bool{{true, true, true}, {true, true, true}}}
+	for i, p := range pieces {
+		b.PieceBB[p>>3][p&7] |= 1 << squares[i]
+	}
+	b.CombineBB()
+	return b
+}
+
+func TestKnownIndices(t *testing.T) {
+	// indices worked out by hand from the format description
+	for _, c := range []struct {
+		fen string
+		idx uint64
+	}{
+		// Qc2 is the 4th square of the triangle, Kh8 the 63rd of the
+		// squares left and ka1 the first
+		{"7K/8/8/8/8/8/2Q5/k7 w - - 0 1", (3*63 + 62) * 62},
+		// turned round to Qc2 Kb8 kh1
+		{"k7/5Q2/8/8/8/8/8/6K1 w - - 0 1", (3*63+56)*62 + 7},
+		// Qd4 on the diagonal, then Kb3 above it flips to c2, the 8th
+		// square below the diagonal, and ka8 to h1
+		{"k7/8/8/8/3Q4/1K6/8/8 w - - 0 1", (6*63+3*28+7)*62 + 7},
+		// Kc2 ka8 after the 3*58 placements with the king on b1-d1,
+		// then the knights on the 1st and 8th squares left
+		{"k7/8/8/8/8/8/2K5/N6N w - - 0 1", (3*58 + 47) + 462*(0+21)},
+		// the knight on e4 is the 27th square left, the leading group
+		// written as the more significant, and the same for black to move
+		{"7K/8/8/8/4n3/8/2R5/k7 w - - 0 1", 26 + 61*((3*63+62)*62)},
+		{"7K/8/8/8/4n3/8/2R5/k7 b - - 0 1", 26 + 61*((3*63+62)*62)},
+		// e5 mirrors to d5, the 4th square of the d file, then Kd1
+		// and kd8 among the squares left
+		{"4k3/8/8/4P3/8/8/8/4K3 w - - 0 1", 3 + 6*(3+63*57)},
+		// the black pawn on c7 is the 42nd of a2-h7 left and written
+		// first, then d5 and Ka1 kh8
+		{"7k/2p5/8/3P4/8/8/8/K7 w - - 0 1", 41 + 47*(3+6*(0+62*60))},
+		// two leading pawns written after both kings: a2 leads its file
+		// and c5 is the 18th pawn square from e7
+		{"3k4/8/8/2P5/8/8/P7/3K4 w - - 0 1", 3 + 62*(56+61*(0+17))},
+	} {
+		b, err := chess.NewBoardFromFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tb := lookup(b)
+		td, err := tb.loadWDL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, idx, _, _ := tb.encode(b, td, int(b.Turn), false); idx != c.idx {
+			t.Errorf("%s: index %d, expected %d", c.fen, idx, c.idx)
+		}
+	}
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	// the prober encodes the position at every index back to that index
+	stride := uint64(7)
+	if testing.Short() {
+		stride = 61
+	}
+	for _, code := range []string{"KQvK", "KRvK", "KPvK", "KRvKN", "KNNvK", "KPvKP", "KPPvK"} {
+		tb := tables[code]
+		for _, dtz := range []bool{false, true} {
+			var td *tableData
+			var err error
+			if dtz {
+				if tb.dtzPath == "" {
+					continue
+				}
+				td, err = tb.loadDTZ()
+			} else {
+				td, err = tb.loadWDL()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := 1
+			if tb.hasPawns {
+				files = 4
+			}
+			for side := range td.sides {
+				for f := range files {
+					d := &td.items[side][f]
+					stm := side
+					if dtz {
+						stm = int(d.flags & flagSTM)
+					}
+					pieces := d.pieces[:tb.pieceCount]
+					gs := groups(tb, pieces, fixtures[code].order, f)
+					for idx := uint64(0); idx < indexSize(gs); idx += stride {
+						b := setUp(pieces, place(tb, pieces, gs, f, idx), chess.Color(stm))
+						got, i, _, _ := tb.encode(b, td, stm, false)
+						if got != d || i != idx {
+							t.Fatalf("%s: %s at index %d encoded to %d", code, b.ToFEN(), idx, i)
+						}
+					}
+				}
+			}
+		}
+	}
+}
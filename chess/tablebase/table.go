@@ -0,0 +1,488 @@
+package tablebase
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	wdlMagic = [4]byte{0x71, 0xE8, 0x23, 0x5D}
+	dtzMagic = [4]byte{0xD7, 0x66, 0x0C, 0xA5}
+)
+
+// flags stored with every pairsData
+const (
+	flagSTM         = 1
+	flagMapped      = 2
+	flagWinPlies    = 4
+	flagLossPlies   = 8
+	flagWide        = 16
+	flagSingleValue = 128
+)
+
+type table struct {
+	key, key2       string
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // leading colour first
+	wdlPath         string
+	dtzPath         string
+
+	wdlOnce, dtzOnce sync.Once
+	wdl, dtz         *tableData
+	wdlErr, dtzErr   error
+}
+
+type tableData struct {
+	dtz    bool
+	sides  int
+	buf    []byte
+	items  [2][4]pairsData // [side][file]
+	dtzMap int             // offset of the dtz value map
+}
+
+type pairsData struct {
+	flags           uint8
+	pieces          [7]int
+	groupLen        [8]int
+	groupIdx        [8]uint64
+	sizeofBlock     uint64
+	span            uint64
+	numBlocks       uint32
+	minSymLen       uint8
+	lowestSym       int // offset of the lowest symbol per length
+	base64          []uint64
+	symlen          []uint8
+	btree           int // offset of the 3 byte symbol pairs
+	sparseIndex     int
+	sparseIndexSize uint64
+	blockLength     int
+	blockLengthSize uint64
+	data            int
+	mapIdx          [4]int
+}
+
+func newTable(code string) *table {
+	// describes the table for a material code like KRPvKR
+	t := &table{}
+	var white, black string
+	for i, r := range code {
+		if r == 'v' {
+			white, black = code[:i], code[i+1:]
+		}
+	}
+	t.key = white + "v" + black
+	t.key2 = black + "v" + white
+	t.pieceCount = len(white) + len(black)
+	count := func(side string, r byte) int {
+		n := 0
+		for i := range len(side) {
+			if side[i] == r {
+				n++
+			}
+		}
+		return n
+	}
+	for _, side := range []string{white, black} {
+		for _, r := range []byte("QRBNP") {
+			if count(side, r) == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+	}
+	whitePawns, blackPawns := count(white, 'P'), count(black, 'P')
+	t.hasPawns = whitePawns+blackPawns > 0
+	// the side with fewer pawns leads as it compresses better
+	if blackPawns == 0 || (whitePawns > 0 && blackPawns >= whitePawns) {
+		t.pawnCount = [2]int{whitePawns, blackPawns}
+	} else {
+		t.pawnCount = [2]int{blackPawns, whitePawns}
+	}
+	return t
+}
+
+func (t *table) loadWDL() (*tableData, error) {
+	t.wdlOnce.Do(func() {
+		t.wdl, t.wdlErr = t.load(t.wdlPath, false)
+	})
+	return t.wdl, t.wdlErr
+}
+
+func (t *table) loadDTZ() (*tableData, error) {
+	t.dtzOnce.Do(func() {
+		if t.dtzPath == "" {
+			t.dtzErr = fmt.Errorf("no dtz table for %s", t.key)
+			return
+		}
+		t.dtz, t.dtzErr = t.load(t.dtzPath, true)
+	})
+	return t.dtz, t.dtzErr
+}
+
+func (t *table) load(path string, dtz bool) (*tableData, error) {
+	// reads a whole table file and decodes its header
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := wdlMagic
+	if dtz {
+		magic = dtzMagic
+	}
+	if len(buf)%64 != 16 || [4]byte(buf[:4]) != magic {
+		return nil, fmt.Errorf("%s: corrupted table", path)
+	}
+	td := &tableData{dtz: dtz, buf: buf, sides: 1}
+	if !dtz && t.key != t.key2 {
+		td.sides = 2
+	}
+	if err := td.setup(t); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return td, nil
+}
+
+func (td *tableData) get(t *table, stm, file int) *pairsData {
+	if !t.hasPawns {
+		file = 0
+	}
+	return &td.items[stm%td.sides][file]
+}
+
+func (td *tableData) checkSTM(t *table, stm, file int) bool {
+	// dtz tables only store one side to move
+	flags := td.get(t, stm, file).flags
+	return int(flags&flagSTM) == stm || (t.key == t.key2 && !t.hasPawns)
+}
+
+func (td *tableData) setup(t *table) (err error) {
+	// walks the header: piece order and groups per file, then the
+	// huffman tables, dtz maps, sparse indexes, block lengths and
+	// finally the 64 byte aligned blocks themselves
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("truncated table")
+		}
+	}()
+	buf := td.buf
+	pos := 4
+	const hasPawns = 2
+	if (buf[pos]&hasPawns != 0) != t.hasPawns {
+		return fmt.Errorf("pawn flag doesn't match %s", t.key)
+	}
+	pos++
+
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	for f := 0; f <= maxFile; f++ {
+		order := [2][2]int{{int(buf[pos] & 0xF), 0xF}, {int(buf[pos] >> 4), 0xF}}
+		if pp {
+			order[0][1] = int(buf[pos+1] & 0xF)
+			order[1][1] = int(buf[pos+1] >> 4)
+			pos++
+		}
+		pos++
+		for k := 0; k < t.pieceCount; k++ {
+			for i := 0; i < td.sides; i++ {
+				if i == 0 {
+					td.items[i][f].pieces[k] = int(buf[pos] & 0xF)
+				} else {
+					td.items[i][f].pieces[k] = int(buf[pos] >> 4)
+				}
+			}
+			pos++
+		}
+		for i := 0; i < td.sides; i++ {
+			td.items[i][f].setGroups(t, order[i], f)
+		}
+	}
+	pos += pos & 1
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < td.sides; i++ {
+			pos = td.items[i][f].setSizes(buf, pos)
+		}
+	}
+
+	if td.dtz {
+		td.dtzMap = pos
+		for f := 0; f <= maxFile; f++ {
+			d := &td.items[0][f]
+			if d.flags&flagMapped == 0 {
+				continue
+			}
+			if d.flags&flagWide != 0 {
+				pos += pos & 1
+				for i := range 4 {
+					d.mapIdx[i] = (pos-td.dtzMap)/2 + 1
+					pos += 2*int(binary.LittleEndian.Uint16(buf[pos:])) + 2
+				}
+			} else {
+				for i := range 4 {
+					d.mapIdx[i] = pos - td.dtzMap + 1
+					pos += int(buf[pos]) + 1
+				}
+			}
+		}
+		pos += pos & 1
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < td.sides; i++ {
+			d := &td.items[i][f]
+			d.sparseIndex = pos
+			pos += int(d.sparseIndexSize) * 6
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < td.sides; i++ {
+			d := &td.items[i][f]
+			d.blockLength = pos
+			pos += int(d.blockLengthSize) * 2
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < td.sides; i++ {
+			d := &td.items[i][f]
+			pos = (pos + 0x3F) &^ 0x3F
+			d.data = pos
+			pos += int(d.numBlocks) * int(d.sizeofBlock)
+		}
+	}
+	if pos > len(buf) {
+		return fmt.Errorf("truncated table")
+	}
+	return nil
+}
+
+func (d *pairsData) setGroups(t *table, order [2]int, file int) {
+	// splits the pieces into groups of identical pieces (the first
+	// group holding the leading pieces) and works out the multiplier
+	// of each group in the index
+	n := 0
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+	d.groupLen[n] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	if pp {
+		next = 2
+	}
+	freeSquares := 64 - d.groupLen[0]
+	if pp {
+		freeSquares -= d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]:
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= leadPawnsSize[d.groupLen[0]][file]
+			case t.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]:
+			d.groupIdx[1] = idx
+			idx *= binomial[d.groupLen[1]][48-d.groupLen[0]]
+		default:
+			d.groupIdx[next] = idx
+			idx *= binomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+func (d *pairsData) setSizes(buf []byte, pos int) int {
+	// reads the block layout and the canonical huffman code used
+	// to compress this part of the table
+	d.flags = buf[pos]
+	pos++
+	if d.flags&flagSingleValue != 0 {
+		d.minSymLen = buf[pos] // the single value
+		return pos + 1
+	}
+
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	tbSize := d.groupIdx[n]
+
+	d.sizeofBlock = 1 << buf[pos]
+	d.span = 1 << buf[pos+1]
+	d.sparseIndexSize = (tbSize + d.span - 1) / d.span
+	padding := uint64(buf[pos+2])
+	d.numBlocks = binary.LittleEndian.Uint32(buf[pos+3:])
+	d.blockLengthSize = uint64(d.numBlocks) + padding
+	maxSymLen := buf[pos+7]
+	d.minSymLen = buf[pos+8]
+	pos += 9
+	d.lowestSym = pos
+
+	// longer codes have lower values, base64[i] is the lowest code of
+	// length minSymLen+i left aligned in 64 bits
+	d.base64 = make([]uint64, int(maxSymLen)-int(d.minSymLen)+1)
+	for i := len(d.base64) - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowest(buf, i)) - uint64(d.lowest(buf, i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= uint(64 - i - int(d.minSymLen))
+	}
+	pos += len(d.base64) * 2
+
+	symbols := int(binary.LittleEndian.Uint16(buf[pos:]))
+	pos += 2
+	d.btree = pos
+	d.symlen = make([]uint8, symbols)
+	visited := make([]bool, symbols)
+	for s := range symbols {
+		if !visited[s] {
+			d.symlen[s] = d.setSymlen(buf, s, visited)
+		}
+	}
+	return pos + symbols*3 + symbols&1
+}
+
+func (d *pairsData) lowest(buf []byte, i int) uint16 {
+	return binary.LittleEndian.Uint16(buf[d.lowestSym+2*i:])
+}
+
+func (d *pairsData) left(buf []byte, s int) int {
+	p := d.btree + 3*s
+	return int(buf[p+1]&0xF)<<8 | int(buf[p])
+}
+
+func (d *pairsData) right(buf []byte, s int) int {
+	p := d.btree + 3*s
+	return int(buf[p+2])<<4 | int(buf[p+1]>>4)
+}
+
+func (d *pairsData) setSymlen(buf []byte, s int, visited []bool) uint8 {
+	// number of values (minus one) a symbol expands to
+	visited[s] = true
+	r := d.right(buf, s)
+	if r == 0xFFF {
+		return 0
+	}
+	l := d.left(buf, s)
+	if !visited[l] {
+		d.symlen[l] = d.setSymlen(buf, l, visited)
+	}
+	if !visited[r] {
+		d.symlen[r] = d.setSymlen(buf, r, visited)
+	}
+	return d.symlen[l] + d.symlen[r] + 1
+}
+
+func (d *pairsData) decompress(buf []byte, idx uint64) int {
+	// finds the value stored at idx: the sparse index gives a nearby
+	// block, block lengths move to the right one, then huffman
+	// symbols are read until the one covering idx is found and its
+	// pair tree is walked down to a single value
+	if d.flags&flagSingleValue != 0 {
+		return int(d.minSymLen)
+	}
+
+	k := idx / d.span
+	entry := d.sparseIndex + 6*int(k)
+	block := int(binary.LittleEndian.Uint32(buf[entry:]))
+	offset := int(binary.LittleEndian.Uint16(buf[entry+4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+
+	blockLength := func(i int) int {
+		return int(binary.LittleEndian.Uint16(buf[d.blockLength+2*i:]))
+	}
+	for offset < 0 {
+		block--
+		offset += blockLength(block) + 1
+	}
+	for offset > blockLength(block) {
+		offset -= blockLength(block) + 1
+		block++
+	}
+
+	ptr := d.data + block*int(d.sizeofBlock)
+	buf64 := binary.BigEndian.Uint64(buf[ptr:])
+	ptr += 8
+	buf64Size := 64
+	var sym int
+	for {
+		length := 0
+		for buf64 < d.base64[length] {
+			length++
+		}
+		sym = int((buf64 - d.base64[length]) >> uint(64-length-int(d.minSymLen)))
+		sym += int(d.lowest(buf, length))
+		if offset < int(d.symlen[sym])+1 {
+			break
+		}
+		offset -= int(d.symlen[sym]) + 1
+		length += int(d.minSymLen)
+		buf64 <<= uint(length)
+		buf64Size -= length
+		if buf64Size <= 32 {
+			buf64Size += 32
+			buf64 |= uint64(binary.BigEndian.Uint32(buf[ptr:])) << uint(64-buf64Size)
+			ptr += 4
+		}
+	}
+
+	for d.symlen[sym] != 0 {
+		left := d.left(buf, sym)
+		if offset < int(d.symlen[left])+1 {
+			sym = left
+		} else {
+			offset -= int(d.symlen[left]) + 1
+			sym = d.right(buf, sym)
+		}
+	}
+	return d.left(buf, sym)
+}
+
+func (td *tableData) mapDTZ(t *table, file int, value int, wdl WDL) int {
+	// converts a stored dtz value into plies
+	wdlMap := [5]int{1, 3, 0, 2, 0}
+	d := td.get(t, 0, file)
+	if d.flags&flagMapped != 0 {
+		i := d.mapIdx[wdlMap[wdl+2]] + value
+		if d.flags&flagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(td.buf[td.dtzMap+2*i:]))
+		} else {
+			value = int(td.buf[td.dtzMap+i])
+		}
+	}
+	if (wdl == Win && d.flags&flagWinPlies == 0) ||
+		(wdl == Loss && d.flags&flagLossPlies == 0) ||
+		wdl == CursedWin || wdl == BlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
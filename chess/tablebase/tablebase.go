@@ -0,0 +1,398 @@
+package tablebase
+
+import (
+	chess "chess/board"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type WDL int
+
+const (
+	Loss        WDL = -2
+	BlessedLoss WDL = -1 // loss saved by the fifty move rule
+	Draw        WDL = 0
+	CursedWin   WDL = 1 // win spoiled by the fifty move rule
+	Win         WDL = 2
+)
+
+type Result struct {
+	WDL WDL
+	DTZ int // plies to the next capture or pawn move, signed like WDL. 0 when unknown
+}
+
+type probeState int
+
+const (
+	probeFail      probeState = iota
+	probeOK                   // value is correct
+	probeChangeSTM            // dtz table stores the other side to move
+	probeZeroing              // best move is a capture or pawn move
+)
+
+var (
+	mu        sync.RWMutex
+	tables    = map[string]*table{}
+	maxPieces int
+)
+
+func (w WDL) String() string {
+	switch w {
+	case Loss:
+		return "loss"
+	case BlessedLoss:
+		return "blessed loss"
+	case CursedWin:
+		return "cursed win"
+	case Win:
+		return "win"
+	}
+	return "draw"
+}
+
+func Init(dir string) error {
+	// registers every syzygy table found in dir (several directories
+	// may be separated by the os path list separator). an empty dir
+	// unloads the tables.
+	found := map[string]*table{}
+	largest := 0
+	if dir != "" {
+		for _, d := range filepath.SplitList(dir) {
+			entries, err := os.ReadDir(d)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				name := entry.Name()
+				code := strings.TrimSuffix(name, ".rtbw")
+				if code == name || !validCode(code) {
+					continue
+				}
+				t := newTable(code)
+				t.wdlPath = filepath.Join(d, name)
+				if _, err := os.Stat(filepath.Join(d, code+".rtbz")); err == nil {
+					t.dtzPath = filepath.Join(d, code+".rtbz")
+				}
+				found[t.key] = t
+				found[t.key2] = t
+				largest = max(largest, t.pieceCount)
+			}
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("no syzygy tables found in %s", dir)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	tables = found
+	maxPieces = largest
+	return nil
+}
+
+func validCode(code string) bool {
+	// material codes look like KRPvKR: two sides each led by a king
+	sides := strings.Split(code, "v")
+	if len(sides) != 2 {
+		return false
+	}
+	for _, side := range sides {
+		if side == "" || side[0] != 'K' || strings.Trim(side[1:], "QRBNP") != "" {
+			return false
+		}
+	}
+	return len(code)-1 <= 7
+}
+
+func MaxPieces() int {
+	// the largest piece count (kings included) with a table available
+	mu.RLock()
+	defer mu.RUnlock()
+	return maxPieces
+}
+
+func materialKey(b *chess.Board, c chess.Color) string {
+	// writes the material of side c against the other as a table code
+	var sb strings.Builder
+	for _, side := range []chess.Color{c, c.Other()} {
+		sb.WriteByte('K')
+		for _, p := range []chess.Piece{chess.Queens, chess.Rooks, chess.Bishops, chess.Knights, chess.Pawns} {
+			sb.WriteString(strings.Repeat(string("PNBRQ"[p-1]), b.PieceBB[side][p].Count()))
+		}
+		if side == c {
+			sb.WriteByte('v')
+		}
+	}
+	return sb.String()
+}
+
+func canProbe(b *chess.Board) bool {
+	// tables hold no castling rights and only go up to maxPieces
+	return b.FullBB.Count() <= MaxPieces() && b.CastlingString() == "-"
+}
+
+func ProbeWDL(b *chess.Board) (WDL, bool) {
+	// returns the win/draw/loss value for the side to move, taking
+	// en passant into account. false if no table covers b.
+	if !canProbe(b) {
+		return Draw, false
+	}
+	wdl, state := search(b, false)
+	return wdl, state != probeFail
+}
+
+func ProbeDTZ(b *chess.Board) (int, bool) {
+	// returns the distance in plies to the next zeroing move with
+	// optimal play: positive when winning, negative when losing,
+	// and with 100 added to cursed wins and blessed losses
+	if !canProbe(b) {
+		return 0, false
+	}
+	dtz, state := probeDTZ(b)
+	return dtz, state != probeFail
+}
+
+func Probe(b *chess.Board) (Result, bool) {
+	// probes both tables. DTZ is left at 0 if only the wdl table exists.
+	wdl, ok := ProbeWDL(b)
+	if !ok {
+		return Result{}, false
+	}
+	result := Result{WDL: wdl}
+	if dtz, ok := ProbeDTZ(b); ok {
+		result.DTZ = dtz
+	}
+	return result, true
+}
+
+func ProbeRoot(b *chess.Board) ([]chess.Move, Result, bool) {
+	// ranks the root moves with the dtz tables and returns the ones
+	// keeping the best result: the fastest progress when winning (so the
+	// fifty move rule can't spoil it), every move holding a draw, or the
+	// longest resistance when losing
+	if !canProbe(b) {
+		return nil, Result{}, false
+	}
+	result, ok := Probe(b)
+	if !ok {
+		return nil, Result{}, false
+	}
+	type ranked struct {
+		move chess.Move
+		rank int
+	}
+	var moves []ranked
+	bestRank := -1 << 30
+	for _, m := range b.LegalMoves() {
+		next := *b
+		next.MakeMove(m)
+		var dtz int
+		if next.HalfMoveClock == 0 {
+			wdl, ok := ProbeWDL(&next)
+			if !ok {
+				return nil, Result{}, false
+			}
+			dtz = dtzBeforeZeroing(-wdl)
+		} else {
+			d, ok := ProbeDTZ(&next)
+			if !ok {
+				return nil, Result{}, false
+			}
+			dtz = -d
+			if dtz > 0 {
+				dtz++
+			} else if dtz < 0 {
+				dtz--
+			}
+		}
+		if next.InCheck() && dtz == 2 && !next.HasLegalMoves() {
+			dtz = 1
+		}
+		rank := 0
+		clock := int(b.HalfMoveClock)
+		if dtz > 0 && dtz+clock <= 100 {
+			rank = 1000 - dtz
+		} else if dtz < 0 && -dtz+clock <= 100 {
+			rank = -1000 - dtz
+		}
+		moves = append(moves, ranked{m, rank})
+		bestRank = max(bestRank, rank)
+	}
+	var best []chess.Move
+	for _, r := range moves {
+		if r.rank == bestRank {
+			best = append(best, r.move)
+		}
+	}
+	return best, result, true
+}
+
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case Win:
+		return 1
+	case CursedWin:
+		return 101
+	case BlessedLoss:
+		return -101
+	case Loss:
+		return -1
+	}
+	return 0
+}
+
+func sign(v int) int {
+	if v > 0 {
+		return 1
+	} else if v < 0 {
+		return -1
+	}
+	return 0
+}
+
+func lookup(b *chess.Board) *table {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tables[materialKey(b, chess.White)]
+}
+
+func probeTable(b *chess.Board, dtz bool, wdl WDL) (int, probeState) {
+	// reads the raw value for b from its wdl or dtz table
+	if b.FullBB.Count() == 2 {
+		return int(Draw), probeOK // KvK
+	}
+	t := lookup(b)
+	if t == nil {
+		return 0, probeFail
+	}
+	var td *tableData
+	var err error
+	if dtz {
+		td, err = t.loadDTZ()
+	} else {
+		td, err = t.loadWDL()
+	}
+	if err != nil {
+		return 0, probeFail
+	}
+
+	// tables are stored with white as the side listed first and, when
+	// both sides have the same material, only for white to move
+	symmetricBlackToMove := t.key == t.key2 && b.Turn == chess.Black
+	blackStronger := materialKey(b, chess.White) != t.key
+	flip := symmetricBlackToMove || blackStronger
+	stm := int(b.Turn)
+	if flip {
+		stm ^= 1
+	}
+	d, idx, file, ok := t.encode(b, td, stm, flip)
+	if !ok {
+		return 0, probeChangeSTM
+	}
+	value := d.decompress(td.buf, idx)
+	if !dtz {
+		return value - 2, probeOK
+	}
+	return td.mapDTZ(t, file, value, wdl), probeOK
+}
+
+func search(b *chess.Board, checkZeroing bool) (WDL, probeState) {
+	// the tables don't know about en passant, so captures (and for dtz
+	// pawn moves) are played out first and the table is only trusted
+	// when it beats them
+	moves := b.LegalMoves()
+	best := Loss
+	count := 0
+	for _, m := range moves {
+		if !b.IsCapture(m) && (!checkZeroing || m.Piece != chess.Pawns) {
+			continue
+		}
+		count++
+		next := *b
+		next.MakeMove(m)
+		v, state := search(&next, false)
+		if state == probeFail {
+			return Draw, probeFail
+		}
+		v = -v
+		if v > best {
+			best = v
+			if v >= Win {
+				return v, probeZeroing
+			}
+		}
+	}
+
+	noMoreMoves := count > 0 && count == len(moves)
+	value := best
+	if !noMoreMoves {
+		raw, state := probeTable(b, false, Draw)
+		if state == probeFail {
+			return Draw, probeFail
+		}
+		value = WDL(raw)
+	}
+	if best >= value {
+		if best > Draw || noMoreMoves {
+			return best, probeZeroing
+		}
+		return best, probeOK
+	}
+	return value, probeOK
+}
+
+func probeDTZ(b *chess.Board) (int, probeState) {
+	wdl, state := search(b, true)
+	if state == probeFail || wdl == Draw {
+		return 0, state
+	}
+	if state == probeZeroing {
+		return dtzBeforeZeroing(wdl), probeOK
+	}
+	dtz, state := probeTable(b, true, wdl)
+	if state == probeFail {
+		return 0, probeFail
+	}
+	if state != probeChangeSTM {
+		if wdl == BlessedLoss || wdl == CursedWin {
+			dtz += 100
+		}
+		return dtz * sign(int(wdl)), probeOK
+	}
+
+	// the table holds the other side to move: find the best reply
+	minDTZ := 0xFFFF
+	for _, m := range b.LegalMoves() {
+		zeroing := b.IsCapture(m) || m.Piece == chess.Pawns
+		next := *b
+		next.MakeMove(m)
+		if zeroing {
+			v, state := search(&next, false)
+			if state == probeFail {
+				return 0, probeFail
+			}
+			dtz = -dtzBeforeZeroing(v)
+		} else {
+			v, state := probeDTZ(&next)
+			if state == probeFail {
+				return 0, probeFail
+			}
+			dtz = -v
+		}
+		if dtz == 1 && next.InCheck() && !next.HasLegalMoves() {
+			minDTZ = 1
+		}
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < minDTZ && sign(dtz) == sign(int(wdl)) {
+			minDTZ = dtz
+		}
+	}
+	if minDTZ == 0xFFFF {
+		return -1, probeOK
+	}
+	return minDTZ, probeOK
+}
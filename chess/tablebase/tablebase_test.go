@@ -0,0 +1,205 @@
+package tablebase
+
+import (
+	chess "chess/board"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"testing"
+)
+
+// the endings solved for the tests, by code
+var solutions = map[string]*solution{}
+
+// the four piece tables holding hashed values
+var hashed = []string{"KRvKN", "KNNvK", "KPvKP", "KPPvK"}
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "syzygy")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := func() int {
+		defer os.RemoveAll(dir)
+		if err := generate(dir); err != nil {
+			fmt.Fprintln(os.Stderr, "generating tables:", err)
+			return 1
+		}
+		return m.Run()
+	}()
+	os.Exit(code)
+}
+
+func generate(dir string) error {
+	solutions["KQvK"] = solve(chess.Queens, nil)
+	solutions["KRvK"] = solve(chess.Rooks, nil)
+	solutions["KPvK"] = solve(chess.Pawns, map[chess.Piece]*solution{
+		chess.Queens: solutions["KQvK"],
+		chess.Rooks:  solutions["KRvK"],
+	})
+	for code, s := range solutions {
+		if err := writeTables(dir, s, code); err != nil {
+			return err
+		}
+	}
+	for _, code := range []string{"KBvK", "KNvK"} {
+		if err := writeDrawnTable(dir, code); err != nil {
+			return err
+		}
+	}
+	for _, code := range hashed {
+		if err := writeHashedTable(dir, code); err != nil {
+			return err
+		}
+	}
+	return Init(dir)
+}
+
+func mirror(b *chess.Board) *chess.Board {
+	// b with the colours swapped and the board turned round
+	m := &chess.Board{Turn: b.Turn.Other(), RKRmoved: b.RKRmoved}
+	for c := chess.White; c <= chess.Black; c++ {
+		for p := chess.Pawns; p <= chess.Kings; p++ {
+			for bb := b.PieceBB[c][p]; bb != 0; bb &= bb - 1 {
+				m.PieceBB[c.Other()][p] |= 1 << (chess.Square(bitIndex(bb)) ^ 56)
+			}
+		}
+	}
+	m.CombineBB()
+	return m
+}
+
+func bitIndex(bb chess.Bitboard) int {
+	for i := range 64 {
+		if bb&(1<<i) != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestProbeKnownPositions(t *testing.T) {
+	for _, c := range []struct {
+		fen string
+		wdl WDL
+		dtz int
+	}{
+		{"k7/8/1K6/8/8/8/7Q/8 w - - 0 1", Win, 1},     // Qh8 mates
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", Win, 1},     // Rh8 mates
+		{"k7/8/1K6/8/8/8/8/7R b - - 0 1", Loss, -2},   // Kb8 Rh8
+		{"8/8/8/5k2/8/8/1Q6/K7 w - - 0 1", Win, 19},   // mate in 10, the longest
+		{"8/8/8/8/8/2k5/1R6/K7 w - - 0 1", Win, 31},   // mate in 16
+		{"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", Draw, 0},   // stalemate
+		{"8/8/8/8/8/8/1kQ5/7K b - - 0 1", Draw, 0},    // the queen is taken
+		{"8/8/8/8/8/8/1kR5/7K b - - 0 1", Draw, 0},    // and the rook
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", Win, 1},    // e8=Q
+		{"8/8/8/8/8/8/3kP3/6K1 b - - 0 1", Draw, 0},   // Kxe2
+		{"8/8/8/8/8/4k3/4P3/4K3 w - - 0 1", Draw, 0},  // the king in front holds
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", Win, 3},   // Kd6 Kd8 e6
+		{"8/8/8/8/4p3/4k3/8/4K3 b - - 0 1", Win, 3},   // the same for black
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", Loss, -4}, // and with black to move
+	} {
+		b, err := chess.NewBoardFromFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok := Probe(b)
+		if !ok {
+			t.Errorf("%s: not found", c.fen)
+			continue
+		}
+		if r.WDL != c.wdl || r.DTZ != c.dtz {
+			t.Errorf("%s: got %v dtz %d, expected %v dtz %d", c.fen, r.WDL, r.DTZ, c.wdl, c.dtz)
+		}
+	}
+}
+
+func TestProbeMatchesSolution(t *testing.T) {
+	// every position of the generated endings, and each with the
+	// colours swapped, probes to the value it was solved to. dtz
+	// probes search a ply deep and are only checked for a sample.
+	stride := 7
+	if testing.Short() {
+		stride = 61
+	}
+	for code, s := range solutions {
+		for idx, valid := range s.valid {
+			if !valid {
+				continue
+			}
+			b, _ := position(s.piece, idx)
+			for _, b := range []*chess.Board{b, mirror(b)} {
+				wdl, ok := ProbeWDL(b)
+				if !ok || wdl != s.wdl[idx] {
+					t.Fatalf("%s: %s probed %v, solved %v", code, b.ToFEN(), wdl, s.wdl[idx])
+				}
+				if idx%stride != 0 {
+					continue
+				}
+				dtz, ok := ProbeDTZ(b)
+				if !ok || dtz != s.dtz[idx] {
+					t.Fatalf("%s: %s probed dtz %d, solved %d", code, b.ToFEN(), dtz, s.dtz[idx])
+				}
+			}
+		}
+	}
+}
+
+func TestProbeRoot(t *testing.T) {
+	b, err := chess.NewBoardFromFEN("k7/8/1K6/8/8/8/7Q/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves, r, ok := ProbeRoot(b)
+	if !ok || r.WDL != Win || len(moves) != 1 || moves[0].String() != "h2h8" {
+		t.Errorf("got %v %v %v, expected only the mate h2h8", moves, r, ok)
+	}
+}
+
+func randomPlacement(r *rand.Rand, code string) *chess.Board {
+	// the pieces of code on random squares, either way round, with the
+	// kings apart
+	sides := strings.Split(code, "v")
+	if r.IntN(2) == 0 {
+		sides[0], sides[1] = sides[1], sides[0]
+	}
+	b := &chess.Board{Turn: chess.Color(r.IntN(2)), RKRmoved: [2][3]bool{{true, true, true}, {true, true, true}}}
+	var taken chess.Bitboard
+	for c, side := range sides {
+		for _, letter := range side {
+			p := chess.Piece(strings.IndexRune("PNBRQK", letter) + 1)
+			sq := r.IntN(64)
+			for taken&(1<<sq) != 0 || p == chess.Pawns && (sq < 8 || sq >= 56) ||
+				p == chess.Kings && chess.AllKingMoves[sq]&b.PieceBB[c^1][chess.Kings] != 0 {
+				sq = r.IntN(64)
+			}
+			taken |= 1 << sq
+			b.PieceBB[c][p] |= 1 << sq
+		}
+	}
+	b.CombineBB()
+	return b
+}
+
+func TestProbeHashedTables(t *testing.T) {
+	// positions of the four piece tables read the value hashed for
+	// them, or for them with the colours swapped when the table is
+	// stored the other way round
+	r := rand.New(rand.NewPCG(3, 4))
+	for _, code := range hashed {
+		tb := tables[code]
+		for range 20000 {
+			b := randomPlacement(r, code)
+			stored := b
+			if materialKey(b, chess.White) != tb.key || tb.key == tb.key2 && b.Turn == chess.Black {
+				stored = mirror(b)
+			}
+			raw, state := probeTable(b, false, Draw)
+			if want := hashedValue(stored, symmetries(tb)); state != probeOK || raw+2 != want {
+				t.Fatalf("%s: %s read %d, expected %d", code, b.ToFEN(), raw+2, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package training
+
+import (
+	chess "chess/board"
+	"chess/pgn"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// which games of a database are learned from, and which of their
+// positions. zero values leave a bound off; games missing the tag a set
+// bound needs are skipped.
+type Filter struct {
+	MinElo, MaxElo   int           // both players' ratings
+	MinTime, MaxTime time.Duration // base time plus 40 increments
+	MinPly, MaxPly   int           // positions before the move of this ply
+}
+
+func (f Filter) Accepts(g *pgn.Game) bool {
+	if g.Result != chess.WhiteWon && g.Result != chess.BlackWon && g.Result != chess.Drawn {
+		return false
+	}
+	if f.MinElo > 0 || f.MaxElo > 0 {
+		for _, tag := range []string{"WhiteElo", "BlackElo"} {
+			elo, err := strconv.Atoi(g.Tag(tag))
+			if err != nil || elo < f.MinElo || (f.MaxElo > 0 && elo > f.MaxElo) {
+				return false
+			}
+		}
+	}
+	if f.MinTime > 0 || f.MaxTime > 0 {
+		t, ok := gameTime(g.Tag("TimeControl"))
+		if !ok || t < f.MinTime || (f.MaxTime > 0 && t > f.MaxTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func gameTime(tc string) (time.Duration, bool) {
+	// the time a game of tc, seconds[+increment], lasts for each side if
+	// it goes 40 moves, as lichess sorts games into blitz and rapid
+	base, inc, _ := strings.Cut(tc, "+")
+	b, err := strconv.ParseFloat(base, 64)
+	if err != nil {
+		return 0, false
+	}
+	i := 0.0
+	if inc != "" {
+		if i, err = strconv.ParseFloat(inc, 64); err != nil {
+			return 0, false
+		}
+	}
+	return time.Duration((b + 40*i) * float64(time.Second)), true
+}
+
+// Extractor turns the games of PGN databases into samples of the moves
+// played, each game going whole to either the training or the
+// validation set
+type Extractor struct {
+	Filter     Filter
+	Validation float64 // share of the games for validation
+	Dedup      bool    // keep only the first sample of each position
+	DedupSize  int     // positions Dedup remembers, DefaultDedupSize if 0
+
+	train, validation *Writer
+	seen              []uint64
+	rng               *rand.Rand
+
+	Games      int // used
+	Skipped    int // filtered out
+	Bad        int // unreadable
+	Duplicates int // positions seen before
+}
+
+// the positions are remembered in a table of hashes indexed by the hash
+// itself, a new position taking the place of the one there before. its
+// memory is 8 bytes a position whatever the size of the databases, at
+// the cost of letting a duplicate through once its slot was taken over:
+// rarely while the positions fit, more often past that.
+const DefaultDedupSize = 1 << 22
+
+func NewExtractor(train, validation *Writer, seed uint64) *Extractor {
+	return &Extractor{
+		train:      train,
+		validation: validation,
+		rng:        rand.New(rand.NewPCG(seed, 0)),
+	}
+}
+
+func (e *Extractor) AddPGN(r io.Reader) (int, error) {
+	// adds every game of a PGN file, returning how many were read.
+	// games that can't be read are counted in Bad and left out.
+	games := 0
+	pr := pgn.NewReader(r)
+	for {
+		g, err := pr.Next()
+		if err == io.EOF {
+			return games, nil
+		} else if _, ok := err.(*pgn.GameError); ok {
+			e.Bad++
+			continue
+		} else if err != nil {
+			return games, err
+		}
+		if err := e.AddGame(g); err != nil {
+			return games, fmt.Errorf("game %d: %v", games+1, err)
+		}
+		games++
+	}
+}
+
+func (e *Extractor) AddGame(g *pgn.Game) error {
+	// a sample of each position in the ply range: the move played there
+	// as the policy and the game's result for the side to move
+	if !e.Filter.Accepts(g) {
+		e.Skipped++
+		return nil
+	}
+	b, err := g.StartBoard()
+	if err != nil {
+		return err
+	}
+	w := e.train
+	if e.rng.Float64() < e.Validation {
+		w = e.validation
+	}
+	e.Games++
+	for ply, m := range g.Moves {
+		if e.Filter.MaxPly > 0 && ply > e.Filter.MaxPly {
+			break
+		}
+		if ply >= e.Filter.MinPly && !e.duplicate(b) {
+			if err := w.Add(NewSample(b, moveTarget(m.Move, b.Turn), resultFor(g.Result, b.Turn))); err != nil {
+				return err
+			}
+		}
+		b.MakeMove(m.Move)
+	}
+	return nil
+}
+
+func (e *Extractor) duplicate(b *chess.Board) bool {
+	if !e.Dedup {
+		return false
+	}
+	if e.seen == nil {
+		size := e.DedupSize
+		if size <= 0 {
+			size = DefaultDedupSize
+		}
+		// a power of 2, so the low bits of the hash index it
+		e.seen = make([]uint64, 1<<bits.Len(uint(size-1)))
+	}
+	// the low bit is set so no hash is taken for an empty slot
+	hash := b.Hash() | 1
+	slot := &e.seen[hash&uint64(len(e.seen)-1)]
+	if *slot == hash {
+		e.Duplicates++
+		return true
+	}
+	*slot = hash
+	return false
+}
+
+func moveTarget(mv chess.Move, turn chess.Color) []float32 {
+	// a policy with all its weight on mv
+	policy := make([]float32, chess.PolicySize)
+	if i, ok := chess.PolicyIndex(mv, turn == chess.Black); ok {
+		policy[i] = 1
+	}
+	return policy
+}
+
+func resultFor(result string, turn chess.Color) float32 {
+	switch {
+	case result == chess.Drawn:
+		return 0
+	case (result == chess.WhiteWon) == (turn == chess.White):
+		return 1
+	}
+	return -1
+}
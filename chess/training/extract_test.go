@@ -0,0 +1,187 @@
+package training
+
+import (
+	chess "chess/board"
+	"chess/pgn"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGameTime(t *testing.T) {
+	for _, c := range []struct {
+		tc   string
+		time time.Duration
+		ok   bool
+	}{
+		{"180+2", 260 * time.Second, true},
+		{"600", 600 * time.Second, true},
+		{"60+0", time.Minute, true},
+		{"15+0.5", 35 * time.Second, true},
+		{"-", 0, false},
+		{"?", 0, false},
+		{"", 0, false},
+		{"1/259200", 0, false},
+		{"300+x", 0, false},
+	} {
+		if got, ok := gameTime(c.tc); got != c.time || ok != c.ok {
+			t.Errorf("%q: %v %v, expected %v %v", c.tc, got, ok, c.time, c.ok)
+		}
+	}
+}
+
+func TestFilterAccepts(t *testing.T) {
+	game := func(result, whiteElo, blackElo, tc string) *pgn.Game {
+		g := &pgn.Game{Result: result}
+		for name, value := range map[string]string{"WhiteElo": whiteElo, "BlackElo": blackElo, "TimeControl": tc} {
+			if value != "" {
+				g.SetTag(name, value)
+			}
+		}
+		return g
+	}
+	rapid := Filter{MinElo: 1800, MaxElo: 2400, MinTime: 8 * time.Minute, MaxTime: 25 * time.Minute}
+	for _, c := range []struct {
+		name   string
+		filter Filter
+		game   *pgn.Game
+		accept bool
+	}{
+		{"no bounds", Filter{}, game(chess.Drawn, "", "", ""), true},
+		{"unfinished", Filter{}, game(chess.Unfinished, "", "", ""), false},
+		{"no result", Filter{}, game("", "", "", ""), false},
+		{"in bounds", rapid, game(chess.WhiteWon, "2000", "2100", "600+5"), true},
+		{"bounds included", rapid, game(chess.BlackWon, "1800", "2400", "480"), true},
+		{"one player too weak", rapid, game(chess.WhiteWon, "1799", "2100", "600+5"), false},
+		{"one player too strong", rapid, game(chess.WhiteWon, "2000", "2401", "600+5"), false},
+		{"rating missing", rapid, game(chess.WhiteWon, "2000", "", "600+5"), false},
+		{"rating unknown", rapid, game(chess.WhiteWon, "2000", "?", "600+5"), false},
+		{"blitz", rapid, game(chess.WhiteWon, "2000", "2100", "180+2"), false},
+		{"classical", rapid, game(chess.WhiteWon, "2000", "2100", "1800+20"), false},
+		{"time control missing", rapid, game(chess.WhiteWon, "2000", "2100", ""), false},
+		{"only a lower rating bound", Filter{MinElo: 1500}, game(chess.Drawn, "2900", "1500", ""), true},
+		{"only an upper time bound", Filter{MaxTime: 5 * time.Minute}, game(chess.Drawn, "", "", "60+1"), true},
+	} {
+		if got := c.filter.Accepts(c.game); got != c.accept {
+			t.Errorf("%s: accepted %v, expected %v", c.name, got, c.accept)
+		}
+	}
+}
+
+func newTestExtractor(t *testing.T, seed uint64) (*Extractor, *Writer, *Writer) {
+	t.Helper()
+	dir := t.TempDir()
+	train, err := NewWriter(filepath.Join(dir, "train"), "train", 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := NewWriter(filepath.Join(dir, "val"), "val", 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewExtractor(train, val, seed), train, val
+}
+
+func closeWriters(t *testing.T, writers ...*Writer) {
+	t.Helper()
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// a game of 10 plies
+const tenPlies = "1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 1-0\n\n"
+
+func TestPlyBounds(t *testing.T) {
+	// the positions sampled are those before the moves of plies MinPly
+	// to MaxPly, counted from 0
+	for _, c := range []struct {
+		minPly, maxPly, samples int
+	}{
+		{0, 0, 10},
+		{0, 3, 4},
+		{2, 5, 4},
+		{8, 0, 2},
+		{9, 9, 1},
+		{10, 0, 0},
+		{4, 20, 6},
+	} {
+		e, train, val := newTestExtractor(t, 1)
+		e.Filter = Filter{MinPly: c.minPly, MaxPly: c.maxPly}
+		if _, err := e.AddPGN(strings.NewReader(tenPlies)); err != nil {
+			t.Fatal(err)
+		}
+		closeWriters(t, train, val)
+		if train.Samples != c.samples || val.Samples != 0 {
+			t.Errorf("plies %d-%d: %d samples, expected %d", c.minPly, c.maxPly, train.Samples+val.Samples, c.samples)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	// the same game twice gives its positions once, remembered in a
+	// table of the size asked for rounded up to a power of 2
+	for _, c := range []struct{ size, slots int }{{0, DefaultDedupSize}, {64, 64}, {100, 128}} {
+		e, train, val := newTestExtractor(t, 1)
+		e.Dedup = true
+		e.DedupSize = c.size
+		if _, err := e.AddPGN(strings.NewReader(tenPlies + tenPlies)); err != nil {
+			t.Fatal(err)
+		}
+		closeWriters(t, train, val)
+		if train.Samples != 10 || e.Duplicates != 10 {
+			t.Errorf("size %d: %d samples and %d duplicates, expected 10 and 10", c.size, train.Samples, e.Duplicates)
+		}
+		if len(e.seen) != c.slots {
+			t.Errorf("size %d: %d slots, expected %d", c.size, len(e.seen), c.slots)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	// games go whole to one set or the other, about Validation of them
+	// to validation, the same way for the same seed
+	var text strings.Builder
+	for range 400 {
+		text.WriteString("1. d4 d5 1/2-1/2\n\n")
+	}
+	counts := map[uint64][2]int{}
+	for _, seed := range []uint64{1, 1, 2} {
+		e, train, val := newTestExtractor(t, seed)
+		e.Validation = 0.25
+		games, err := e.AddPGN(strings.NewReader(text.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		closeWriters(t, train, val)
+		if games != 400 || train.Samples+val.Samples != 800 {
+			t.Fatalf("%d games and %d samples, expected 400 and 800", games, train.Samples+val.Samples)
+		}
+		if train.Samples%2 != 0 || val.Samples%2 != 0 {
+			t.Errorf("seed %d: a game split across the sets", seed)
+		}
+		// 100 expected, with a standard deviation of about 9
+		if n := val.Samples / 2; n < 70 || n > 130 {
+			t.Errorf("seed %d: %d of 400 games for validation", seed, n)
+		}
+		if before, ok := counts[seed]; ok && before != [2]int{train.Samples, val.Samples} {
+			t.Errorf("seed %d: split %v, then %d and %d", seed, before, train.Samples, val.Samples)
+		}
+		counts[seed] = [2]int{train.Samples, val.Samples}
+	}
+}
+
+func TestAddPGNSkipsBadGames(t *testing.T) {
+	e, train, val := newTestExtractor(t, 1)
+	games, err := e.AddPGN(strings.NewReader(tenPlies + "[Event \"bad\"]\n\n1. e4 e5 2. Ke3 1-0\n\n[Event \"good\"]\n\n" + tenPlies))
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeWriters(t, train, val)
+	if games != 2 || e.Bad != 1 || e.Games != 2 || train.Samples != 20 {
+		t.Errorf("%d games read, %d used, %d bad and %d samples, expected 2, 2, 1 and 20", games, e.Games, e.Bad, train.Samples)
+	}
+}
@@ -0,0 +1,150 @@
+package training
+
+import (
+	"archive/zip"
+	chess "chess/board"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// training data is written in shards, each a compressed NumPy archive
+// as np.savez_compressed makes, read with np.load:
+//
+//	states    float32 (n, 8, 8, 19)  the position seen from the side to
+//	                                 move, Board.Encode with Relative
+//	policies  float32 (n, 1968)      the target for the policy head,
+//	                                 numbered like chess.PolicyIndex
+//	values    float32 (n,)           the result of the game for the side
+//	                                 to move, 1 win, 0 draw, -1 loss
+//
+// the arrays line up sample by sample.
+
+// a position and what the network should learn from it
+type Sample struct {
+	State  []float32 // 8*8*19, planes last
+	Policy []float32 // chess.PolicySize
+	Value  float32
+}
+
+func NewSample(b *chess.Board, policy []float32, value float32) Sample {
+	// a sample of b, encoded from the side to move
+	return Sample{chess.ChannelsLast(b.Encode(chess.TensorOptions{Relative: true}, nil)), policy, value}
+}
+
+func WriteShard(path string, samples []Sample) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	z := zip.NewWriter(f)
+	n := len(samples)
+	states := make([]float32, 0, n*8*8*chess.PositionPlanes)
+	policies := make([]float32, 0, n*chess.PolicySize)
+	values := make([]float32, 0, n)
+	for _, s := range samples {
+		if len(s.State) != 8*8*chess.PositionPlanes || len(s.Policy) != chess.PolicySize {
+			f.Close()
+			return fmt.Errorf("sample of %d states and %d policies", len(s.State), len(s.Policy))
+		}
+		states = append(states, s.State...)
+		policies = append(policies, s.Policy...)
+		values = append(values, s.Value)
+	}
+	arrays := []struct {
+		name  string
+		shape []int
+		data  []float32
+	}{
+		{"states", []int{n, 8, 8, chess.PositionPlanes}, states},
+		{"policies", []int{n, chess.PolicySize}, policies},
+		{"values", []int{n}, values},
+	}
+	for _, a := range arrays {
+		w, err := z.CreateHeader(&zip.FileHeader{Name: a.name + ".npy", Method: zip.Deflate})
+		if err == nil {
+			err = writeNPY(w, a.shape, a.data)
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := z.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeNPY(w io.Writer, shape []int, data []float32) error {
+	// a float32 array in version 1.0 of the .npy format
+	dims := make([]string, len(shape))
+	for i, d := range shape {
+		dims[i] = fmt.Sprint(d)
+	}
+	tuple := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		tuple += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }", tuple)
+	// magic, version and length come to 10 bytes, the data starts at a
+	// multiple of 64 after the padding and a newline
+	header += strings.Repeat(" ", 63-(10+len(header))%64) + "\n"
+	prefix := append([]byte("\x93NUMPY\x01\x00"), byte(len(header)), byte(len(header)>>8))
+	if _, err := w.Write(append(prefix, header...)); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, data)
+}
+
+// Writer gathers samples into shards of a fixed size, named after a
+// prefix and numbered from 1
+type Writer struct {
+	dir, prefix string
+	size        int
+	pending     []Sample
+	shards      int
+	Samples     int // written so far
+}
+
+func NewWriter(dir, prefix string, size int) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Writer{dir: dir, prefix: prefix, size: max(size, 1)}, nil
+}
+
+func (w *Writer) Add(samples ...Sample) error {
+	for _, s := range samples {
+		w.pending = append(w.pending, s)
+		if len(w.pending) == w.size {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Writer) flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	w.shards++
+	path := filepath.Join(w.dir, fmt.Sprintf("%s-%04d.npz", w.prefix, w.shards))
+	if err := WriteShard(path, w.pending); err != nil {
+		return err
+	}
+	w.Samples += len(w.pending)
+	w.pending = w.pending[:0]
+	return nil
+}
+
+func (w *Writer) Close() error {
+	// writes the last, smaller shard
+	return w.flush()
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/engine"
+	"chess/tablebase"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// how long hints and threats are searched for
+const helperTime = time.Second
+
+// an iteration of the analysis of the position shown
+type analysisMsg struct {
+	id   int
+	info engine.SearchInfo
+	ok   bool // false once the search has ended
+}
+
+// the move the engine suggests in a position, or the one the opponent
+// would play there if it were their move
+type helperMsg struct {
+	of     *node
+	move   chess.Move
+	threat bool
+}
+
+func waitAnalysis(infos <-chan engine.SearchInfo, id int) tea.Cmd {
+	return func() tea.Msg {
+		info, ok := <-infos
+		return analysisMsg{id, info, ok}
+	}
+}
+
+func (m *model) followAnalysis() tea.Cmd {
+	// keeps the analysis on the position shown, starting it over
+	// whenever that changes
+	if !m.analyzing || m.analysisOf == m.view || m.wizard != nil {
+		return nil
+	}
+	if m.analyst == nil {
+		m.analyst = engine.New()
+	}
+	m.analysisID++
+	m.analysisOf = m.view
+	m.analysis = engine.SearchInfo{}
+	m.probe, m.probed = tablebase.Probe(m.view.board)
+	m.infos = m.analyst.Analyze(m.view.board, m.view.history(), engine.Limits{Infinite: true})
+	return waitAnalysis(m.infos, m.analysisID)
+}
+
+func (m *model) toggleAnalysis() tea.Cmd {
+	m.analyzing = !m.analyzing
+	if !m.analyzing {
+		m.analyst.Stop()
+		m.analysisOf = nil
+		return nil
+	}
+	return m.followAnalysis()
+}
+
+func (m *model) askHelper(threat bool) tea.Cmd {
+	// searches for a hint in the position shown, or with threat for the
+	// opponent's best move were it their turn. a hint is taken from the
+	// analysis when it is running.
+	n := m.view
+	if n.outcome.Over() {
+		return nil
+	}
+	if threat && n.board.InCheck() {
+		m.status = "no threats while in check"
+		return nil
+	}
+	if !threat && m.analysisOf == n && len(m.analysis.PV) > 0 {
+		m.helped(helperMsg{n, m.analysis.PV[0], false})
+		return nil
+	}
+	if m.helper == nil {
+		m.helper = engine.New()
+	}
+	e := m.helper
+	board := *n.board
+	history := n.history()
+	if threat {
+		board.MakeNullMove()
+		history = append(history, n.board.Hash())
+	}
+	m.status = "thinking..."
+	return func() tea.Msg {
+		move, _ := e.BestMove(&board, history, engine.Limits{MoveTime: helperTime})
+		return helperMsg{n, move, threat}
+	}
+}
+
+func (m *model) helped(msg helperMsg) {
+	m.status = ""
+	if msg.threat {
+		m.threat, m.threatOf = msg.move, msg.of
+		if msg.of == m.view && msg.move != chess.NullMove {
+			passed := *msg.of.board
+			passed.MakeNullMove()
+			m.status = "threat: " + passed.SAN(msg.move)
+		}
+		return
+	}
+	m.hint, m.hintOf = msg.move, msg.of
+	if msg.of == m.view && msg.move != chess.NullMove {
+		m.status = "hint: " + msg.of.board.SAN(msg.move)
+	}
+}
+
+func (m model) threatened() chess.Bitboard {
+	// the squares the opponent attacks while a threat is shown, empty
+	// or not, worked out once for each drawing of the board
+	if m.threatOf != m.view {
+		return 0
+	}
+	return m.view.board.AttackMap(m.view.board.Turn.Other())
+}
+
+func (m model) highlight(sq chess.Square, threatened chess.Bitboard) (lipgloss.Color, bool) {
+	// the colour marking sq when a hint or a threat is shown. with a
+	// threat every square the opponent attacks is tinted too.
+	t := findTheme(m.config.Theme)
+	if m.hintOf == m.view && m.hint != chess.NullMove && (sq == m.hint.From || sq == m.hint.To) {
+		return t.hint, true
+	}
+	if m.threatOf != m.view {
+		return "", false
+	}
+	if m.threat != chess.NullMove && (sq == m.threat.From || sq == m.threat.To) {
+		return t.threat, true
+	}
+	if threatened&(chess.Bitboard(1)<<sq) != 0 {
+		return t.attacked, true
+	}
+	return "", false
+}
+
+func whiteScore(info engine.SearchInfo, turn chess.Color) (score, mate int) {
+	// the score of info from white's side
+	if turn == chess.Black {
+		return -info.Score, -info.Mate
+	}
+	return info.Score, info.Mate
+}
+
+func formatScore(score, mate int) string {
+	if mate > 0 {
+		return fmt.Sprintf("#%d", mate)
+	} else if mate < 0 {
+		return fmt.Sprintf("#-%d", -mate)
+	}
+	return fmt.Sprintf("%+.2f", float64(score)/100)
+}
+
+func evalBar(score, mate, width int) string {
+	// a bar filled with white's share of the expected score
+	share := 1 / (1 + math.Pow(10, -float64(score)/400))
+	switch {
+	case mate > 0:
+		share = 1
+	case mate < 0:
+		share = 0
+	}
+	white := int(math.Round(share * float64(width)))
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Render(strings.Repeat("█", white)) +
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#3C3C3C")).Render(strings.Repeat("░", width-white))
+}
+
+func lineSAN(b *chess.Board, pv []chess.Move) string {
+	// the moves of pv in SAN, numbered from b
+	var sb strings.Builder
+	board := *b
+	for i, mv := range pv {
+		if board.Turn == chess.White {
+			fmt.Fprintf(&sb, "%d. ", board.MoveCounter/2+1)
+		} else if i == 0 {
+			fmt.Fprintf(&sb, "%d... ", board.MoveCounter/2+1)
+		}
+		sb.WriteString(board.SAN(mv) + " ")
+		board.MakeMove(mv)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// columns of the analysis pane's contents
+const analysisWidth = 30
+
+func (m model) tablebaseText() string {
+	// the tablebases' verdict on the position analysed, from the winner's
+	// side, or empty when no table covers it
+	if m.analysisOf == nil || !m.probed {
+		return ""
+	}
+	if m.probe.WDL == tablebase.Draw {
+		return "tablebase: draw"
+	}
+	wdl, winner, dtz := m.probe.WDL, m.analysisOf.board.Turn, m.probe.DTZ
+	if wdl < 0 {
+		wdl, winner, dtz = -wdl, winner.Other(), -dtz
+	}
+	text := fmt.Sprintf("tablebase: %v for %s", wdl, colorName(winner))
+	if dtz != 0 {
+		text += fmt.Sprintf(", dtz %d", dtz)
+	}
+	return text
+}
+
+func (m model) renderAnalysis(lines int) string {
+	// the evaluation, a bar showing it and the best line found, under
+	// the tablebases' verdict when they cover the position
+	info := m.analysis
+	title := titleStyle.Render("Analysis")
+	if tb := m.tablebaseText(); tb != "" {
+		title += "\n" + labelStyle.Width(analysisWidth).Render(tb)
+		lines--
+	}
+	if m.analysisOf == nil || info.Depth == 0 {
+		return title + "\n" + labelStyle.Render("thinking...")
+	}
+	score, mate := whiteScore(info, m.analysisOf.board.Turn)
+	head := fmt.Sprintf("%s %s", titleStyle.Render(formatScore(score, mate)), labelStyle.Render(fmt.Sprintf("depth %d", info.Depth)))
+	line := lipgloss.NewStyle().Width(analysisWidth).MaxHeight(max(lines-3, 1)).Render(lineSAN(m.analysisOf.board, info.PV))
+	return title + "\n" + head + "\n" + evalBar(score, mate, analysisWidth) + "\n" + line
+}
+
+func (m model) renderAnalysisLine() string {
+	// the analysis on one line, for the compact layout
+	info := m.analysis
+	if m.analysisOf == nil || info.Depth == 0 {
+		return labelStyle.Render("analysis: thinking...")
+	}
+	score, mate := whiteScore(info, m.analysisOf.board.Turn)
+	text := fmt.Sprintf("%s d%d %s", formatScore(score, mate), info.Depth, lineSAN(m.analysisOf.board, info.PV))
+	if tb := m.tablebaseText(); tb != "" {
+		text = tb + " · " + text
+	}
+	return lipgloss.NewStyle().MaxWidth(8*m.squareWidth() + m.boardLeft()).Render(text)
+}
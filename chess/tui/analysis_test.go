@@ -0,0 +1,39 @@
+package main
+
+import (
+	chess "chess/board"
+	"testing"
+)
+
+func TestThreatened(t *testing.T) {
+	// the opponent's attacks take in the pieces it defends and leave
+	// out pawn pushes, which attack nothing
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := newModel()
+	b, err := chess.NewBoardFromFEN("r3k3/4p3/8/8/8/8/8/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.view = newRoot(b, nil)
+	if m.threatened() != 0 {
+		t.Error("squares tinted with no threat shown")
+	}
+	m.threatOf = m.view
+	threatened := m.threatened()
+	for _, c := range []struct {
+		square   string
+		attacked bool
+	}{
+		{"a1", true},  // down the a file
+		{"d8", true},  // by the king and the rook
+		{"e7", true},  // the pawn, defended by the king
+		{"d6", true},  // the pawn's capture
+		{"e6", false}, // its push
+		{"e5", false},
+		{"h8", false},
+	} {
+		if got := threatened&(chess.Bitboard(1)<<chess.NotationToIndex[c.square]) != 0; got != c.attacked {
+			t.Errorf("%s tinted %v, expected %v", c.square, got, c.attacked)
+		}
+	}
+}
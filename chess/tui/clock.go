@@ -0,0 +1,201 @@
+package main
+
+import (
+	chess "chess/board"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how time is added back for each move
+const (
+	fischer   = '+' // the increment is added after every move
+	delay     = 'd' // the clock only starts once the delay has passed
+	bronstein = 'b' // the time used is given back, up to the delay
+)
+
+// one period of a time control. the last stage lasts the rest of the game.
+type stage struct {
+	moves int           // moves to make in this stage, 0 for the rest of the game
+	base  time.Duration // added to the clock when the stage begins
+	bonus time.Duration // increment or delay
+	kind  byte          // fischer, delay or bronstein
+}
+
+type timeControl struct {
+	Name   string
+	Stages []stage // none for no clock
+}
+
+func parseTimeControl(s string) (timeControl, error) {
+	// reads stages separated by commas, each [moves/]minutes followed by
+	// +seconds for an increment, dseconds for a simple delay or bseconds
+	// for a Bronstein delay. eg "40/90+30, 30+30" or "5d3".
+	tc := timeControl{Name: strings.TrimSpace(s)}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		var st stage
+		if moves, rest, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(moves)
+			if err != nil || n <= 0 {
+				return tc, fmt.Errorf("bad move count in %q", part)
+			}
+			st.moves, part = n, rest
+		}
+		base := part
+		if i := strings.IndexAny(part, "+db"); i >= 0 {
+			base = part[:i]
+			st.kind = part[i]
+			seconds, err := strconv.ParseFloat(part[i+1:], 64)
+			if err != nil || seconds < 0 {
+				return tc, fmt.Errorf("bad increment in %q", part)
+			}
+			st.bonus = time.Duration(seconds * float64(time.Second))
+		}
+		minutes, err := strconv.ParseFloat(base, 64)
+		if err != nil || minutes <= 0 {
+			return tc, fmt.Errorf("bad minutes in %q", part)
+		}
+		st.base = time.Duration(minutes * float64(time.Minute))
+		tc.Stages = append(tc.Stages, st)
+	}
+	for _, st := range tc.Stages[:len(tc.Stages)-1] {
+		if st.moves == 0 {
+			return tc, fmt.Errorf("only the last stage may last the rest of the game")
+		}
+	}
+	return tc, nil
+}
+
+func mustTimeControl(name, spec string) timeControl {
+	tc, err := parseTimeControl(spec)
+	if err != nil {
+		panic(err)
+	}
+	tc.Name = name
+	return tc
+}
+
+func (tc timeControl) pgnTag() string {
+	// the control in the PGN TimeControl format, eg 40/5400+30:1800+30.
+	// delays have no notation there and are left out.
+	if len(tc.Stages) == 0 {
+		return "-"
+	}
+	var parts []string
+	for _, st := range tc.Stages {
+		part := strconv.Itoa(int(st.base.Seconds()))
+		if st.moves > 0 {
+			part = strconv.Itoa(st.moves) + "/" + part
+		}
+		if st.kind == fischer && st.bonus > 0 {
+			part += "+" + strconv.FormatFloat(st.bonus.Seconds(), 'f', -1, 64)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ":")
+}
+
+type clock struct {
+	control   timeControl
+	remaining [2]time.Duration
+	moves     [2]int // moves made by each side
+	stage     [2]int
+	turn      chess.Color
+	since     time.Time // when the side to move started thinking
+	stopped   bool
+}
+
+func newClock(tc timeControl, turn chess.Color, now time.Time) *clock {
+	// starts the clock of the side to move. nil when there's no clock.
+	if len(tc.Stages) == 0 {
+		return nil
+	}
+	c := &clock{control: tc, turn: turn, since: now}
+	c.remaining = [2]time.Duration{tc.Stages[0].base, tc.Stages[0].base}
+	return c
+}
+
+func (c *clock) current(color chess.Color) stage {
+	return c.control.Stages[c.stage[color]]
+}
+
+func (c *clock) used(now time.Time) time.Duration {
+	// time taken off the clock of the side to move so far
+	spent := now.Sub(c.since)
+	if st := c.current(c.turn); st.kind == delay {
+		spent = max(spent-st.bonus, 0)
+	}
+	return spent
+}
+
+func (c *clock) left(color chess.Color, now time.Time) time.Duration {
+	// the time on color's clock
+	if color != c.turn || c.stopped {
+		return c.remaining[color]
+	}
+	return c.remaining[color] - c.used(now)
+}
+
+func (c *clock) flagged(now time.Time) bool {
+	return !c.stopped && c.left(c.turn, now) <= 0
+}
+
+func (c *clock) press(now time.Time) {
+	// the side to move has moved: its time is settled, the next stage
+	// begins if the control was reached, and the other clock starts
+	color := c.turn
+	st := c.current(color)
+	spent := now.Sub(c.since)
+	c.remaining[color] -= c.used(now)
+	switch st.kind {
+	case fischer:
+		c.remaining[color] += st.bonus
+	case bronstein:
+		c.remaining[color] += min(spent, st.bonus)
+	}
+	c.moves[color]++
+	if c.stage[color]+1 < len(c.control.Stages) && c.moves[color] == c.stageEnd(color) {
+		c.stage[color]++
+		c.remaining[color] += c.current(color).base
+	}
+	c.turn = color.Other()
+	c.since = now
+}
+
+func (c *clock) stageEnd(color chess.Color) int {
+	// the move count at which color's current stage ends
+	end := 0
+	for _, st := range c.control.Stages[:c.stage[color]+1] {
+		end += st.moves
+	}
+	return end
+}
+
+func (c *clock) movesToGo(color chess.Color) int {
+	// moves left until the next control, 0 when the stage lasts the game
+	if c.current(color).moves == 0 {
+		return 0
+	}
+	return c.stageEnd(color) - c.moves[color]
+}
+
+func formatClock(d time.Duration) string {
+	// minutes and seconds, with tenths in the last ten seconds
+	d = max(d, 0)
+	if d < 10*time.Second {
+		return fmt.Sprintf("0:%04.1f", d.Seconds())
+	}
+	d = d.Truncate(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func clkComment(d time.Duration) string {
+	// the time left after a move as a PGN %clk command
+	d = max(d, 0)
+	return fmt.Sprintf("[%%clk %d:%02d:%04.1f]", int(d.Hours()), int(d.Minutes())%60, d.Seconds()-float64(int(d.Minutes())*60))
+}
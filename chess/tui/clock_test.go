@@ -0,0 +1,75 @@
+package main
+
+import (
+	chess "chess/board"
+	"testing"
+	"time"
+)
+
+func TestClockPress(t *testing.T) {
+	// white thinks 10s a move, black 2s, for three moves each
+	for _, c := range []struct {
+		spec         string
+		white, black time.Duration
+	}{
+		{"5", 5*time.Minute - 30*time.Second, 5*time.Minute - 6*time.Second},
+		{"5+2", 5*time.Minute - 24*time.Second, 5 * time.Minute},
+		{"5d3", 5*time.Minute - 21*time.Second, 5 * time.Minute},
+		{"5b3", 5*time.Minute - 21*time.Second, 5 * time.Minute},
+		// the second stage's minute comes with the third move, its
+		// increment only after the moves made in it
+		{"3/5, 1+1", 6*time.Minute - 30*time.Second, 6*time.Minute - 6*time.Second},
+	} {
+		tc, err := parseTimeControl(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Unix(0, 0)
+		clk := newClock(tc, chess.White, now)
+		for range 3 {
+			now = now.Add(10 * time.Second)
+			clk.press(now)
+			now = now.Add(2 * time.Second)
+			clk.press(now)
+		}
+		if clk.left(chess.White, now) != c.white || clk.left(chess.Black, now) != c.black {
+			t.Errorf("%s: %v and %v left, expected %v and %v", c.spec,
+				clk.left(chess.White, now), clk.left(chess.Black, now), c.white, c.black)
+		}
+	}
+}
+
+func TestClockFlagged(t *testing.T) {
+	tc, _ := parseTimeControl("1d5")
+	now := time.Unix(0, 0)
+	clk := newClock(tc, chess.White, now)
+	// the delay passes before the minute starts running
+	if clk.flagged(now.Add(64 * time.Second)) {
+		t.Error("flagged inside the delay")
+	}
+	if !clk.flagged(now.Add(65 * time.Second)) {
+		t.Error("not flagged once the minute is up")
+	}
+	clk.stopped = true
+	if clk.flagged(now.Add(time.Hour)) {
+		t.Error("flagged with the clock stopped")
+	}
+}
+
+func TestMoveAfterFlag(t *testing.T) {
+	// a move arriving once the time is up loses on time instead of
+	// being played
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := newModel()
+	m.setup.mode = humanVsHuman
+	m.setup.timeControl = "Bullet 1+0"
+	if err := m.begin(); err != nil {
+		t.Fatal(err)
+	}
+	root := m.head
+	m.clock.since = time.Now().Add(-2 * time.Minute)
+	m.play(m.head, chess.Move{From: chess.NotationToIndex["e2"], To: chess.NotationToIndex["e4"], Piece: chess.Pawns})
+	if m.head != root || m.head.outcome.Result != chess.BlackWon || m.head.outcome.Reason != "time forfeit" {
+		t.Errorf("the move was played or the game not lost: %v", m.head.outcome)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"chess/puzzle"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// settings kept between runs
+type config struct {
+	Name            string `json:"name"`   // the player's name, written to PGN headers
+	Theme           string `json:"theme"`  // the board colours, see themes
+	Pieces          string `json:"pieces"` // the piece set, see pieceSets
+	HideCoordinates bool   `json:"hide_coordinates"`
+	Compact         bool   `json:"compact"` // small squares and no move list, even when there is room
+
+	PuzzleRating *puzzle.Rating `json:"puzzle_rating,omitempty"` // nil until a puzzle is tried
+	Explorer     string         `json:"explorer,omitempty"`      // the opening explorer's store, the default one if empty
+	Syzygy       string         `json:"syzygy,omitempty"`        // directories of syzygy tablebases shown in the analysis, none if empty
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chess", "config.json"), nil
+}
+
+func loadConfig() config {
+	// reads the saved settings, falling back to the defaults when
+	// there are none or they can't be read
+	var c config
+	path, err := configPath()
+	if err != nil {
+		return c
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	json.Unmarshal(data, &c)
+	return c
+}
+
+func (c config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
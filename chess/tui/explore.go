@@ -0,0 +1,110 @@
+package main
+
+import (
+	"chess/explorer"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// columns of the bar showing how the games with a move ended
+const resultsWidth = 12
+
+// the moves the explorer found in a position, kept while it is open so
+// redrawing doesn't query the store again
+type explored struct {
+	moves []explorer.MoveStats
+	err   error
+}
+
+func (m *model) toggleExplorer() {
+	// opens the store of the opening explorer, built with cmd/index,
+	// or closes it again
+	if m.explorer != nil {
+		m.explorer.Close()
+		m.explorer, m.explored = nil, nil
+		return
+	}
+	path := m.config.Explorer
+	if path == "" {
+		var err error
+		if path, err = explorer.DefaultPath(); err != nil {
+			m.status = err.Error()
+			return
+		}
+	}
+	db, err := explorer.Open(path)
+	if err != nil {
+		m.status = fmt.Sprintf("no explorer: %v, import games with cmd/index", err)
+		return
+	}
+	m.explorer = db
+	m.explored = map[*node]explored{}
+	m.status = ""
+}
+
+func (m model) explore(n *node) ([]explorer.MoveStats, error) {
+	// the explorer's moves in n, looked up the first time n is shown
+	if e, ok := m.explored[n]; ok {
+		return e.moves, e.err
+	}
+	moves, err := m.explorer.Lookup(n.board)
+	m.explored[n] = explored{moves, err}
+	return moves, err
+}
+
+func resultsBar(s explorer.Stats) string {
+	// white's wins, the draws and black's wins side by side
+	games := float64(max(s.Games(), 1))
+	white := int(float64(s.White) / games * resultsWidth)
+	black := int(float64(s.Black) / games * resultsWidth)
+	draws := resultsWidth - white - black
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Render(strings.Repeat("█", white)) +
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Render(strings.Repeat("▒", draws)) +
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#3C3C3C")).Render(strings.Repeat("░", black))
+}
+
+func percent(n, games int) float64 {
+	return 100 * float64(n) / float64(max(games, 1))
+}
+
+func (m model) renderExplorer(lines int) string {
+	// the moves played in the position shown with how often and how
+	// those games ended
+	title := titleStyle.Render("Explorer")
+	moves, err := m.explore(m.view)
+	if err != nil {
+		return title + "\n" + messageStyle.Render(err.Error())
+	}
+	if len(moves) == 0 {
+		return title + "\n" + labelStyle.Render("no games from here")
+	}
+	total := 0
+	for _, mv := range moves {
+		total += mv.Games()
+	}
+	rows := []string{title + labelStyle.Render(fmt.Sprintf(" %d games", total))}
+	for _, mv := range moves[:max(0, min(len(moves), lines-1))] {
+		games := mv.Games()
+		rows = append(rows, fmt.Sprintf("%-7s%6d %s %3.0f/%3.0f/%3.0f", m.view.board.SAN(mv.Move), games, resultsBar(mv.Stats),
+			percent(mv.White, games), percent(mv.Draws, games), percent(mv.Black, games)))
+	}
+	return strings.Join(rows, "\n")
+}
+
+func (m model) renderExplorerLine() string {
+	// the most played moves on one line, for the compact layout
+	moves, err := m.explore(m.view)
+	if err != nil {
+		return messageStyle.Render(err.Error())
+	}
+	var parts []string
+	for _, mv := range moves[:min(len(moves), 3)] {
+		parts = append(parts, fmt.Sprintf("%s %d", m.view.board.SAN(mv.Move), mv.Games()))
+	}
+	if len(parts) == 0 {
+		return labelStyle.Render("explorer: no games")
+	}
+	return "explorer: " + strings.Join(parts, ", ")
+}
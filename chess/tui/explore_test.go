@@ -0,0 +1,41 @@
+package main
+
+import (
+	"chess/explorer"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderExplorer(t *testing.T) {
+	// the pane fits any height, and each position is looked up once
+	b := explorer.NewBuilder(10)
+	if _, err := b.AddPGN(strings.NewReader("1. e4 e5 1-0\n\n1. d4 d5 0-1\n\n1. e4 c5 1/2-1/2\n")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "explorer.db")
+	if err := b.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := newModel()
+	m.config.Explorer = path
+	m.toggleExplorer()
+	if m.explorer == nil {
+		t.Fatal(m.status)
+	}
+	defer m.toggleExplorer()
+
+	for _, lines := range []int{-1, 0, 1, 2, 10} {
+		text := m.renderExplorer(lines)
+		if !strings.Contains(text, "3 games") {
+			t.Errorf("%d lines: %q", lines, text)
+		}
+	}
+	if rows := strings.Count(m.renderExplorer(10), "\n"); rows != 2 {
+		t.Errorf("%d moves shown, expected e4 and d4", rows)
+	}
+	if _, ok := m.explored[m.view]; !ok || len(m.explored) != 1 {
+		t.Errorf("%d positions looked up, expected the one shown", len(m.explored))
+	}
+}
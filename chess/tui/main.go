@@ -1,5 +1,15 @@
 package main
 
+import (
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
 func main() {
-	playTUI()
+	if _, err := tea.NewProgram(newModel(), tea.WithAltScreen(), tea.WithMouseCellMotion()).Run(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
 }
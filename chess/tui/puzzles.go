@@ -0,0 +1,163 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/puzzle"
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strings"
+)
+
+// puzzles are picked within this many points of the solver's rating
+// when there are any
+const puzzleRange = 250
+
+// the puzzle being solved and how the session is going
+type trainer struct {
+	puzzles []puzzle.Puzzle
+	current puzzle.Puzzle
+	player  chess.Color
+	ply     int  // the move of the line played next
+	done    bool // solved or failed, the rating is updated
+	failed  bool
+	solved  int // puzzles solved this session
+	tried   int
+	message string
+}
+
+func loadPuzzles(path, theme string) ([]puzzle.Puzzle, error) {
+	f, err := os.Open(strings.TrimSpace(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	puzzles, err := puzzle.Read(f, strings.TrimSpace(theme))
+	if err != nil {
+		return nil, err
+	}
+	if len(puzzles) == 0 {
+		return nil, fmt.Errorf("no puzzles with theme %q", theme)
+	}
+	return puzzles, nil
+}
+
+func (m *model) beginPuzzles() error {
+	// starts the trainer on the puzzles of the wizard's file
+	puzzles, err := loadPuzzles(m.setup.puzzlePath, m.setup.puzzleTheme)
+	if err != nil {
+		return err
+	}
+	m.trainer = &trainer{puzzles: puzzles}
+	return m.nextPuzzle()
+}
+
+func (m *model) nextPuzzle() error {
+	// sets up a puzzle near the solver's rating and plays the
+	// opponent's first move
+	t := m.trainer
+	rating := m.puzzleRating().Rating
+	var near []puzzle.Puzzle
+	for _, p := range t.puzzles {
+		if math.Abs(float64(p.Rating)-rating) <= puzzleRange {
+			near = append(near, p)
+		}
+	}
+	if len(near) == 0 {
+		near = t.puzzles
+	}
+	t.current = near[rand.IntN(len(near))]
+	b, mv, err := t.current.Start()
+	if err != nil {
+		return fmt.Errorf("puzzle %s: %v", t.current.ID, err)
+	}
+	root := newRoot(b, nil)
+	m.head, m.view = root, root
+	t.ply, t.done, t.failed = 0, false, false
+	t.player = b.Turn.Other()
+	t.message = ""
+	m.flipped = t.player == chess.Black
+	name := cmp.Or(m.config.Name, "Player")
+	m.names[t.player] = name
+	m.names[t.player.Other()] = fmt.Sprintf("Puzzle %s (%d)", t.current.ID, t.current.Rating)
+	m.deselect()
+	m.play(root, mv)
+	return nil
+}
+
+func (m *model) puzzleMoved(mv chess.Move) {
+	// follows the line after a move. the solver's moves are checked
+	// and answered with the opponent's next one.
+	t := m.trainer
+	if t.done {
+		return
+	}
+	if m.head.parent.board.Turn != t.player {
+		t.ply++
+		t.message = "find the best move for " + colorName(t.player)
+		return
+	}
+	correct, solved := t.current.Try(m.head.parent.board, t.ply, mv)
+	switch {
+	case !correct:
+		t.failed = true
+		m.finishPuzzle()
+		best, _ := m.head.parent.board.ParseMove(t.current.Moves[t.ply])
+		t.message = fmt.Sprintf("wrong, %s was the move", m.head.parent.board.SAN(best))
+	case solved:
+		m.finishPuzzle()
+		t.message = "solved!"
+	default:
+		t.ply++
+		reply, err := m.head.board.ParseMove(t.current.Moves[t.ply])
+		if err != nil {
+			t.message = err.Error()
+			return
+		}
+		m.play(m.head, reply)
+		t.message = "correct, keep going"
+	}
+}
+
+func (m *model) finishPuzzle() {
+	// rates the attempt against the puzzle's rating and keeps the new
+	// rating for the next run
+	t := m.trainer
+	t.done = true
+	t.tried++
+	score := 1.0
+	if t.failed {
+		score = 0
+	} else {
+		t.solved++
+	}
+	rating := m.puzzleRating().Update(float64(t.current.Rating), float64(t.current.RatingDeviation), score)
+	m.config.PuzzleRating = &rating
+	m.config.save()
+}
+
+func (m model) puzzleRating() puzzle.Rating {
+	if m.config.PuzzleRating == nil {
+		return puzzle.NewRating()
+	}
+	return *m.config.PuzzleRating
+}
+
+func (m model) renderPuzzleStatus() string {
+	t := m.trainer
+	r := m.puzzleRating()
+	text := fmt.Sprintf("%s · rating %.0f±%.0f · solved %d/%d", t.message, r.Rating, r.Deviation, t.solved, t.tried)
+	if t.done {
+		text += " · N next puzzle"
+	}
+	return text
+}
+
+func colorName(c chess.Color) string {
+	if c == chess.Black {
+		return "Black"
+	}
+	return "White"
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/engine"
+	"chess/pgn"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// how long each position of a reviewed game is searched for
+const reviewTime = 500 * time.Millisecond
+
+// centipawns a mate counts as when moves are compared
+const mateScore = 1000
+
+// how good a move was next to the engine's choice
+const (
+	best       = "best"
+	good       = "good"
+	inaccuracy = "inaccuracy"
+	mistake    = "mistake"
+	blunder    = "blunder"
+)
+
+// the NAGs and suffixes the weaker classes are annotated with
+var (
+	classNAGs     = map[string]int{inaccuracy: 6, mistake: 2, blunder: 4}
+	classSuffixes = map[string]string{inaccuracy: "?!", mistake: "?", blunder: "??"}
+	classStyles   = map[string]lipgloss.Style{
+		inaccuracy: lipgloss.NewStyle().Foreground(lipgloss.Color("#F1C232")),
+		mistake:    lipgloss.NewStyle().Foreground(lipgloss.Color("#E69138")),
+		blunder:    lipgloss.NewStyle().Foreground(lipgloss.Color("#E06666")),
+	}
+)
+
+// the engine's view of a position, from white's side
+type evaluation struct {
+	score int
+	mate  int // moves to mate, negative when black mates, 0 if none
+	best  chess.Move
+}
+
+// an evaluation of a position of the game under review
+type reviewMsg struct {
+	review *review
+	index  int
+	eval   evaluation
+}
+
+// the engine going over a finished game
+type review struct {
+	line  []*node      // the positions of the game, the start first
+	evals []evaluation // of each position, as far as done
+}
+
+// what the review says about one move
+type verdict struct {
+	class  string
+	loss   int // centipawns lost next to the best move
+	best   chess.Move
+	before evaluation
+	after  evaluation
+}
+
+func (m *model) startReview() tea.Cmd {
+	// reviews the game once it is over, searching every position of it
+	// in turn in the background
+	end := m.lastMove()
+	if !end.outcome.Over() {
+		m.status = "the game isn't over yet"
+		return nil
+	}
+	if m.reviewer == nil {
+		m.reviewer = engine.New()
+	}
+	m.reviewer.NewGame()
+	m.review = &review{line: append([]*node{end.root()}, end.path()...)}
+	m.status = ""
+	return m.reviewStep(m.review)
+}
+
+func (m model) reviewStep(r *review) tea.Cmd {
+	// evaluates the next position of r
+	index := len(r.evals)
+	if index == len(r.line) {
+		return nil
+	}
+	n := r.line[index]
+	e := m.reviewer
+	board := *n.board
+	history := n.history()
+	return func() tea.Msg {
+		return reviewMsg{r, index, evaluate(e, &board, history, n.outcome)}
+	}
+}
+
+func evaluate(e *engine.Engine, b *chess.Board, history []uint64, outcome chess.Outcome) evaluation {
+	// searches b, unless the game is over there
+	if outcome.Over() {
+		switch outcome.Result {
+		case chess.WhiteWon:
+			return evaluation{score: mateScore}
+		case chess.BlackWon:
+			return evaluation{score: -mateScore}
+		}
+		return evaluation{}
+	}
+	move, info := e.BestMove(b, history, engine.Limits{MoveTime: reviewTime})
+	score, mate := whiteScore(info, b.Turn)
+	return evaluation{score, mate, move}
+}
+
+func (r *review) finished() bool {
+	return len(r.evals) == len(r.line)
+}
+
+func (e evaluation) centipawns() int {
+	// the score with mates counted as a large but bounded advantage
+	switch {
+	case e.mate > 0:
+		return mateScore
+	case e.mate < 0:
+		return -mateScore
+	}
+	return min(max(e.score, -mateScore), mateScore)
+}
+
+func (e evaluation) String() string {
+	return formatScore(e.score, e.mate)
+}
+
+func winChance(cp int) float64 {
+	// the expected score in percent for an advantage of cp, as fitted
+	// to games by lichess
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(cp)))-1)
+}
+
+func (r *review) verdict(i int) (verdict, bool) {
+	// judges the move from line[i] to line[i+1], once both positions
+	// are evaluated
+	if i < 0 || i+1 >= len(r.evals) {
+		return verdict{}, false
+	}
+	before, after := r.evals[i], r.evals[i+1]
+	sign := 1
+	if r.line[i].board.Turn == chess.Black {
+		sign = -1
+	}
+	v := verdict{
+		loss:   max(sign*(before.centipawns()-after.centipawns()), 0),
+		best:   before.best,
+		before: before,
+		after:  after,
+	}
+	switch {
+	case r.line[i+1].move.Move == before.best:
+		v.class = best
+	case v.loss < 50:
+		v.class = good
+	case v.loss < 100:
+		v.class = inaccuracy
+	case v.loss < 300:
+		v.class = mistake
+	default:
+		v.class = blunder
+	}
+	return v, true
+}
+
+func (v verdict) accuracy(mover chess.Color) float64 {
+	// how close the move kept the mover's winning chances, 100 for no
+	// loss at all, with the formula lichess uses
+	sign := 1
+	if mover == chess.Black {
+		sign = -1
+	}
+	drop := winChance(sign*v.before.centipawns()) - winChance(sign*v.after.centipawns())
+	return min(max(103.1668*math.Exp(-0.04354*max(drop, 0))-3.1669, 0), 100)
+}
+
+// how one side played over a reviewed game
+type reviewSummary struct {
+	moves    int
+	loss     int // total centipawn loss
+	accuracy float64
+	classes  map[string]int
+}
+
+func (r *review) summary(color chess.Color) reviewSummary {
+	s := reviewSummary{classes: map[string]int{}}
+	for i := range r.line[:len(r.line)-1] {
+		v, ok := r.verdict(i)
+		if !ok {
+			break
+		}
+		if r.line[i].board.Turn != color {
+			continue
+		}
+		s.moves++
+		s.loss += v.loss
+		s.accuracy += v.accuracy(color)
+		s.classes[v.class]++
+	}
+	if s.moves > 0 {
+		s.accuracy /= float64(s.moves)
+	}
+	return s
+}
+
+func (r *review) verdictOf(n *node) (verdict, bool) {
+	// the verdict on the move leading to n, if n is in the game reviewed
+	i := slices.Index(r.line, n)
+	if i < 1 {
+		return verdict{}, false
+	}
+	return r.verdict(i - 1)
+}
+
+func (m *model) nextMistake(step int) {
+	// goes to the next inaccuracy or worse in the direction of step
+	r := m.review
+	if r == nil {
+		m.status = "no review, press R once the game is over"
+		return
+	}
+	i := slices.Index(r.line, m.view)
+	if i < 0 {
+		i = 0
+	}
+	for i += step; i > 0 && i < len(r.line); i += step {
+		if v, ok := r.verdict(i - 1); ok && classNAGs[v.class] != 0 {
+			m.goTo(r.line[i])
+			return
+		}
+	}
+	m.status = "no more mistakes that way"
+}
+
+func (m model) annotate(moves []pgn.Move, path []*node) {
+	// adds the review's NAGs and evaluations to the moves leading
+	// along path
+	r := m.review
+	if r == nil {
+		return
+	}
+	for i, n := range path {
+		v, ok := r.verdictOf(n)
+		if !ok {
+			continue
+		}
+		var notes []string
+		if nag := classNAGs[v.class]; nag != 0 {
+			moves[i].NAGs = append(moves[i].NAGs, nag)
+			notes = append(notes, fmt.Sprintf("%s. %s was best.", strings.ToUpper(v.class[:1])+v.class[1:], n.parent.board.SAN(v.best)))
+		}
+		if !n.outcome.Over() {
+			notes = append(notes, fmt.Sprintf("[%%eval %s]", evalCommand(v.after)))
+		}
+		if moves[i].Comment != "" {
+			notes = append(notes, moves[i].Comment)
+		}
+		moves[i].Comment = strings.Join(notes, " ")
+	}
+}
+
+func evalCommand(e evaluation) string {
+	// an evaluation as written in a PGN %eval command
+	if e.mate != 0 {
+		return fmt.Sprintf("#%d", e.mate)
+	}
+	return fmt.Sprintf("%.2f", float64(e.score)/100)
+}
+
+func (m model) renderReview(lines int) string {
+	// the accuracy of each side and the verdict on the move shown
+	r := m.review
+	title := titleStyle.Render("Review")
+	if !r.finished() {
+		title += labelStyle.Render(fmt.Sprintf(" %d/%d", len(r.evals), len(r.line)))
+	}
+	rows := []string{title}
+	for color, name := range []string{"White", "Black"} {
+		s := r.summary(chess.Color(color))
+		rows = append(rows, fmt.Sprintf("%-6s%3.0f%% acpl %-3d %s %s %s", name, s.accuracy, s.loss/max(s.moves, 1),
+			classStyles[inaccuracy].Render(fmt.Sprintf("?!%d", s.classes[inaccuracy])),
+			classStyles[mistake].Render(fmt.Sprintf("?%d", s.classes[mistake])),
+			classStyles[blunder].Render(fmt.Sprintf("??%d", s.classes[blunder]))))
+	}
+	if v, ok := r.verdictOf(m.view); ok {
+		text := fmt.Sprintf("%s: %s", m.view.move.SAN, v.class)
+		if v.class != best {
+			text += fmt.Sprintf(", %s was best", m.view.parent.board.SAN(v.best))
+		}
+		text += fmt.Sprintf(" (%s → %s)", v.before, v.after)
+		rows = append(rows, "", lipgloss.NewStyle().Width(analysisWidth+6).MaxHeight(max(lines-4, 1)).Render(text))
+	}
+	return strings.Join(rows, "\n")
+}
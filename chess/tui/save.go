@@ -0,0 +1,55 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/pgn"
+	"fmt"
+	"os"
+	"time"
+)
+
+func (m model) record() *pgn.Game {
+	// the game so far as PGN, with the players, the time control and
+	// the clock after every move, and once reviewed the evaluations
+	// and mistakes. only the line the game took is written, not the
+	// variations tried on the way.
+	end := m.lastMove()
+	outcome := end.outcome
+	g := &pgn.Game{Moves: end.moves(), Result: outcome.Result}
+	m.annotate(g.Moves, end.path())
+	g.SetTag("Event", "Casual game")
+	g.SetTag("Site", "chess tui")
+	g.SetTag("Date", time.Now().Format("2006.01.02"))
+	g.SetTag("White", m.names[chess.White])
+	g.SetTag("Black", m.names[chess.Black])
+	g.SetTag("Result", outcome.Result)
+	if end.root().board.Chess960 {
+		g.SetTag("Variant", "Chess960")
+	}
+	if fen := end.root().board.ToFEN(); fen != chess.StartFEN || end.root().board.Chess960 {
+		g.SetTag("SetUp", "1")
+		g.SetTag("FEN", fen)
+	}
+	if m.clock != nil {
+		g.SetTag("TimeControl", m.clock.control.pgnTag())
+	}
+	if outcome.Over() {
+		g.SetTag("Termination", outcome.Reason)
+	}
+	if m.review != nil {
+		g.SetTag("Annotator", "chess engine")
+	}
+	return g
+}
+
+func (m model) save() (string, error) {
+	// writes the game to a new PGN file in the working directory
+	if !m.playing {
+		return "", fmt.Errorf("no game to save")
+	}
+	path := fmt.Sprintf("chess-%s.pgn", time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(path, []byte(m.record().String()), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
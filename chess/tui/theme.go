@@ -0,0 +1,137 @@
+package main
+
+import (
+	chess "chess/board"
+	"slices"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the colours the board is drawn in
+type theme struct {
+	Name       string
+	light      lipgloss.Color
+	dark       lipgloss.Color
+	lastLight  lipgloss.Color // squares of the last move
+	lastDark   lipgloss.Color
+	cursor     lipgloss.Color
+	selected   lipgloss.Color
+	check      lipgloss.Color
+	target     lipgloss.Color // the dots on legal destinations
+	hint       lipgloss.Color // the squares of a suggested move
+	threat     lipgloss.Color // the squares of the opponent's threat
+	attacked   lipgloss.Color // squares the opponent attacks
+	whitePiece lipgloss.Color
+	blackPiece lipgloss.Color
+}
+
+var themes = []theme{
+	{
+		Name:  "green",
+		light: "#EEEED2", dark: "#769656",
+		lastLight: "#F6F669", lastDark: "#BACA2B",
+		cursor: "#6FA8DC", selected: "#F4A460", check: "#E06666", target: "#444444",
+		hint: "#93C47D", threat: "#CC4125", attacked: "#E6B8AF",
+		whitePiece: "#FFFFFF", blackPiece: "#000000",
+	},
+	{
+		Name:  "brown",
+		light: "#F0D9B5", dark: "#B58863",
+		lastLight: "#CDD26A", lastDark: "#AAA23A",
+		cursor: "#6FA8DC", selected: "#E8A33C", check: "#E06666", target: "#5A4632",
+		hint: "#9BC0A0", threat: "#C0504D", attacked: "#E8B4A0",
+		whitePiece: "#FFFFFF", blackPiece: "#000000",
+	},
+	{
+		Name:  "blue",
+		light: "#DEE3E6", dark: "#8CA2AD",
+		lastLight: "#C3D888", lastDark: "#92B166",
+		cursor: "#F6B26B", selected: "#F4A460", check: "#E06666", target: "#3C4A52",
+		hint: "#93C47D", threat: "#CC4125", attacked: "#E6B8AF",
+		whitePiece: "#FFFFFF", blackPiece: "#000000",
+	},
+	{
+		// the Catppuccin Mocha palette, matching the new game wizard
+		Name:  "catppuccin",
+		light: "#BAC2DE", dark: "#585B70",
+		lastLight: "#F9E2AF", lastDark: "#FAB387",
+		cursor: "#89DCEB", selected: "#CBA6F7", check: "#F38BA8", target: "#1E1E2E",
+		hint: "#A6E3A1", threat: "#EBA0AC", attacked: "#F5C2E7",
+		whitePiece: "#FFFFFF", blackPiece: "#11111B",
+	},
+	{
+		// no colour but the pieces', for terminals with few colours
+		Name:  "plain",
+		light: "7", dark: "8",
+		lastLight: "3", lastDark: "3",
+		cursor: "4", selected: "5", check: "1", target: "0",
+		hint: "2", threat: "1", attacked: "9",
+		whitePiece: "15", blackPiece: "0",
+	},
+}
+
+// how the pieces are drawn
+type pieceSet struct {
+	Name   string
+	glyphs [2][7]string // by colour and piece
+}
+
+var pieceSets = []pieceSet{
+	{
+		// the same solid glyphs for both sides, told apart by colour
+		Name: "unicode",
+		glyphs: [2][7]string{
+			{"", "♟", "♞", "♝", "♜", "♛", "♚"},
+			{"", "♟", "♞", "♝", "♜", "♛", "♚"},
+		},
+	},
+	{
+		Name: "ascii",
+		glyphs: [2][7]string{
+			{"", "P", "N", "B", "R", "Q", "K"},
+			{"", "p", "n", "b", "r", "q", "k"},
+		},
+	},
+	{
+		// the chess icons of Nerd Fonts, for terminals using one
+		Name: "nerd",
+		glyphs: [2][7]string{
+			{"", "\U000F0859", "\U000F0858", "\U000F085C", "\U000F085B", "\U000F085A", "\U000F0857"},
+			{"", "\U000F0859", "\U000F0858", "\U000F085C", "\U000F085B", "\U000F085A", "\U000F0857"},
+		},
+	},
+}
+
+func findTheme(name string) theme {
+	// the theme called name, or the first when there is none
+	for _, t := range themes {
+		if t.Name == name {
+			return t
+		}
+	}
+	return themes[0]
+}
+
+func findPieceSet(name string) pieceSet {
+	for _, s := range pieceSets {
+		if s.Name == name {
+			return s
+		}
+	}
+	return pieceSets[0]
+}
+
+func (s pieceSet) glyph(p chess.Piece, c chess.Color) string {
+	return s.glyphs[c][p]
+}
+
+func nextTheme(name string) string {
+	// the theme after name, going round
+	i := slices.IndexFunc(themes, func(t theme) bool { return t.Name == findTheme(name).Name })
+	return themes[(i+1)%len(themes)].Name
+}
+
+func nextPieceSet(name string) string {
+	i := slices.IndexFunc(pieceSets, func(s pieceSet) bool { return s.Name == findPieceSet(name).Name })
+	return pieceSets[(i+1)%len(pieceSets)].Name
+}
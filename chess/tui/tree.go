@@ -0,0 +1,109 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/pgn"
+)
+
+// a position in the game tree. every move tried from a position is a
+// child of it, so going back and playing something else starts a
+// variation instead of losing the moves played before.
+type node struct {
+	parent   *node
+	children []*node
+	selected int          // the child going forward follows
+	move     pgn.Move     // the move leading here, empty at the root
+	board    *chess.Board // the position after the move
+	outcome  chess.Outcome
+}
+
+func newRoot(b *chess.Board, history []uint64) *node {
+	return &node{board: b, outcome: b.Outcome(history)}
+}
+
+func (n *node) history() []uint64 {
+	// hashes of the positions before n, oldest first
+	var hashes []uint64
+	for p := n.parent; p != nil; p = p.parent {
+		hashes = append(hashes, p.board.Hash())
+	}
+	for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+		hashes[i], hashes[j] = hashes[j], hashes[i]
+	}
+	return hashes
+}
+
+func (n *node) play(mv chess.Move, comment string) *node {
+	// returns the position after mv, adding it to the tree unless
+	// it was played from here before. either way it becomes the
+	// selected line.
+	for i, child := range n.children {
+		if child.move.Move == mv {
+			n.selected = i
+			return child
+		}
+	}
+	next := *n.board
+	next.MakeMove(mv)
+	child := &node{
+		parent: n,
+		move:   pgn.Move{Move: mv, SAN: n.board.SAN(mv), Comment: comment},
+		board:  &next,
+	}
+	child.outcome = next.Outcome(child.history())
+	n.children = append(n.children, child)
+	n.selected = len(n.children) - 1
+	return child
+}
+
+func (n *node) next() *node {
+	// the position forward along the selected line, nil at its end
+	if len(n.children) == 0 {
+		return nil
+	}
+	return n.children[n.selected]
+}
+
+func (n *node) root() *node {
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n
+}
+
+func (n *node) path() []*node {
+	// the positions from the first move up to n
+	var nodes []*node
+	for p := n; p.parent != nil; p = p.parent {
+		nodes = append(nodes, p)
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return nodes
+}
+
+func (n *node) line() []*node {
+	// the path to n continued along the selected moves to the end
+	nodes := n.path()
+	for p := n.next(); p != nil; p = p.next() {
+		nodes = append(nodes, p)
+	}
+	return nodes
+}
+
+func (n *node) siblings() []*node {
+	if n.parent == nil {
+		return []*node{n}
+	}
+	return n.parent.children
+}
+
+func (n *node) moves() []pgn.Move {
+	// the moves leading to n
+	var moves []pgn.Move
+	for _, p := range n.path() {
+		moves = append(moves, p.move)
+	}
+	return moves
+}
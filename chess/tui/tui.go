@@ -1,81 +1,691 @@
 package main
 
 import (
-	"bufio"
-	"fmt"
-	"os"
+	chess "chess/board"
+	"chess/engine"
+	"chess/explorer"
+	"chess/tablebase"
+	"math/rand/v2"
+	"slices"
 	"strings"
+	"time"
 
-	chess "chess/board"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
 )
 
-func playTUI() {
-	var board *chess.Board = chess.NewBoard()
-	counter := 0
-	for counter < 50 {
-		fmt.Println(board.PrintBoard())
-		fmt.Printf("Turn: %v (0=White, 1=Black)\n", board.Turn)
-		if !requestMove(board, &counter) {
-			continue
-		}
-		counter++
-		board.Turn = board.Turn.Other()
-	}
-	fmt.Println("Draw by fifty moves!")
-}
-
-var pieceMap = map[string]chess.Piece{
-	"Pawn": chess.Pawns, "Pawns": chess.Pawns, "pawn": chess.Pawns, "pawns": chess.Pawns,
-	"Knight": chess.Knights, "Knights": chess.Knights, "knight": chess.Knights, "knights": chess.Knights,
-	"Bishop": chess.Bishops, "Bishops": chess.Bishops, "bishop": chess.Bishops, "bishops": chess.Bishops,
-	"Rook": chess.Rooks, "Rooks": chess.Rooks, "rook": chess.Rooks, "rooks": chess.Rooks,
-	"Queen": chess.Queens, "Queens": chess.Queens, "queen": chess.Queens, "queens": chess.Queens,
-	"King": chess.Kings, "Kings": chess.Kings, "king": chess.Kings, "kings": chess.Kings,
-}
-
-func requestMove(board *chess.Board, counter *int) bool {
-	fmt.Println("Please input move.")
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		fmt.Printf("Error reading input: %v\n", err)
-		return false
-	}
-	var start chess.Square
-	var end chess.Square
-	var promotion chess.Piece
-	var piece chess.Piece
-	input = strings.TrimSpace(input)
-	move := strings.Split(input, " ")
-	if input == "draw" || input == "Draw" {
-		return true
-	}
-	if len(move) < 2 || len(move) > 3 {
-		fmt.Println("Please provide at two words if not promoting, and three words if promoting.")
-		return false
-	}
-	if len(move) == 3 {
-		promotion = pieceMap[move[2]]
+type model struct {
+	// the game tree: head is the position the game has reached and view
+	// the one on the board, which is behind it while looking back
+	head *node
+	view *node
+
+	// how the game is played, from the new game wizard
+	config   config
+	setup    *setup
+	wizard   *huh.Form // open while a new game is being set up
+	names    [2]string // the players, by colour
+	engines  [2]*engine.Engine
+	game     int  // counts the games so late ticks can be told apart
+	search   int  // counts engine searches so late replies can be told apart
+	playing  bool // a game has been set up
+	clock    *clock
+	thinking bool
+
+	// the engine analysing the position shown, when the panel is open
+	analyzing  bool
+	analyst    *engine.Engine
+	analysisID int // counts the analyses so late results can be told apart
+	analysisOf *node
+	analysis   engine.SearchInfo // the deepest iteration so far
+	infos      <-chan engine.SearchInfo
+	probe      tablebase.Result // the tablebases' verdict on analysisOf, when probed
+	probed     bool
+
+	// hints and threats, found by another engine, and where they apply
+	helper   *engine.Engine
+	hint     chess.Move
+	hintOf   *node
+	threat   chess.Move
+	threatOf *node
+
+	trainer  *trainer     // set while solving puzzles
+	explorer *explorer.DB // open while the explorer pane is shown
+	explored map[*node]explored
+
+	// the engine going over the game once it is over
+	review   *review
+	reviewer *engine.Engine
+
+	flipped  bool // the board is seen from black's side
+	cursor   chess.Square
+	selected bool
+	from     chess.Square // the square picked up, when selected
+	targets  []chess.Move // legal moves of the piece picked up
+	dragging bool         // the mouse button is held on the piece picked up
+
+	// a pawn reaching the last rank waits here for the piece it becomes
+	promoting []chess.Move
+	choice    int
+
+	status string
+	width  int
+	height int
+}
+
+// an engine has found its move
+type engineMoveMsg struct {
+	search int
+	move   chess.Move
+}
+
+// redraws the running clock
+type tickMsg struct {
+	game int
+}
+
+func tick(game int) tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg { return tickMsg{game} })
+}
+
+func newModel() model {
+	m := model{
+		head:   newRoot(chess.NewBoard(), nil),
+		cursor: chess.NotationToIndex["e2"],
+		config: loadConfig(),
+	}
+	m.view = m.head
+	if m.config.Syzygy != "" {
+		if err := tablebase.Init(m.config.Syzygy); err != nil {
+			m.status = "no tablebases: " + err.Error()
+		}
+	}
+	m.setup = newSetup(m.config)
+	m.wizard = newWizard(m.setup)
+	return m
+}
+
+func (m model) Init() tea.Cmd {
+	return m.wizard.Init()
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.wizard != nil {
+		return m.updateWizard(msg)
+	}
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case engineMoveMsg:
+		if msg.search != m.search {
+			break
+		}
+		m.thinking = false
+		if msg.move != chess.NullMove {
+			m.play(m.head, msg.move)
+		}
+	case tickMsg:
+		if msg.game != m.game || m.clock == nil || m.head.outcome.Over() {
+			break
+		}
+		if m.clock.flagged(time.Now()) {
+			m.flag()
+			break
+		}
+		return m, tea.Batch(tick(m.game), m.engineTurn(), m.followAnalysis())
+	case analysisMsg:
+		if msg.id != m.analysisID || !msg.ok {
+			break
+		}
+		m.analysis = msg.info
+		return m, tea.Batch(waitAnalysis(m.infos, m.analysisID), m.engineTurn(), m.followAnalysis())
+	case helperMsg:
+		m.helped(msg)
+	case reviewMsg:
+		if msg.review != m.review {
+			break
+		}
+		m.review.evals = append(m.review.evals, msg.eval)
+		return m, tea.Batch(m.reviewStep(m.review), m.engineTurn(), m.followAnalysis())
+	case tea.MouseMsg:
+		m.mouse(msg)
+	case tea.KeyMsg:
+		if m.promoting != nil {
+			m.promotionKey(msg.String())
+			break
+		}
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.stopEngines()
+			for _, e := range []*engine.Engine{m.analyst, m.helper, m.reviewer} {
+				if e != nil {
+					e.Stop()
+				}
+			}
+			return m, tea.Quit
+		case "up", "k":
+			m.moveCursor(0, 1)
+		case "down", "j":
+			m.moveCursor(0, -1)
+		case "left", "h":
+			m.moveCursor(-1, 0)
+		case "right", "l":
+			m.moveCursor(1, 0)
+		case "f":
+			m.flipped = !m.flipped
+		case "a":
+			return m, tea.Batch(m.toggleAnalysis(), m.engineTurn())
+		case "?":
+			return m, tea.Batch(m.askHelper(false), m.engineTurn(), m.followAnalysis())
+		case "T":
+			return m, tea.Batch(m.askHelper(true), m.engineTurn(), m.followAnalysis())
+		case "o":
+			m.toggleExplorer()
+		case "R":
+			return m, tea.Batch(m.startReview(), m.engineTurn(), m.followAnalysis())
+		case "N":
+			if m.trainer != nil {
+				if err := m.nextPuzzle(); err != nil {
+					m.status = err.Error()
+				}
+			}
+		case "[":
+			m.nextMistake(-1)
+		case "]":
+			m.nextMistake(1)
+		case "t", "p", "c", "m":
+			m.changeLook(msg.String())
+		case "enter", " ":
+			m.choose(m.cursor)
+		case "esc":
+			m.deselect()
+		case "u", "ctrl+z":
+			m.undo()
+		case "r", "ctrl+y":
+			m.redo()
+		case ",", "pgup":
+			if m.view.parent != nil {
+				m.goTo(m.view.parent)
+			}
+		case ".", "pgdown":
+			if next := m.view.next(); next != nil {
+				m.goTo(next)
+			}
+		case "home":
+			m.goTo(m.view.root())
+		case "end":
+			m.goTo(m.lastMove())
+		case "v":
+			m.variation()
+		case "s":
+			if path, err := m.save(); err != nil {
+				m.status = err.Error()
+			} else {
+				m.status = "saved to " + path
+			}
+		case "n":
+			m.wizard = newWizard(m.setup)
+			return m, m.wizard.Init()
+		}
+	}
+	return m, tea.Batch(m.engineTurn(), m.followAnalysis())
+}
+
+func (m model) updateWizard(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// hands everything to the form until it is filled in, then starts
+	// the game it describes
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.width, m.height = size.Width, size.Height
+	}
+	form, cmd := m.wizard.Update(msg)
+	m.wizard = form.(*huh.Form)
+	switch m.wizard.State {
+	case huh.StateAborted:
+		m.wizard = nil
+		if !m.playing {
+			return m, tea.Quit
+		}
+		return m, nil
+	case huh.StateCompleted:
+		m.wizard = nil
+		if err := m.begin(); err != nil {
+			m.status = err.Error()
+		}
+		return m, tea.Batch(tick(m.game), m.engineTurn(), m.followAnalysis())
+	}
+	return m, cmd
+}
+
+func (m *model) begin() error {
+	// sets up a new game from the wizard's answers
+	s := m.setup
+	m.config.Name = strings.TrimSpace(s.name)
+	m.config.save()
+	name := m.config.Name
+	if name == "" {
+		name = "Player"
+	}
+
+	head := newRoot(chess.NewBoard(), nil)
+	switch s.start {
+	case fromStart:
+		if s.variant == chess960 {
+			head = newRoot(chess.NewChess960Board(rand.IntN(960)), nil)
+		}
+	case fromFEN:
+		b, err := chess.NewBoardFromFEN(strings.TrimSpace(s.fen))
+		if err != nil {
+			return err
+		}
+		// a Chess960 FEN with the rooks on a and h reads as standard
+		b.Chess960 = b.Chess960 || s.variant == chess960
+		head = newRoot(b, nil)
+	case fromPGN:
+		g, err := loadPGN(s.pgnPath)
+		if err != nil {
+			return err
+		}
+		start, err := g.StartBoard()
+		if err != nil {
+			return err
+		}
+		head = newRoot(start, nil)
+		for _, mv := range g.Moves {
+			head = head.play(mv.Move, "")
+		}
+	}
+
+	m.cancelSearch()
+	m.game++
+	m.playing = true
+	m.head, m.view = head, head
+	m.review = nil
+	m.clock = newClock(s.clock(), head.board.Turn, time.Now())
+	m.deselect()
+	m.status = ""
+	m.engines = [2]*engine.Engine{}
+	m.names = [2]string{name, name}
+	m.flipped = false
+	m.trainer = nil
+	switch s.mode {
+	case humanVsEngine:
+		human := chess.White
+		if s.side == "Black" || s.side == "Random" && rand.IntN(2) == 1 {
+			human = chess.Black
+		}
+		m.flipped = human == chess.Black
+		m.engines[human.Other()] = s.newEngine()
+		m.names[human.Other()] = s.engineName()
+	case engineVsEngine:
+		m.engines = [2]*engine.Engine{s.newEngine(), s.newEngine()}
+		m.names = [2]string{s.engineName(), s.engineName()}
+	case puzzles:
+		m.clock = nil
+		return m.beginPuzzles()
+	}
+	return nil
+}
+
+func (m model) stopEngines() {
+	// the engines of a game being left behind stop thinking
+	for _, e := range m.engines {
+		if e != nil {
+			e.Stop()
+		}
+	}
+}
+
+func (m *model) cancelSearch() {
+	// drops the engine move being searched for, whose reply is then
+	// ignored when it comes
+	m.stopEngines()
+	m.search++
+	m.thinking = false
+}
+
+func (m model) humanToMove() bool {
+	// whether a human plays the side to move in the position shown
+	return m.playing && m.engines[m.view.board.Turn] == nil
+}
+
+func (m model) takebacks() bool {
+	// moves can be taken back and played differently when a human is
+	// playing and no clock is running
+	return m.playing && m.clock == nil && m.trainer == nil && (m.engines[chess.White] == nil || m.engines[chess.Black] == nil)
+}
+
+func (m *model) engineTurn() tea.Cmd {
+	// starts the engine thinking when it is its move
+	e := m.engines[m.head.board.Turn]
+	if e == nil || m.thinking || m.head.outcome.Over() || m.wizard != nil {
+		return nil
+	}
+	m.thinking = true
+	board := *m.head.board
+	history := m.head.history()
+	limits := m.engineLimits()
+	search := m.search
+	return func() tea.Msg {
+		move, _ := e.BestMove(&board, history, limits)
+		return engineMoveMsg{search, move}
+	}
+}
+
+func (m model) engineLimits() engine.Limits {
+	// the engine plays to the clock, or takes about a second a move
+	// without one. delays are treated like an increment.
+	if m.clock == nil {
+		return engine.Limits{MoveTime: time.Second}
+	}
+	now := time.Now()
+	c := m.clock
+	return engine.Limits{
+		WTime:     c.left(chess.White, now),
+		BTime:     c.left(chess.Black, now),
+		WInc:      c.current(chess.White).bonus,
+		BInc:      c.current(chess.Black).bonus,
+		MovesToGo: c.movesToGo(m.head.board.Turn),
+	}
+}
+
+func (m *model) flag() {
+	// the side to move ran out of time. it loses unless the opponent
+	// could never mate.
+	m.cancelSearch()
+	b := m.head.board
+	m.clock.remaining[b.Turn] = 0
+	m.clock.stopped = true
+	m.deselect()
+	if !b.HasMatingMaterial(b.Turn.Other()) {
+		m.head.outcome = chess.Outcome{Result: chess.Drawn, Reason: "timeout vs insufficient material"}
+	} else if b.Turn == chess.White {
+		m.head.outcome = chess.Outcome{Result: chess.BlackWon, Reason: "time forfeit"}
 	} else {
-		promotion = chess.Empty
+		m.head.outcome = chess.Outcome{Result: chess.WhiteWon, Reason: "time forfeit"}
+	}
+}
+
+func (m *model) changeLook(key string) {
+	// switches to the next theme or piece set, or toggles the
+	// coordinates or the compact layout, and keeps the choice for
+	// the next run
+	switch key {
+	case "t":
+		m.config.Theme = nextTheme(m.config.Theme)
+		m.status = "theme " + m.config.Theme
+	case "p":
+		m.config.Pieces = nextPieceSet(m.config.Pieces)
+		m.status = "pieces " + m.config.Pieces
+	case "c":
+		m.config.HideCoordinates = !m.config.HideCoordinates
+	case "m":
+		m.config.Compact = !m.config.Compact
+	}
+	if err := m.config.save(); err != nil {
+		m.status = err.Error()
+	}
+}
+
+func (m *model) moveCursor(files, ranks int) {
+	// moves the cursor as seen on screen, so the other way round when
+	// the board is flipped
+	if m.flipped {
+		files, ranks = -files, -ranks
+	}
+	file := min(max(int(m.cursor%8)+files, 0), 7)
+	rank := min(max(int(m.cursor/8)+ranks, 0), 7)
+	m.cursor = chess.Square(rank*8 + file)
+}
+
+func (m *model) choose(sq chess.Square) {
+	// picks up the piece on sq, or puts the one picked up down on sq
+	// when that is a legal move
+	if !m.humanToMove() || m.view.outcome.Over() {
+		return
+	}
+	if m.view != m.head && !m.takebacks() {
+		m.status = "looking back at the game, press end to return"
+		return
+	}
+	if m.selected {
+		var moves []chess.Move
+		for _, mv := range m.targets {
+			if mv.To == sq {
+				moves = append(moves, mv)
+			}
+		}
+		if len(moves) == 1 {
+			m.play(m.view, moves[0])
+			return
+		} else if len(moves) > 1 {
+			// one move for each piece the pawn can become
+			m.promoting = moves
+			m.choice = 0
+			return
+		}
+	}
+	if m.view.board.GetPieceAt(sq, m.view.board.Turn) == chess.Empty {
+		if m.selected {
+			m.status = "illegal move"
+		}
+		m.deselect()
+		return
 	}
-	start = chess.NotationToIndex[move[0]]
-	end = chess.NotationToIndex[move[1]]
-	piece, promotion = board.IsLegal(start, end, promotion)
-	fmt.Println(start)
-	if piece == chess.Empty {
-		fmt.Println("Move not legal.")
-		return false
+	m.selected = true
+	m.from = sq
+	m.targets = nil
+	for _, mv := range m.view.board.LegalMoves() {
+		if mv.From == sq {
+			m.targets = append(m.targets, mv)
+		}
+	}
+	m.status = ""
+	if len(m.targets) == 0 {
+		m.status = "that piece can't move"
+	}
+}
+
+func (m *model) deselect() {
+	m.selected = false
+	m.dragging = false
+	m.targets = nil
+	m.promoting = nil
+}
+
+func (m *model) promotionKey(key string) {
+	// picks the piece for a promotion with its letter, or the arrows
+	// and enter. esc takes the pawn back.
+	switch key {
+	case "left", "h":
+		m.choice = (m.choice + len(m.promoting) - 1) % len(m.promoting)
+	case "right", "l":
+		m.choice = (m.choice + 1) % len(m.promoting)
+	case "enter", " ":
+		m.play(m.view, m.promoting[m.choice])
+	case "esc", "ctrl+c":
+		m.deselect()
+	default:
+		for _, mv := range m.promoting {
+			if key == string(mv.String()[4]) {
+				m.play(m.view, mv)
+			}
+		}
+	}
+}
+
+func (m *model) mouse(msg tea.MouseMsg) {
+	// a click picks a piece up and a second click puts it down; the
+	// piece can also be dragged and let go on its target. the right
+	// button cancels.
+	if msg.Button == tea.MouseButtonRight && msg.Action == tea.MouseActionPress {
+		m.deselect()
+		return
 	}
-	if piece == chess.Pawns {
-		*counter = 0
+	if m.promoting != nil {
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
+			if i, ok := m.promotionAt(msg.X, msg.Y); ok && i < len(m.promoting) {
+				m.play(m.view, m.promoting[i])
+			}
+		}
+		return
 	}
-	if board.MovePiece(piece, start, end, promotion) {
-		*counter = 0
+	sq, ok := m.squareAt(msg.X, msg.Y)
+	switch {
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft:
+		if n := m.moveAt(msg.X, msg.Y); n != nil {
+			m.goTo(n)
+			return
+		}
+		if !ok {
+			m.deselect()
+			return
+		}
+		m.cursor = sq
+		m.choose(sq)
+		m.dragging = m.selected && m.from == sq
+	case msg.Action == tea.MouseActionRelease && m.dragging:
+		m.dragging = false
+		if ok && sq != m.from {
+			m.cursor = sq
+			m.choose(sq)
+		}
 	}
-	return true
 }
 
-func main() {
-	playTUI()
+func (m *model) play(from *node, mv chess.Move) {
+	// plays mv in the position from, which is the game's unless a
+	// move is being taken back by playing another. the board follows
+	// the game when it was showing where the move was played.
+	if from != m.head {
+		m.cancelSearch()
+	}
+	comment := ""
+	if m.clock != nil {
+		now := time.Now()
+		if m.clock.flagged(now) {
+			// the time ran out before the move came, between two ticks
+			m.flag()
+			return
+		}
+		m.clock.press(now)
+		comment = clkComment(m.clock.remaining[from.board.Turn])
+	}
+	follow := m.view == from
+	m.head = from.play(mv, comment)
+	if follow {
+		m.view = m.head
+	}
+	m.deselect()
+	m.status = ""
+	if m.head.outcome.Over() && m.clock != nil {
+		m.clock.stopped = true
+	}
+	if m.trainer != nil {
+		m.puzzleMoved(mv)
+	}
+}
+
+func (m *model) goTo(n *node) {
+	// shows the position n. in a game between humans without a clock
+	// the game goes there too, so it carries on from it.
+	m.view = n
+	m.deselect()
+	m.status = ""
+	if m.followsView() {
+		m.head = n
+	}
+}
+
+func (m model) followsView() bool {
+	return m.takebacks() && m.engines == [2]*engine.Engine{}
+}
+
+func (m model) lastMove() *node {
+	// where the end key goes: the game, or the end of the line shown
+	// when the game goes wherever the board does
+	n := m.head
+	if m.followsView() {
+		for n.next() != nil {
+			n = n.next()
+		}
+	}
+	return n
+}
+
+func (m *model) undo() {
+	// takes back the last move, or against the engine the last move
+	// of each side so it is the human's turn again
+	if !m.takebacks() {
+		m.status = "moves can't be taken back in this game"
+		return
+	}
+	n := m.head
+	for n.parent != nil {
+		n = n.parent
+		if m.engines[n.board.Turn] == nil {
+			break
+		}
+	}
+	if n == m.head {
+		m.status = "nothing to undo"
+		return
+	}
+	m.cancelSearch()
+	m.head = n
+	m.goTo(n)
+}
+
+func (m *model) redo() {
+	// plays again what undo took back, along the selected line
+	if !m.takebacks() {
+		m.status = "moves can't be taken back in this game"
+		return
+	}
+	n := m.head
+	for n.next() != nil {
+		n = n.next()
+		if m.engines[n.board.Turn] == nil {
+			break
+		}
+	}
+	if n == m.head {
+		m.status = "nothing to redo"
+		return
+	}
+	m.cancelSearch()
+	m.head = n
+	m.goTo(n)
+}
+
+func (m *model) variation() {
+	// switches to the next line tried from the position before the one
+	// shown, if any
+	siblings := m.view.siblings()
+	if len(siblings) < 2 {
+		m.status = "no other variation here"
+		return
+	}
+	i := slices.Index(siblings, m.view)
+	next := siblings[(i+1)%len(siblings)]
+	m.view.parent.selected = (i + 1) % len(siblings)
+	m.goTo(next)
+}
+
+func (m model) isTarget(sq chess.Square) bool {
+	for _, mv := range m.targets {
+		if mv.To == sq {
+			return true
+		}
+	}
+	return false
+}
+
+func pieceAt(b *chess.Board, sq chess.Square) (chess.Piece, chess.Color) {
+	// returns the piece on sq and its colour, Empty if there is none
+	for c := chess.White; c <= chess.Black; c++ {
+		if p := b.GetPieceAt(sq, c); p != chess.Empty {
+			return p, c
+		}
+	}
+	return chess.Empty, chess.White
 }
@@ -0,0 +1,379 @@
+package main
+
+import (
+	chess "chess/board"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	labelStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	paneStyle         = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	titleStyle        = lipgloss.NewStyle().Bold(true)
+	statusStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Background(lipgloss.Color("#3C3C3C")).Padding(0, 1)
+	messageStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#E06666"))
+	clockStyle        = lipgloss.NewStyle().Padding(0, 1).Background(lipgloss.Color("#3C3C3C")).Foreground(lipgloss.Color("#AAAAAA"))
+	variationStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#6FA8DC")).Italic(true)
+	runningClockStyle = clockStyle.Background(lipgloss.Color("#EEEED2")).Foreground(lipgloss.Color("#000000")).Bold(true)
+)
+
+// where things are on screen, used to tell what the mouse points at
+const (
+	pickerLeft  = len("Promote to ")
+	pickerWidth = 3 // columns per piece in the picker
+	movesTop    = 3 // below the pane's border and the players
+	moveWidth   = 7 // columns per move in a row
+	numberWidth = 5
+)
+
+// the smallest terminal the full layout fits, below which it is compact
+const (
+	fullWidth  = 56
+	fullHeight = 15
+)
+
+func (m model) compact() bool {
+	return m.config.Compact || m.width > 0 && (m.width < fullWidth || m.height < fullHeight)
+}
+
+func (m model) boardLeft() int {
+	// columns taken by the rank labels
+	if m.config.HideCoordinates {
+		return 0
+	}
+	return 2
+}
+
+func (m model) squareWidth() int {
+	if m.compact() {
+		return 2
+	}
+	return 3
+}
+
+func (m model) boardHeight() int {
+	if m.config.HideCoordinates {
+		return 8
+	}
+	return 9
+}
+
+func (m model) statusRow() int {
+	// the line of the status bar and the promotion picker
+	return m.boardHeight() + 1
+}
+
+func (m model) movesLeft() int {
+	// past the board, the gap and the pane's border and padding
+	return m.boardLeft() + 8*m.squareWidth() + 4
+}
+
+func (m model) moveLines() int {
+	// rows of moves the pane has room for
+	return m.boardHeight() - 4
+}
+
+func (m model) View() string {
+	if m.wizard != nil {
+		return titleStyle.Render("New game") + "\n\n" + m.wizard.View()
+	}
+	board := m.renderBoard()
+	status := m.renderStatus()
+	if m.promoting != nil {
+		status = m.renderPicker()
+	}
+	if m.compact() {
+		players := m.renderPlayer(chess.White, "○ ") + "  " + m.renderPlayer(chess.Black, "● ")
+		if m.analyzing {
+			players += "\n" + m.renderAnalysisLine()
+		}
+		if m.explorer != nil {
+			players += "\n" + m.renderExplorerLine()
+		}
+		return lipgloss.JoinVertical(lipgloss.Left, board, "", status, players)
+	}
+	height := lipgloss.Height(board) - 2
+	moves := paneStyle.Height(height).Render(m.renderMoves())
+	top := lipgloss.JoinHorizontal(lipgloss.Top, board, "  ", moves)
+	if m.review != nil {
+		top = lipgloss.JoinHorizontal(lipgloss.Top, top, " ", paneStyle.Height(height).Render(m.renderReview(height)))
+	}
+	if m.analyzing {
+		top = lipgloss.JoinHorizontal(lipgloss.Top, top, " ", paneStyle.Height(height).Render(m.renderAnalysis(height)))
+	}
+	if m.explorer != nil {
+		top = lipgloss.JoinHorizontal(lipgloss.Top, top, " ", paneStyle.Height(height).Render(m.renderExplorer(height)))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, top, "", status, labelStyle.Render("arrows or mouse · enter or click picks up and drops · esc or right click cancels\n"+
+		", . home end or click a move to look back · v variation · u undo · r redo · n new · s save · q quit\n"+
+		"a analysis · ? hint · T threats · R review · [ ] mistakes · o explorer · f flip · t theme · p pieces · c coordinates · m compact"))
+}
+
+func (m model) squareAt(x, y int) (chess.Square, bool) {
+	// the square under the screen cell x, y
+	left := m.boardLeft()
+	file, rank := (x-left)/m.squareWidth(), 7-y
+	if x < left || file > 7 || y < 0 || y > 7 {
+		return 0, false
+	}
+	if m.flipped {
+		file, rank = 7-file, 7-rank
+	}
+	return chess.Square(rank*8 + file), true
+}
+
+func (m model) promotionAt(x, y int) (int, bool) {
+	// the picker choice under the screen cell x, y
+	if y != m.statusRow() || x < pickerLeft || (x-pickerLeft)%(pickerWidth+1) == pickerWidth {
+		return 0, false
+	}
+	return (x - pickerLeft) / (pickerWidth + 1), true
+}
+
+func (m model) renderPicker() string {
+	t := findTheme(m.config.Theme)
+	pieces := findPieceSet(m.config.Pieces)
+	choices := make([]string, len(m.promoting))
+	for i, mv := range m.promoting {
+		style := lipgloss.NewStyle().Background(t.light).Foreground(t.blackPiece)
+		if i == m.choice {
+			style = style.Background(t.cursor)
+		}
+		choices[i] = style.Render(" " + pieces.glyph(mv.Promotion, m.view.board.Turn) + " ")
+	}
+	return "Promote to " + strings.Join(choices, " ") + labelStyle.Render("  q r b n, or click")
+}
+
+func (m model) renderBoard() string {
+	// the board from white's side, or black's when flipped, with the
+	// coordinates around it unless they are hidden
+	var sb strings.Builder
+	checked := chess.Square(64)
+	if b := m.view.board; b.InCheck() {
+		checked = b.KingSquare(b.Turn)
+	}
+	threatened := m.threatened()
+	coordinates := !m.config.HideCoordinates
+	for row := 0; row < 8; row++ {
+		rank := 7 - row
+		if m.flipped {
+			rank = row
+		}
+		if coordinates {
+			sb.WriteString(labelStyle.Render(fmt.Sprintf("%d ", rank+1)))
+		}
+		for column := 0; column < 8; column++ {
+			file := column
+			if m.flipped {
+				file = 7 - column
+			}
+			sq := chess.Square(rank*8 + file)
+			sb.WriteString(m.renderSquare(sq, sq == checked, threatened))
+		}
+		if row < 7 || coordinates {
+			sb.WriteByte('\n')
+		}
+	}
+	if coordinates {
+		files := strings.Repeat(" ", m.boardLeft())
+		for column := 0; column < 8; column++ {
+			file := column
+			if m.flipped {
+				file = 7 - column
+			}
+			label := fmt.Sprintf("%*c", m.squareWidth()/2+1, 'a'+file)
+			files += fmt.Sprintf("%-*s", m.squareWidth(), label)
+		}
+		sb.WriteString(labelStyle.Render(files))
+	}
+	return sb.String()
+}
+
+func (m model) renderSquare(sq chess.Square, checked bool, threatened chess.Bitboard) string {
+	t := findTheme(m.config.Theme)
+	light := (sq/8+sq%8)%2 == 1
+	background := t.dark
+	if light {
+		background = t.light
+	}
+	if last := m.view.move.Move; m.view.parent != nil && (sq == last.From || sq == last.To) {
+		background = t.lastDark
+		if light {
+			background = t.lastLight
+		}
+	}
+	switch {
+	case sq == m.cursor:
+		background = t.cursor
+	case m.selected && sq == m.from:
+		background = t.selected
+	case checked:
+		background = t.check
+	default:
+		if color, ok := m.highlight(sq, threatened); ok {
+			background = color
+		}
+	}
+	style := lipgloss.NewStyle().Background(background)
+
+	piece, color := pieceAt(m.view.board, sq)
+	text := " "
+	if piece != chess.Empty {
+		text = findPieceSet(m.config.Pieces).glyph(piece, color)
+		style = style.Foreground(t.whitePiece).Bold(true)
+		if color == chess.Black {
+			style = style.Foreground(t.blackPiece)
+		}
+	}
+	compact := m.compact()
+	if m.isTarget(sq) {
+		// legal destinations show a dot, or a ring around what they
+		// take. small squares only have room for an underline.
+		switch {
+		case piece == chess.Empty && compact:
+			return style.Foreground(t.target).Render(" •")
+		case piece == chess.Empty:
+			return style.Foreground(t.target).Render(" • ")
+		case compact:
+			return style.Underline(true).Render(" " + text)
+		}
+		return style.Render("(" + text + ")")
+	}
+	if compact {
+		return style.Render(" " + text)
+	}
+	return style.Render(" " + text + " ")
+}
+
+func (m model) moveRows() [][2]*node {
+	// the line shown in rows of a white and a black move, nil where
+	// the line starts with black. only the rows that fit are kept,
+	// scrolled so the move shown is among them.
+	var rows [][2]*node
+	shown := 0
+	for _, n := range m.view.line() {
+		if n.parent.board.Turn == chess.White || len(rows) == 0 {
+			rows = append(rows, [2]*node{})
+		}
+		rows[len(rows)-1][n.parent.board.Turn] = n
+		if n == m.view {
+			shown = len(rows) - 1
+		}
+	}
+	if lines := m.moveLines(); len(rows) > lines {
+		first := min(len(rows)-lines, shown)
+		if m.view == m.head || m.view.next() == nil {
+			first = len(rows) - lines
+		}
+		rows = rows[first : first+lines]
+	}
+	return rows
+}
+
+func (m model) moveAt(x, y int) *node {
+	// the move in the move list under the screen cell x, y
+	rows := m.moveRows()
+	if m.compact() {
+		return nil
+	}
+	row, column := y-movesTop, x-m.movesLeft()-numberWidth
+	if row < 0 || row >= len(rows) || column < 0 || column >= 2*moveWidth {
+		return nil
+	}
+	return rows[row][column/moveWidth]
+}
+
+func (m model) renderMoves() string {
+	// the move list, numbered in pairs. the move shown is lit up and
+	// moves with variations beside them are marked.
+	var lines []string
+	for _, row := range m.moveRows() {
+		first := row[chess.White]
+		if first == nil {
+			first = row[chess.Black]
+		}
+		line := fmt.Sprintf("%3d. ", first.parent.board.MoveCounter/2+1)
+		for _, n := range row {
+			if n != nil || first == row[chess.Black] {
+				line += m.renderMove(n)
+			}
+		}
+		lines = append(lines, line)
+	}
+	header := m.renderPlayer(chess.White, "○ ") + "\n" + m.renderPlayer(chess.Black, "● ")
+	return header + "\n" + lipgloss.NewStyle().Width(numberWidth+2*moveWidth).Render(strings.Join(lines, "\n"))
+}
+
+func (m model) renderMove(n *node) string {
+	if n == nil {
+		return fmt.Sprintf("%-*s", moveWidth, "...")
+	}
+	style := lipgloss.NewStyle()
+	san := n.move.SAN
+	if len(n.siblings()) > 1 {
+		style = variationStyle
+		san += "*"
+	}
+	if n == m.view {
+		t := findTheme(m.config.Theme)
+		style = style.Background(t.cursor).Foreground(t.blackPiece)
+	}
+	text, width := style.Render(san), len(san)
+	if m.review != nil {
+		if v, ok := m.review.verdictOf(n); ok && classSuffixes[v.class] != "" {
+			text += classStyles[v.class].Render(classSuffixes[v.class])
+			width += len(classSuffixes[v.class])
+		}
+	}
+	return text + strings.Repeat(" ", max(moveWidth-width, 1))
+}
+
+func (m model) renderPlayer(color chess.Color, mark string) string {
+	// the player's name and, with a clock, the time left on it. the
+	// clock that is running is lit up.
+	line := titleStyle.Render(mark + m.names[color])
+	if m.clock == nil {
+		return line
+	}
+	style := clockStyle
+	if m.clock.turn == color && !m.clock.stopped {
+		style = runningClockStyle
+	}
+	return line + " " + style.Render(formatClock(m.clock.left(color, time.Now())))
+}
+
+func (m model) renderStatus() string {
+	var status string
+	n := m.view
+	switch {
+	case n.outcome.Over():
+		status = fmt.Sprintf("%s by %s", n.outcome.Result, n.outcome.Reason)
+		if m.review == nil && m.trainer == nil {
+			status += ", R to review"
+		}
+	case n.board.Turn == chess.White:
+		status = "White to move"
+	default:
+		status = "Black to move"
+	}
+	if !n.outcome.Over() && n.board.InCheck() {
+		status += ", check!"
+	}
+	if m.trainer != nil && n == m.head {
+		status = m.renderPuzzleStatus()
+	}
+	if n != m.head {
+		status += fmt.Sprintf(" (move %d of %d)", len(n.path()), len(m.head.path()))
+	}
+	if m.thinking {
+		status += " (thinking)"
+	}
+	line := statusStyle.Render(status)
+	if m.status != "" {
+		line += " " + messageStyle.Render(m.status)
+	}
+	return line
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/engine"
+	"chess/pgn"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+// who plays the game
+const (
+	humanVsHuman   = "human vs human"
+	humanVsEngine  = "human vs engine"
+	engineVsEngine = "engine vs engine"
+	puzzles        = "tactics puzzles"
+)
+
+// where the game starts
+const (
+	fromStart = "initial position"
+	fromFEN   = "FEN"
+	fromPGN   = "PGN file"
+)
+
+// the rules the game is played by
+const (
+	standard = "Standard"
+	chess960 = "Chess960"
+)
+
+var timeControls = []timeControl{
+	{Name: "No clock"},
+	mustTimeControl("Bullet 1+0", "1"),
+	mustTimeControl("Blitz 3+2", "3+2"),
+	mustTimeControl("Blitz 5+0", "5"),
+	mustTimeControl("Blitz 5, 3s delay", "5d3"),
+	mustTimeControl("Blitz 5, 3s Bronstein", "5b3"),
+	mustTimeControl("Rapid 10+5", "10+5"),
+	mustTimeControl("Rapid 15+10", "15+10"),
+	mustTimeControl("Classical 30+20", "30+20"),
+	mustTimeControl("Classical 40/90+30, 30+30", "40/90+30, 30+30"),
+}
+
+// picks the custom time control input
+const customClock = "Custom"
+
+// the answers of the new game wizard
+type setup struct {
+	name        string
+	mode        string
+	side        string // the human's colour against the engine: White, Black or Random
+	difficulty  string
+	timeControl string
+	customClock string
+	start       string
+	fen         string
+	pgnPath     string
+	variant     string // Chess960 shuffles the back rank of a game from the start
+	puzzlePath  string // a CSV file in the lichess puzzle format
+	puzzleTheme string // only puzzles with this theme, eg fork or mateIn2
+}
+
+// strength used when no difficulty limits the engine
+const fullStrength = "Full strength"
+
+func newSetup(c config) *setup {
+	return &setup{
+		name:        c.Name,
+		mode:        humanVsEngine,
+		side:        "White",
+		difficulty:  engine.Difficulties[len(engine.Difficulties)/2].Name,
+		timeControl: timeControls[0].Name,
+		start:       fromStart,
+		variant:     standard,
+	}
+}
+
+func newWizard(s *setup) *huh.Form {
+	// builds the form asking how the next game is played
+	var difficulties []huh.Option[string]
+	for _, d := range engine.Difficulties {
+		difficulties = append(difficulties, huh.NewOption(fmt.Sprintf("%s (~%d Elo)", d.Name, d.Elo), d.Name))
+	}
+	difficulties = append(difficulties, huh.NewOption(fullStrength, fullStrength))
+	var clocks []huh.Option[string]
+	for _, tc := range timeControls {
+		clocks = append(clocks, huh.NewOption(tc.Name, tc.Name))
+	}
+	clocks = append(clocks, huh.NewOption(customClock, customClock))
+
+	return huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("What's your name?").
+				Placeholder("Enter your name").
+				Value(&s.name),
+			huh.NewSelect[string]().
+				Title("Who plays?").
+				Options(huh.NewOptions(humanVsHuman, humanVsEngine, engineVsEngine, puzzles)...).
+				Value(&s.mode),
+		),
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Play as").
+				Options(huh.NewOptions("White", "Black", "Random")...).
+				Value(&s.side),
+		).WithHideFunc(func() bool { return s.mode != humanVsEngine }),
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Select difficulty").
+				Options(difficulties...).
+				Value(&s.difficulty),
+		).WithHideFunc(func() bool { return s.mode == humanVsHuman || s.mode == puzzles }),
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Time control").
+				Options(clocks...).
+				Value(&s.timeControl),
+			huh.NewSelect[string]().
+				Title("Variant").
+				Options(huh.NewOptions(standard, chess960)...).
+				Value(&s.variant),
+			huh.NewSelect[string]().
+				Title("Start from").
+				Options(huh.NewOptions(fromStart, fromFEN, fromPGN)...).
+				Value(&s.start),
+		).WithHideFunc(func() bool { return s.mode == puzzles }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Time control").
+				Description("stages of [moves/]minutes with +increment, d(elay) or b(ronstein) seconds, eg 40/90+30, 30+30").
+				Value(&s.customClock).
+				Validate(func(spec string) error {
+					_, err := parseTimeControl(spec)
+					return err
+				}),
+		).WithHideFunc(func() bool { return s.timeControl != customClock || s.mode == puzzles }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("FEN").
+				Value(&s.fen).
+				Validate(func(fen string) error {
+					_, err := chess.NewBoardFromFEN(strings.TrimSpace(fen))
+					return err
+				}),
+		).WithHideFunc(func() bool { return s.start != fromFEN || s.mode == puzzles }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("PGN file").
+				Description("the game is continued from its last move").
+				Value(&s.pgnPath).
+				Validate(func(path string) error {
+					_, err := loadPGN(path)
+					return err
+				}),
+		).WithHideFunc(func() bool { return s.start != fromPGN || s.mode == puzzles }),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Puzzle file").
+				Description("a CSV file in the lichess puzzle database format").
+				Value(&s.puzzlePath).
+				Validate(func(path string) error {
+					_, err := os.Stat(strings.TrimSpace(path))
+					return err
+				}),
+			huh.NewInput().
+				Title("Theme").
+				Description("only puzzles with this theme, eg fork or mateIn2. empty for all").
+				Value(&s.puzzleTheme),
+		).WithHideFunc(func() bool { return s.mode != puzzles }),
+	).WithTheme(huh.ThemeCatppuccin()).WithShowHelp(true)
+}
+
+func loadPGN(path string) (*pgn.Game, error) {
+	// reads the first game of a PGN file
+	f, err := os.Open(strings.TrimSpace(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return pgn.NewReader(f).Next()
+}
+
+func (s *setup) clock() timeControl {
+	if s.timeControl == customClock {
+		if tc, err := parseTimeControl(s.customClock); err == nil {
+			return tc
+		}
+	}
+	for _, tc := range timeControls {
+		if tc.Name == s.timeControl {
+			return tc
+		}
+	}
+	return timeControls[0]
+}
+
+func (s *setup) newEngine() *engine.Engine {
+	// an engine playing at the chosen difficulty
+	e := engine.New()
+	if s.difficulty != fullStrength {
+		e.Options.LimitStrength = true
+		e.Options.Elo = engine.DifficultyElo(s.difficulty)
+	}
+	return e
+}
+
+func (s *setup) engineName() string {
+	if s.difficulty == fullStrength {
+		return "chess engine"
+	}
+	return fmt.Sprintf("chess engine (%s)", s.difficulty)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	chess "chess/board"
+	"chess/engine"
+	"testing"
+)
+
+func TestBeginLimitsStrength(t *testing.T) {
+	// the engines of a new game play at the difficulty chosen
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	difficulties := []string{fullStrength}
+	for _, d := range engine.Difficulties {
+		difficulties = append(difficulties, d.Name)
+	}
+	for _, difficulty := range difficulties {
+		for _, mode := range []string{humanVsEngine, engineVsEngine} {
+			m := newModel()
+			m.setup.mode = mode
+			m.setup.side = "White"
+			m.setup.difficulty = difficulty
+			if err := m.begin(); err != nil {
+				t.Fatal(err)
+			}
+			engines := m.engines[:]
+			if mode == humanVsEngine {
+				if m.engines[chess.White] != nil {
+					t.Fatalf("%s: an engine plays the human's side", difficulty)
+				}
+				engines = engines[chess.Black:]
+			}
+			for _, e := range engines {
+				if e == nil {
+					t.Fatalf("%s, %s: an engine is missing", difficulty, mode)
+				}
+				limited := difficulty != fullStrength
+				if e.Options.LimitStrength != limited {
+					t.Errorf("%s, %s: LimitStrength %v, expected %v", difficulty, mode, e.Options.LimitStrength, limited)
+				}
+				if limited && e.Options.Elo != engine.DifficultyElo(difficulty) {
+					t.Errorf("%s, %s: Elo %d, expected %d", difficulty, mode, e.Options.Elo, engine.DifficultyElo(difficulty))
+				}
+			}
+		}
+	}
+}
+
+func TestBeginChess960(t *testing.T) {
+	// a Chess960 game starts from a shuffled back rank and is saved
+	// with the tags that play it back by the same rules
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := newModel()
+	m.setup.mode = humanVsHuman
+	m.setup.variant = chess960
+	if err := m.begin(); err != nil {
+		t.Fatal(err)
+	}
+	b := m.head.board
+	if !b.Chess960 {
+		t.Fatal("the game isn't played as Chess960")
+	}
+	g := m.record()
+	if g.Tag("Variant") != "Chess960" || g.Tag("FEN") != b.ToFEN() {
+		t.Errorf("saved with Variant %q and FEN %q", g.Tag("Variant"), g.Tag("FEN"))
+	}
+	start, err := g.StartBoard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Chess960 || start.ToFEN() != b.ToFEN() {
+		t.Errorf("read back as %s, Chess960 %v", start.ToFEN(), start.Chess960)
+	}
+}
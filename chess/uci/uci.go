@@ -0,0 +1,300 @@
+package uci
+
+import (
+	"bufio"
+	chess "chess/board"
+	"chess/engine"
+	"chess/nnue"
+	"chess/tablebase"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type session struct {
+	engine  *engine.Engine
+	board   *chess.Board
+	history []uint64 // hashes of the positions before board
+	out     io.Writer
+	mu      sync.Mutex
+	done    chan struct{}
+}
+
+func Run(in io.Reader, out io.Writer, e *engine.Engine) {
+	// speaks the UCI protocol on in/out until quit or end of input
+	s := &session{engine: e, board: chess.NewBoard(), out: out}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			s.println("id name chess")
+			s.println("id author jadotte")
+			s.println(fmt.Sprintf("option name Hash type spin default %d min 1 max 4096", engine.DefaultOptions.HashMB))
+			s.println("option name Ponder type check default false")
+			s.println("option name SyzygyPath type string default <empty>")
+			s.println(fmt.Sprintf("option name SyzygyProbeDepth type spin default %d min 1 max 100", engine.DefaultOptions.SyzygyProbeDepth))
+			s.println("option name EvalFile type string default <empty>")
+			defaults := engine.DefaultOptions
+			for _, name := range checkOptions {
+				s.println(fmt.Sprintf("option name %s type check default %t", name, *techniqueOption(&defaults, name)))
+			}
+			s.println("option name UCI_LimitStrength type check default false")
+			s.println(fmt.Sprintf("option name UCI_Elo type spin default %d min %d max %d", engine.DefaultOptions.Elo, engine.MinElo, engine.MaxElo))
+			s.println("uciok")
+		case "isready":
+			s.println("readyok")
+		case "ucinewgame":
+			s.stop()
+			e.NewGame()
+			s.board = chess.NewBoard()
+			s.history = nil
+		case "position":
+			s.stop()
+			if err := s.position(fields[1:]); err != nil {
+				s.println("info string " + err.Error())
+			}
+		case "go":
+			s.stop()
+			s.goSearch(parseLimits(fields[1:]))
+		case "stop":
+			s.stop()
+		case "ponderhit":
+			e.PonderHit()
+		case "setoption":
+			s.stop()
+			s.setOption(fields[1:])
+		case "d":
+			s.println(s.board.PrintBoard() + "Fen: " + s.board.ToFEN())
+		case "quit":
+			s.stop()
+			return
+		}
+	}
+	s.stop()
+}
+
+func (s *session) println(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintln(s.out, line)
+}
+
+func (s *session) stop() {
+	// stops a running search and waits for its bestmove to be printed
+	if s.done == nil {
+		return
+	}
+	s.engine.Stop()
+	<-s.done
+	s.done = nil
+}
+
+func (s *session) position(args []string) error {
+	// position [startpos | fen <fen>] [moves <m1> ... <mi>]
+	var b *chess.Board
+	i := 0
+	switch {
+	case len(args) > 0 && args[0] == "startpos":
+		b = chess.NewBoard()
+		i = 1
+	case len(args) > 0 && args[0] == "fen":
+		i = 1
+		for i < len(args) && args[i] != "moves" {
+			i++
+		}
+		var err error
+		b, err = chess.NewBoardFromFEN(strings.Join(args[1:i], " "))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("position needs startpos or fen")
+	}
+	var history []uint64
+	if i < len(args) && args[i] == "moves" {
+		for _, text := range args[i+1:] {
+			m, err := b.ParseMove(text)
+			if err != nil {
+				return err
+			}
+			history = append(history, b.Hash())
+			b.MakeMove(m)
+		}
+	}
+	s.board = b
+	s.history = history
+	return nil
+}
+
+func (s *session) goSearch(limits engine.Limits) {
+	// runs the search in the background, printing info lines as it
+	// goes and bestmove (with the expected reply to ponder on) at the end
+	done := make(chan struct{})
+	s.done = done
+	infos := s.engine.Analyze(s.board, s.history, limits)
+	go func() {
+		defer close(done)
+		var last engine.SearchInfo
+		for info := range infos {
+			last = info
+			s.println(FormatInfo(info))
+		}
+		if len(last.PV) == 0 {
+			s.println("bestmove 0000")
+		} else if len(last.PV) == 1 {
+			s.println("bestmove " + last.PV[0].String())
+		} else {
+			s.println("bestmove " + last.PV[0].String() + " ponder " + last.PV[1].String())
+		}
+	}()
+}
+
+func (s *session) setOption(args []string) {
+	// setoption name <id> [value <x>]
+	var name, value []string
+	target := &name
+	for _, a := range args {
+		switch a {
+		case "name":
+			target = &name
+		case "value":
+			target = &value
+		default:
+			*target = append(*target, a)
+		}
+	}
+	switch strings.ToLower(strings.Join(name, " ")) {
+	case "hash":
+		if mb, err := strconv.Atoi(strings.Join(value, "")); err == nil && mb > 0 {
+			s.engine.SetHash(mb)
+		}
+	case "syzygypath":
+		path := strings.Join(value, " ")
+		if path == "<empty>" {
+			path = ""
+		}
+		if err := tablebase.Init(path); err != nil {
+			s.println("info string " + err.Error())
+		} else if path != "" {
+			s.println(fmt.Sprintf("info string found tablebases up to %d pieces", tablebase.MaxPieces()))
+		}
+	case "syzygyprobedepth":
+		if depth, err := strconv.Atoi(strings.Join(value, "")); err == nil {
+			s.engine.Options.SyzygyProbeDepth = depth
+		}
+	case "evalfile":
+		path := strings.Join(value, " ")
+		if path == "<empty>" || path == "" {
+			s.engine.SetNNUE(nil)
+			return
+		}
+		net, err := nnue.Load(path)
+		if err != nil {
+			s.println("info string " + err.Error())
+			return
+		}
+		s.engine.SetNNUE(net)
+		s.println(fmt.Sprintf("info string loaded nnue with %d hidden units", net.Hidden))
+	case "uci_limitstrength":
+		s.engine.Options.LimitStrength = strings.EqualFold(strings.Join(value, ""), "true")
+	case "uci_elo":
+		if elo, err := strconv.Atoi(strings.Join(value, "")); err == nil {
+			s.engine.Options.Elo = max(engine.MinElo, min(elo, engine.MaxElo))
+		}
+	default:
+		for _, option := range checkOptions {
+			if strings.EqualFold(option, strings.Join(name, " ")) {
+				*techniqueOption(&s.engine.Options, option) = strings.EqualFold(strings.Join(value, ""), "true")
+			}
+		}
+	}
+}
+
+// switches for the search techniques, so their worth can be measured
+var checkOptions = []string{"NullMove", "LMR", "Futility", "ReverseFutility", "AspirationWindows", "CheckExtensions", "PVS"}
+
+func techniqueOption(o *engine.Options, name string) *bool {
+	switch name {
+	case "NullMove":
+		return &o.NullMove
+	case "LMR":
+		return &o.LateMoveReduction
+	case "Futility":
+		return &o.Futility
+	case "ReverseFutility":
+		return &o.ReverseFutility
+	case "AspirationWindows":
+		return &o.AspirationWindows
+	case "CheckExtensions":
+		return &o.CheckExtensions
+	}
+	return &o.PVS
+}
+
+func parseLimits(args []string) engine.Limits {
+	var limits engine.Limits
+	for i := 0; i < len(args); i++ {
+		next := func() int {
+			if i+1 >= len(args) {
+				return 0
+			}
+			i++
+			n, _ := strconv.Atoi(args[i])
+			return n
+		}
+		switch args[i] {
+		case "infinite":
+			limits.Infinite = true
+		case "ponder":
+			limits.Ponder = true
+		case "depth":
+			limits.Depth = next()
+		case "nodes":
+			limits.Nodes = uint64(next())
+		case "movetime":
+			limits.MoveTime = time.Duration(next()) * time.Millisecond
+		case "wtime":
+			limits.WTime = time.Duration(next()) * time.Millisecond
+		case "btime":
+			limits.BTime = time.Duration(next()) * time.Millisecond
+		case "winc":
+			limits.WInc = time.Duration(next()) * time.Millisecond
+		case "binc":
+			limits.BInc = time.Duration(next()) * time.Millisecond
+		case "movestogo":
+			limits.MovesToGo = next()
+		}
+	}
+	return limits
+}
+
+func FormatInfo(info engine.SearchInfo) string {
+	// writes a SearchInfo as a UCI info line
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "info depth %d seldepth %d", info.Depth, info.SelDepth)
+	if info.Mate != 0 {
+		fmt.Fprintf(&sb, " score mate %d", info.Mate)
+	} else {
+		fmt.Fprintf(&sb, " score cp %d", info.Score)
+	}
+	ms := info.Time.Milliseconds()
+	nps := uint64(0)
+	if ms > 0 {
+		nps = info.Nodes * 1000 / uint64(ms)
+	}
+	fmt.Fprintf(&sb, " nodes %d nps %d hashfull %d tbhits %d time %d", info.Nodes, nps, info.Hashfull, info.TBHits, ms)
+	if len(info.PV) > 0 {
+		sb.WriteString(" pv")
+		for _, m := range info.PV {
+			sb.WriteString(" " + m.String())
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,122 @@
+package uci
+
+import (
+	"bufio"
+	"chess/engine"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a UCI session driven line by line
+type conversation struct {
+	t     *testing.T
+	in    *io.PipeWriter
+	lines chan string
+	ended chan struct{}
+}
+
+func converse(t *testing.T) *conversation {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &conversation{t: t, in: inW, lines: make(chan string, 1024), ended: make(chan struct{})}
+	go func() {
+		defer close(c.ended)
+		defer outW.Close()
+		Run(inR, outW, engine.New())
+	}()
+	go func() {
+		defer close(c.lines)
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			c.lines <- scanner.Text()
+		}
+	}()
+	t.Cleanup(func() {
+		c.send("quit")
+		<-c.ended
+	})
+	return c
+}
+
+func (c *conversation) send(command string) {
+	fmt.Fprintln(c.in, command)
+}
+
+func (c *conversation) await(prefix string, within time.Duration) string {
+	// the first line starting with prefix, failing if none comes in time
+	c.t.Helper()
+	timeout := time.After(within)
+	for {
+		select {
+		case line := <-c.lines:
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			c.t.Fatalf("no %q within %v", prefix, within)
+		}
+	}
+}
+
+func (c *conversation) silent(prefix string, during time.Duration) {
+	// fails if a line starting with prefix comes during the wait
+	c.t.Helper()
+	timeout := time.After(during)
+	for {
+		select {
+		case line := <-c.lines:
+			if strings.HasPrefix(line, prefix) {
+				c.t.Fatalf("unexpected %q", line)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	c := converse(t)
+	c.send("uci")
+	c.await("uciok", time.Second)
+	c.send("isready")
+	c.await("readyok", time.Second)
+}
+
+func TestGoDepth(t *testing.T) {
+	c := converse(t)
+	c.send("position fen k7/8/1K6/8/8/8/8/7R w - - 0 1")
+	c.send("go depth 3")
+	if line := c.await("bestmove", 10*time.Second); line != "bestmove h1h8" {
+		t.Errorf("got %q, expected the mate", line)
+	}
+}
+
+func TestPonder(t *testing.T) {
+	// a ponder search holds its move until ponderhit, then finishes on
+	// the clock it was given
+	c := converse(t)
+	c.send("position startpos moves e2e4")
+	c.send("go ponder wtime 1000 btime 1000")
+	c.await("info depth 1 ", 5*time.Second)
+	c.silent("bestmove", 300*time.Millisecond)
+	c.send("ponderhit")
+	c.await("bestmove", 5*time.Second)
+}
+
+func TestStop(t *testing.T) {
+	// stop ends both a ponder and an infinite search with a move
+	for _, search := range []string{"go ponder", "go infinite"} {
+		c := converse(t)
+		c.send("position startpos")
+		c.send(search)
+		c.await("info depth 1 ", 5*time.Second)
+		c.silent("bestmove", 200*time.Millisecond)
+		c.send("stop")
+		if line := c.await("bestmove", 5*time.Second); line == "bestmove 0000" {
+			t.Errorf("%s: no move after stop", search)
+		}
+	}
+}